@@ -18,7 +18,7 @@ func main() {
 	fmt.Println("Configuration loaded successfully!")
 
 	fmt.Println("\n=== Initializing Database ===")
-	db, err := database.NewPostgresDB()
+	db, err := database.NewPostgresDB(cfg.ContainerPrefix)
 	if err != nil {
 		log.Fatal("Database connection failed:", err)
 	}
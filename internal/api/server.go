@@ -1,18 +1,28 @@
 package api
 
 import (
+	"context"
 	"log"
 	"net/http"
 
+	"github.com/chrisabs/storage/internal/blob"
+	"github.com/chrisabs/storage/internal/checkout"
 	"github.com/chrisabs/storage/internal/config"
 	"github.com/chrisabs/storage/internal/container"
 	"github.com/chrisabs/storage/internal/item"
+	"github.com/chrisabs/storage/internal/loan"
+	"github.com/chrisabs/storage/internal/location"
 	"github.com/chrisabs/storage/internal/middleware"
 	"github.com/chrisabs/storage/internal/platform/database"
 	"github.com/chrisabs/storage/internal/recent"
+	"github.com/chrisabs/storage/internal/scan"
+	"github.com/chrisabs/storage/internal/share"
 	"github.com/chrisabs/storage/internal/search"
+	"github.com/chrisabs/storage/internal/storage"
 	"github.com/chrisabs/storage/internal/tag"
+	"github.com/chrisabs/storage/internal/unit"
 	"github.com/chrisabs/storage/internal/user"
+	"github.com/chrisabs/storage/internal/valuation"
 	"github.com/chrisabs/storage/internal/workspace"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -56,15 +66,42 @@ func (s *Server) Run() {
     tagRepo := tag.NewRepository(s.db.DB)
     searchRepo := search.NewRepository(s.db.DB)
     recentRepo := recent.NewRepository(s.db.DB)
+    blobRepo := blob.NewRepository(s.db.DB)
+    locationRepo := location.NewRepository(s.db.DB)
+    scanRepo := scan.NewRepository(s.db.DB)
+    shareRepo := share.NewRepository(s.db.DB)
+    checkoutRepo := checkout.NewRepository(s.db.DB)
+    loanRepo := loan.NewRepository(s.db.DB)
+    unitRepo := unit.NewRepository(s.db.DB)
+    valuationRepo := valuation.NewRepository(s.db.DB)
+
+    // Image URLs are signed on the way out and blobs cleaned up in the background
+    var urlSigner *storage.URLSigner
+    s3Handler, err := storage.NewS3Handler()
+    if err != nil {
+        log.Printf("Blob cleanup and URL signing disabled: %v", err)
+    } else {
+        urlSigner = s3Handler.URLSigner()
+        blobService := blob.NewService(blobRepo, s3Handler)
+        go blobService.Run(context.Background())
+    }
 
     // Initialise services
-    userService := user.NewService(userRepo, s.config.JWTSecret)
+    userService := user.NewService(userRepo, s.config.JWTSecret, urlSigner)
     workspaceService := workspace.NewService(workspaceRepo)
-    containerService := container.NewService(containerRepo)
-    itemService := item.NewService(itemRepo)
-    tagService := tag.NewService(tagRepo)
-    searchService := search.NewService(searchRepo)
+    containerService := container.NewService(containerRepo, urlSigner, s.config.ContainerPrefix, s.config.QRBaseURL)
+    unitService := unit.NewService(unitRepo)
+    exchangeRates := valuation.NewRates(s.config.BaseCurrency, s.config.ExchangeRates)
+    itemService := item.NewService(itemRepo, urlSigner, unitService, exchangeRates)
+    tagService := tag.NewService(tagRepo, urlSigner)
+    searchService := search.NewService(searchRepo, urlSigner)
     recentService := recent.NewService(recentRepo)
+    locationService := location.NewService(locationRepo)
+    shareService := share.NewService(shareRepo, containerService, s.config.ShareBaseURL)
+    scanService := scan.NewService(scanRepo, containerService, itemService, locationService)
+    checkoutService := checkout.NewService(checkoutRepo)
+    loanService := loan.NewService(loanRepo)
+    valuationService := valuation.NewService(valuationRepo, exchangeRates)
 
     // Initialise handlers
     userHandler := user.NewHandler(userService, authMiddleware)
@@ -74,6 +111,13 @@ func (s *Server) Run() {
     tagHandler := tag.NewHandler(tagService, authMiddleware)
     searchHandler := search.NewHandler(searchService, authMiddleware)
     recentHandler := recent.NewHandler(recentService, authMiddleware)
+    locationHandler := location.NewHandler(locationService, authMiddleware)
+    scanHandler := scan.NewHandler(scanService, authMiddleware)
+    shareHandler := share.NewHandler(shareService, containerService, authMiddleware)
+    checkoutHandler := checkout.NewHandler(checkoutService, containerService, itemService, authMiddleware)
+    loanHandler := loan.NewHandler(loanService, containerService, itemService, authMiddleware)
+    unitHandler := unit.NewHandler(unitService, authMiddleware)
+    valuationHandler := valuation.NewHandler(valuationService, authMiddleware)
 
     // Register routes
     userHandler.RegisterRoutes(router)
@@ -83,6 +127,13 @@ func (s *Server) Run() {
     tagHandler.RegisterRoutes(router)
     searchHandler.RegisterRoutes(router)
     recentHandler.RegisterRoutes(router)
+    locationHandler.RegisterRoutes(router)
+    scanHandler.RegisterRoutes(router)
+    shareHandler.RegisterRoutes(router)
+    checkoutHandler.RegisterRoutes(router)
+    loanHandler.RegisterRoutes(router)
+    unitHandler.RegisterRoutes(router)
+    valuationHandler.RegisterRoutes(router)
 
     handler := c.Handler(router)
 
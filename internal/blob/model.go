@@ -0,0 +1,16 @@
+package blob
+
+import "time"
+
+type OutboxEntry struct {
+    ID        int
+    ObjectKey string
+    Attempts  int
+    LastError string
+    CreatedAt time.Time
+}
+
+type SweepResult struct {
+    Scanned int `json:"scanned"`
+    Deleted int `json:"deleted"`
+}
\ No newline at end of file
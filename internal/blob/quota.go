@@ -0,0 +1,51 @@
+package blob
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/chrisabs/storage/internal/storage"
+)
+
+type Execer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ReserveStorage charges bytes against the user's quota, failing with
+// storage.ErrQuotaExceeded when the upload would not fit. Users without an
+// explicit quota fall back to defaultQuota.
+func ReserveStorage(exec Execer, userID int, bytes, defaultQuota int64) error {
+    query := `
+        UPDATE users
+        SET storage_used_bytes = storage_used_bytes + $2
+        WHERE id = $1 AND storage_used_bytes + $2 <= COALESCE(storage_quota_bytes, $3)`
+
+    result, err := exec.Exec(query, userID, bytes, defaultQuota)
+    if err != nil {
+        return fmt.Errorf("error reserving storage: %v", err)
+    }
+
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking storage reservation: %v", err)
+    }
+
+    if rowsAffected == 0 {
+        return fmt.Errorf("%w: upload needs %d bytes", storage.ErrQuotaExceeded, bytes)
+    }
+
+    return nil
+}
+
+func ReleaseStorage(exec Execer, userID int, bytes int64) error {
+    query := `
+        UPDATE users
+        SET storage_used_bytes = GREATEST(storage_used_bytes - $2, 0)
+        WHERE id = $1`
+
+    if _, err := exec.Exec(query, userID, bytes); err != nil {
+        return fmt.Errorf("error releasing storage: %v", err)
+    }
+
+    return nil
+}
\ No newline at end of file
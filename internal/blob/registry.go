@@ -0,0 +1,188 @@
+package blob
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+// claim takes a reference on the blob registered under key, returning nil
+// when there is none. The row lock it takes keeps a concurrent Release from
+// removing the blob before the caller's transaction ends.
+func claim(tx *sql.Tx, key string) (*models.ItemImage, error) {
+    query := `
+        UPDATE blob
+        SET ref_count = ref_count + 1
+        WHERE key = $1
+        RETURNING url, width, height, byte_size, storage_bytes, variants`
+
+    image, _, err := scanBlob(tx.QueryRow(query, key))
+    return image, err
+}
+
+func scanBlob(row *sql.Row) (*models.ItemImage, int64, error) {
+    image := new(models.ItemImage)
+    var storageBytes int64
+    var variantsJSON []byte
+
+    err := row.Scan(
+        &image.URL, &image.Width, &image.Height,
+        &image.ByteSize, &storageBytes, &variantsJSON,
+    )
+    if err == sql.ErrNoRows {
+        return nil, 0, nil
+    }
+    if err != nil {
+        return nil, 0, fmt.Errorf("error looking up blob: %v", err)
+    }
+
+    if err := json.Unmarshal(variantsJSON, &image.Variants); err != nil {
+        return nil, 0, fmt.Errorf("error parsing blob variants: %v", err)
+    }
+
+    return image, storageBytes, nil
+}
+
+// Register adds a reference to the blob stored under key, creating the
+// registry entry on first use. The stored bytes are charged to the blob
+// rather than to its references: userID, whose quota paid for them, gets them
+// back when the last reference goes away.
+func Register(tx *sql.Tx, key string, image *models.ItemImage, storageBytes int64, userID int) error {
+    variantsJSON, err := json.Marshal(image.Variants)
+    if err != nil {
+        return fmt.Errorf("error encoding blob variants: %v", err)
+    }
+
+    if err := lock(tx, key); err != nil {
+        return err
+    }
+
+    query := `
+        INSERT INTO blob (key, url, width, height, byte_size, storage_bytes, variants, user_id, ref_count, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1, $9)
+        ON CONFLICT (key) DO UPDATE SET ref_count = blob.ref_count + 1`
+
+    _, err = tx.Exec(
+        query, key, image.URL, image.Width, image.Height,
+        image.ByteSize, storageBytes, variantsJSON, userID, time.Now().UTC(),
+    )
+    if err != nil {
+        return fmt.Errorf("error registering blob: %v", err)
+    }
+
+    return nil
+}
+
+// Release drops a reference to the blob stored under key. When the last
+// reference goes away the registry entry is removed, its objects are queued
+// for deletion and its bytes are returned to the uploader's quota.
+func Release(tx *sql.Tx, key string) error {
+    query := `
+        UPDATE blob
+        SET ref_count = ref_count - 1
+        WHERE key = $1
+        RETURNING ref_count, url, variants`
+
+    var refCount int
+    var url string
+    var variantsJSON []byte
+
+    err := tx.QueryRow(query, key).Scan(&refCount, &url, &variantsJSON)
+    if err == sql.ErrNoRows {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("error releasing blob: %v", err)
+    }
+
+    if refCount > 0 {
+        return nil
+    }
+
+    var ownerID sql.NullInt64
+    var storageBytes int64
+    err = tx.QueryRow(
+        `DELETE FROM blob WHERE key = $1 RETURNING user_id, storage_bytes`, key,
+    ).Scan(&ownerID, &storageBytes)
+    if err != nil {
+        return fmt.Errorf("error removing blob: %v", err)
+    }
+
+    urls, err := ImageURLs(url, variantsJSON)
+    if err != nil {
+        return err
+    }
+
+    if err := Enqueue(tx, urls...); err != nil {
+        return err
+    }
+
+    if !ownerID.Valid {
+        return nil
+    }
+    return ReleaseStorage(tx, int(ownerID.Int64), storageBytes)
+}
+
+// DeleteImages runs a DELETE ... RETURNING url, variants, user_id,
+// storage_bytes, blob_key statement and releases the removed images' blobs
+// within the same transaction. Images stored before deduplication have no
+// blob; they are queued directly and their own storage_bytes go back to the
+// uploader's quota.
+func DeleteImages(tx *sql.Tx, query string, args ...interface{}) (int, error) {
+    rows, err := tx.Query(query, args...)
+    if err != nil {
+        return 0, err
+    }
+
+    var urls, blobKeys []string
+    freed := make(map[int]int64)
+    deleted := 0
+    for rows.Next() {
+        var url string
+        var variantsJSON []byte
+        var userID sql.NullInt64
+        var storageBytes int64
+        var blobKey sql.NullString
+        if err := rows.Scan(&url, &variantsJSON, &userID, &storageBytes, &blobKey); err != nil {
+            rows.Close()
+            return 0, err
+        }
+
+        if blobKey.Valid {
+            blobKeys = append(blobKeys, blobKey.String)
+        } else {
+            imageURLs, err := ImageURLs(url, variantsJSON)
+            if err != nil {
+                rows.Close()
+                return 0, err
+            }
+            urls = append(urls, imageURLs...)
+        }
+        if userID.Valid {
+            freed[int(userID.Int64)] += storageBytes
+        }
+        deleted++
+    }
+    rows.Close()
+
+    if err := Enqueue(tx, urls...); err != nil {
+        return 0, err
+    }
+
+    for _, key := range blobKeys {
+        if err := Release(tx, key); err != nil {
+            return 0, err
+        }
+    }
+
+    for userID, bytes := range freed {
+        if err := ReleaseStorage(tx, userID, bytes); err != nil {
+            return 0, err
+        }
+    }
+
+    return deleted, nil
+}
@@ -0,0 +1,217 @@
+package blob
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+// Enqueue records objects for deletion inside the caller's transaction, so a
+// blob is only removed once the rows referencing it are gone for good.
+func Enqueue(tx *sql.Tx, urls ...string) error {
+    query := `
+        INSERT INTO blob_outbox (object_key, created_at)
+        VALUES ($1, $2)`
+
+    for _, u := range urls {
+        key := KeyFromURL(u)
+        if key == "" {
+            continue
+        }
+
+        if _, err := tx.Exec(query, key, time.Now().UTC()); err != nil {
+            return fmt.Errorf("error queueing blob deletion: %v", err)
+        }
+    }
+
+    return nil
+}
+
+// ImageURLs returns the original and every rendition URL of a stored image.
+func ImageURLs(url string, variantsJSON []byte) ([]string, error) {
+    urls := []string{url}
+
+    if len(variantsJSON) == 0 {
+        return urls, nil
+    }
+
+    var variants map[string]models.ImageVariant
+    if err := json.Unmarshal(variantsJSON, &variants); err != nil {
+        return nil, fmt.Errorf("error parsing image variants: %v", err)
+    }
+
+    for _, variant := range variants {
+        urls = append(urls, variant.URL, variant.WebPURL)
+    }
+
+    return urls, nil
+}
+
+// KeyFromURL turns a stored object URL into its bucket key. Values that are
+// already keys are returned unchanged.
+func KeyFromURL(u string) string {
+    u = strings.TrimSpace(u)
+    if u == "" {
+        return ""
+    }
+
+    parsed, err := url.Parse(u)
+    if err != nil || parsed.Host == "" {
+        return strings.TrimPrefix(u, "/")
+    }
+
+    return strings.TrimPrefix(parsed.Path, "/")
+}
+
+// contentKey returns the blob key an object was stored under: the object key
+// without its rendition suffix and extension.
+func contentKey(objectKey string) string {
+    dir, name := path.Split(objectKey)
+    if i := strings.IndexAny(name, "_."); i >= 0 {
+        name = name[:i]
+    }
+    return dir + name
+}
+
+func (r *Repository) ProcessPending(limit, maxAttempts int, fn func(entry OutboxEntry) error) (int, error) {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return 0, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    query := `
+        SELECT id, object_key, attempts, COALESCE(last_error, ''), created_at
+        FROM blob_outbox
+        WHERE attempts < $2
+        ORDER BY id
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED`
+
+    rows, err := tx.Query(query, limit, maxAttempts)
+    if err != nil {
+        return 0, fmt.Errorf("error querying blob outbox: %v", err)
+    }
+
+    var entries []OutboxEntry
+    for rows.Next() {
+        var entry OutboxEntry
+        if err := rows.Scan(&entry.ID, &entry.ObjectKey, &entry.Attempts, &entry.LastError, &entry.CreatedAt); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("error scanning blob outbox entry: %v", err)
+        }
+        entries = append(entries, entry)
+    }
+    rows.Close()
+
+    processed := 0
+    for _, entry := range entries {
+        // Uploads hold the content's lock while they reuse or rewrite its
+        // objects; leave the entry for the next run while one is in progress
+        key := contentKey(entry.ObjectKey)
+        var locked bool
+        err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock(hashtext($1))`, key).Scan(&locked)
+        if err != nil {
+            return 0, fmt.Errorf("error locking blob: %v", err)
+        }
+        if !locked {
+            continue
+        }
+
+        // The same content may have been uploaded again since it was queued
+        var reused bool
+        err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM blob WHERE key = $1)`, key).Scan(&reused)
+        if err != nil {
+            return 0, fmt.Errorf("error checking blob reuse: %v", err)
+        }
+
+        if reused {
+            if _, err := tx.Exec(`DELETE FROM blob_outbox WHERE id = $1`, entry.ID); err != nil {
+                return 0, fmt.Errorf("error removing blob outbox entry: %v", err)
+            }
+            continue
+        }
+
+        if err := fn(entry); err != nil {
+            _, err = tx.Exec(`
+                UPDATE blob_outbox
+                SET attempts = attempts + 1, last_error = $2
+                WHERE id = $1`, entry.ID, err.Error())
+            if err != nil {
+                return 0, fmt.Errorf("error recording blob deletion failure: %v", err)
+            }
+            continue
+        }
+
+        if _, err := tx.Exec(`DELETE FROM blob_outbox WHERE id = $1`, entry.ID); err != nil {
+            return 0, fmt.Errorf("error removing blob outbox entry: %v", err)
+        }
+        processed++
+    }
+
+    if err := tx.Commit(); err != nil {
+        return 0, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return processed, nil
+}
+
+func (r *Repository) GetReferencedKeys() (map[string]bool, error) {
+    query := `
+        SELECT url FROM blob
+        UNION
+        SELECT v.value->>'url' FROM blob, jsonb_each(variants) v
+        UNION
+        SELECT v.value->>'webpUrl' FROM blob, jsonb_each(variants) v
+        UNION
+        SELECT url FROM item_image
+        UNION
+        SELECT v.value->>'url' FROM item_image, jsonb_each(variants) v
+        UNION
+        SELECT v.value->>'webpUrl' FROM item_image, jsonb_each(variants) v
+        UNION
+        SELECT url FROM container_image
+        UNION
+        SELECT v.value->>'url' FROM container_image, jsonb_each(variants) v
+        UNION
+        SELECT v.value->>'webpUrl' FROM container_image, jsonb_each(variants) v
+        UNION
+        SELECT url FROM item_receipt
+        UNION
+        SELECT image_url FROM users
+        UNION
+        SELECT object_key FROM blob_outbox`
+
+    rows, err := r.db.Query(query)
+    if err != nil {
+        return nil, fmt.Errorf("error querying referenced blobs: %v", err)
+    }
+    defer rows.Close()
+
+    keys := make(map[string]bool)
+    for rows.Next() {
+        var ref sql.NullString
+        if err := rows.Scan(&ref); err != nil {
+            return nil, fmt.Errorf("error scanning referenced blob: %v", err)
+        }
+        if key := KeyFromURL(ref.String); key != "" {
+            keys[key] = true
+        }
+    }
+
+    return keys, nil
+}
\ No newline at end of file
@@ -0,0 +1,102 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	outboxBatchSize   = 100
+	outboxMaxAttempts = 10
+	outboxInterval    = 30 * time.Second
+
+	sweepInterval = 24 * time.Hour
+	// Objects younger than this may belong to an upload whose row is not
+	// committed yet, so the sweeper leaves them alone.
+	sweepGracePeriod = 24 * time.Hour
+)
+
+// SweepPrefixes lists the bucket prefixes owned by the API.
+var SweepPrefixes = []string{"images/", "avatars/", "items/", "users/", "receipts/"}
+
+type ObjectStore interface {
+	DeleteObject(ctx context.Context, key string) error
+	ListObjects(ctx context.Context, prefix string, fn func(key string, lastModified time.Time) error) error
+}
+
+type Service struct {
+	repo  *Repository
+	store ObjectStore
+}
+
+func NewService(repo *Repository, store ObjectStore) *Service {
+	return &Service{
+		repo:  repo,
+		store: store,
+	}
+}
+
+func (s *Service) ProcessOutbox(ctx context.Context) (int, error) {
+	return s.repo.ProcessPending(outboxBatchSize, outboxMaxAttempts, func(entry OutboxEntry) error {
+		return s.store.DeleteObject(ctx, entry.ObjectKey)
+	})
+}
+
+func (s *Service) SweepOrphans(ctx context.Context) (*SweepResult, error) {
+	referenced, err := s.repo.GetReferencedKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SweepResult{}
+	cutoff := time.Now().Add(-sweepGracePeriod)
+
+	for _, prefix := range SweepPrefixes {
+		err := s.store.ListObjects(ctx, prefix, func(key string, lastModified time.Time) error {
+			result.Scanned++
+			if referenced[key] || lastModified.After(cutoff) {
+				return nil
+			}
+
+			if err := s.store.DeleteObject(ctx, key); err != nil {
+				return err
+			}
+			result.Deleted++
+			return nil
+		})
+		if err != nil {
+			return result, fmt.Errorf("error sweeping %s: %v", prefix, err)
+		}
+	}
+
+	return result, nil
+}
+
+// Run drains the outbox and sweeps orphans periodically until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	outboxTicker := time.NewTicker(outboxInterval)
+	defer outboxTicker.Stop()
+
+	sweepTicker := time.NewTicker(sweepInterval)
+	defer sweepTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-outboxTicker.C:
+			if _, err := s.ProcessOutbox(ctx); err != nil {
+				log.Printf("blob outbox processing failed: %v", err)
+			}
+		case <-sweepTicker.C:
+			result, err := s.SweepOrphans(ctx)
+			if err != nil {
+				log.Printf("orphan blob sweep failed: %v", err)
+				continue
+			}
+			log.Printf("orphan blob sweep: scanned %d, deleted %d", result.Scanned, result.Deleted)
+		}
+	}
+}
\ No newline at end of file
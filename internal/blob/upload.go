@@ -0,0 +1,68 @@
+package blob
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
+)
+
+// Storer writes uploads to the bucket.
+type Storer interface {
+    Store(upload *storage.Upload) (*models.ItemImage, int64, error)
+    StorageQuotaBytes() int64
+}
+
+// Acquire takes a reference on the upload's content within tx and returns
+// the stored image for the caller to record. Content already registered is
+// shared at no cost; new content is charged to userID's quota and only then
+// written, so an upload over quota stores nothing. The content key stays
+// locked until tx ends, keeping the outbox away from objects being reused or
+// rewritten. Objects written for a transaction that rolls back are reclaimed
+// by the orphan sweeper.
+func Acquire(tx *sql.Tx, store Storer, userID int, upload *storage.Upload) (*models.ItemImage, error) {
+    if err := lock(tx, upload.Key); err != nil {
+        return nil, err
+    }
+
+    image, err := claim(tx, upload.Key)
+    if err != nil || image != nil {
+        return image, err
+    }
+
+    storageBytes, err := upload.Process()
+    if err != nil {
+        return nil, err
+    }
+
+    if err := ReserveStorage(tx, userID, storageBytes, store.StorageQuotaBytes()); err != nil {
+        return nil, err
+    }
+
+    // Deletions queued when this content was last released would remove the
+    // objects about to be written
+    _, err = tx.Exec(`DELETE FROM blob_outbox WHERE left(object_key, length($1)) = $1`, upload.Key)
+    if err != nil {
+        return nil, fmt.Errorf("error cancelling blob deletion: %v", err)
+    }
+
+    image, _, err = store.Store(upload)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := Register(tx, upload.Key, image, storageBytes, userID); err != nil {
+        return nil, err
+    }
+
+    return image, nil
+}
+
+// lock serialises work on the content stored under key until tx ends.
+func lock(tx *sql.Tx, key string) error {
+    if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+        return fmt.Errorf("error locking blob: %v", err)
+    }
+    return nil
+}
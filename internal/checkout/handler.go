@@ -0,0 +1,199 @@
+package checkout
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chrisabs/storage/internal/container"
+	"github.com/chrisabs/storage/internal/item"
+	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+    service          *Service
+    containerService *container.Service
+    itemService      *item.Service
+    authMiddleware   *middleware.AuthMiddleware
+}
+
+func NewHandler(service *Service, containerService *container.Service, itemService *item.Service, authMiddleware *middleware.AuthMiddleware) *Handler {
+    return &Handler{
+        service:          service,
+        containerService: containerService,
+        itemService:      itemService,
+        authMiddleware:   authMiddleware,
+    }
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+    router.HandleFunc("/checkouts", h.authMiddleware.AuthHandler(h.handleGetOpen)).Methods("GET")
+
+    router.HandleFunc("/containers/{id}/checkout", h.authMiddleware.AuthHandler(h.handleCheckOut(EntityContainer))).Methods("POST")
+    router.HandleFunc("/containers/{id}/checkin", h.authMiddleware.AuthHandler(h.handleCheckIn(EntityContainer))).Methods("POST")
+    router.HandleFunc("/containers/{id}/checkouts", h.authMiddleware.AuthHandler(h.handleGetHistory(EntityContainer))).Methods("GET")
+
+    router.HandleFunc("/items/{id}/checkout", h.authMiddleware.AuthHandler(h.handleCheckOut(EntityItem))).Methods("POST")
+    router.HandleFunc("/items/{id}/checkin", h.authMiddleware.AuthHandler(h.handleCheckIn(EntityItem))).Methods("POST")
+    router.HandleFunc("/items/{id}/checkouts", h.authMiddleware.AuthHandler(h.handleGetHistory(EntityItem))).Methods("GET")
+}
+
+// handleGetOpen lists open checkouts; ?overdue=true keeps only those past
+// their expected return.
+func (h *Handler) handleGetOpen(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    overdueOnly := false
+    if value := r.URL.Query().Get("overdue"); value != "" {
+        overdueOnly, err = strconv.ParseBool(value)
+        if err != nil {
+            writeError(w, http.StatusBadRequest, "invalid overdue")
+            return
+        }
+    }
+
+    checkouts, err := h.service.GetOpen(userID, overdueOnly)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, checkouts)
+}
+
+func (h *Handler) handleCheckOut(entity string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        userID, entityID, ok := h.authorize(w, r, entity)
+        if !ok {
+            return
+        }
+
+        var req CheckoutRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            writeError(w, http.StatusBadRequest, "invalid request body")
+            return
+        }
+
+        checkout, err := h.service.CheckOut(userID, entity, entityID, &req)
+        if err != nil {
+            writeError(w, errorStatus(err), err.Error())
+            return
+        }
+        writeJSON(w, http.StatusCreated, checkout)
+    }
+}
+
+func (h *Handler) handleCheckIn(entity string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        _, entityID, ok := h.authorize(w, r, entity)
+        if !ok {
+            return
+        }
+
+        // The body is optional
+        var req CheckinRequest
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                writeError(w, http.StatusBadRequest, "invalid request body")
+                return
+            }
+        }
+
+        checkout, err := h.service.CheckIn(entity, entityID, &req)
+        if err != nil {
+            writeError(w, errorStatus(err), err.Error())
+            return
+        }
+        writeJSON(w, http.StatusOK, checkout)
+    }
+}
+
+func (h *Handler) handleGetHistory(entity string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        _, entityID, ok := h.authorize(w, r, entity)
+        if !ok {
+            return
+        }
+
+        checkouts, err := h.service.GetHistory(entity, entityID)
+        if err != nil {
+            writeError(w, http.StatusInternalServerError, err.Error())
+            return
+        }
+        writeJSON(w, http.StatusOK, checkouts)
+    }
+}
+
+// authorize checks the caller owns the container or item in the URL and
+// writes the error response when they do not. Items belong to someone only
+// through their container, so unassigned items cannot be checked out.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, entity string) (int, int, bool) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return 0, 0, false
+    }
+
+    entityID, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid "+entity+" ID")
+        return 0, 0, false
+    }
+
+    containerID := entityID
+    if entity == EntityItem {
+        i, err := h.itemService.GetItemByID(entityID)
+        if err != nil {
+            writeError(w, http.StatusNotFound, "item not found")
+            return 0, 0, false
+        }
+        if i.ContainerID == nil {
+            writeError(w, http.StatusForbidden, "access denied")
+            return 0, 0, false
+        }
+        containerID = *i.ContainerID
+    }
+
+    c, err := h.containerService.GetContainerByID(containerID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "container not found")
+        return 0, 0, false
+    }
+
+    if c.UserID != userID {
+        writeError(w, http.StatusForbidden, "access denied")
+        return 0, 0, false
+    }
+
+    return userID, entityID, true
+}
+
+func errorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, ErrAlreadyCheckedOut), errors.Is(err, ErrNotCheckedOut),
+        errors.Is(err, ErrContainerRetired):
+        return http.StatusConflict
+    case errors.Is(err, ErrInvalidHolder), errors.Is(err, ErrNotesTooLong),
+        errors.Is(err, ErrInvalidReturnDate):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,36 @@
+package checkout
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+    EntityContainer = "container"
+    EntityItem      = "item"
+)
+
+const (
+    maxHolderLength = 100
+    maxNotesLength  = 1000
+)
+
+var (
+    ErrInvalidHolder     = errors.New("holder must be between 1 and 100 characters")
+    ErrNotesTooLong      = errors.New("notes must be at most 1000 characters")
+    ErrInvalidReturnDate = errors.New("expectedReturnAt must be in the future")
+    ErrAlreadyCheckedOut = errors.New("already checked out; check it in first")
+    ErrNotCheckedOut     = errors.New("not checked out")
+    ErrNotFound          = errors.New("container or item not found")
+    ErrContainerRetired  = errors.New("container has been merged into another and is retired")
+)
+
+type CheckoutRequest struct {
+    Holder           string     `json:"holder"`
+    ExpectedReturnAt *time.Time `json:"expectedReturnAt,omitempty"`
+    Notes            string     `json:"notes"`
+}
+
+type CheckinRequest struct {
+    Notes string `json:"notes"`
+}
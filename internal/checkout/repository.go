@@ -0,0 +1,188 @@
+package checkout
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+// entityColumns maps an entity type to its column on checkout and its table.
+// Both are fixed strings, never taken from the request.
+var entityColumns = map[string]struct{ column, table string }{
+    EntityContainer: {"container_id", "container"},
+    EntityItem:      {"item_id", "item"},
+}
+
+const checkoutColumns = `
+    co.id, co.container_id, co.item_id, COALESCE(c.name, i.name, ''), co.user_id,
+    co.holder, co.notes, co.checked_out_at, co.expected_return_at,
+    co.checked_in_at, co.return_notes,
+    co.checked_in_at IS NULL AND COALESCE(co.expected_return_at < NOW(), FALSE)`
+
+const checkoutJoins = `
+    FROM checkout co
+    LEFT JOIN container c ON co.container_id = c.id
+    LEFT JOIN item i ON co.item_id = i.id`
+
+type scanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanCheckout(row scanner) (*models.Checkout, error) {
+    checkout := new(models.Checkout)
+    err := row.Scan(
+        &checkout.ID, &checkout.ContainerID, &checkout.ItemID, &checkout.Name, &checkout.UserID,
+        &checkout.Holder, &checkout.Notes, &checkout.CheckedOutAt, &checkout.ExpectedReturnAt,
+        &checkout.CheckedInAt, &checkout.ReturnNotes,
+        &checkout.Overdue,
+    )
+    if err != nil {
+        return nil, err
+    }
+    return checkout, nil
+}
+
+// Create opens a checkout, failing when the entity is already out. The
+// entity row is locked so two concurrent checkouts cannot both succeed.
+func (r *Repository) Create(userID int, entity string, entityID int, req *CheckoutRequest) (*models.Checkout, error) {
+    cols := entityColumns[entity]
+
+    tx, err := r.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var lockQuery string
+    if entity == EntityContainer {
+        lockQuery = `SELECT retired_at IS NOT NULL FROM container WHERE id = $1 FOR UPDATE`
+    } else {
+        lockQuery = `SELECT FALSE FROM item WHERE id = $1 FOR UPDATE`
+    }
+
+    var retired bool
+    err = tx.QueryRow(lockQuery, entityID).Scan(&retired)
+    if err == sql.ErrNoRows {
+        return nil, ErrNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error locking %s: %v", cols.table, err)
+    }
+    if retired {
+        return nil, ErrContainerRetired
+    }
+
+    var open bool
+    openQuery := `SELECT EXISTS (SELECT 1 FROM checkout WHERE ` + cols.column + ` = $1 AND checked_in_at IS NULL)`
+    if err := tx.QueryRow(openQuery, entityID).Scan(&open); err != nil {
+        return nil, fmt.Errorf("error checking open checkout: %v", err)
+    }
+    if open {
+        return nil, ErrAlreadyCheckedOut
+    }
+
+    var id int
+    insertQuery := `
+        INSERT INTO checkout (user_id, ` + cols.column + `, holder, notes, checked_out_at, expected_return_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id`
+
+    err = tx.QueryRow(
+        insertQuery, userID, entityID, req.Holder, req.Notes, time.Now().UTC(), req.ExpectedReturnAt,
+    ).Scan(&id)
+    if err != nil {
+        return nil, fmt.Errorf("error creating checkout: %v", err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return r.GetByID(id)
+}
+
+// CheckIn closes the entity's open checkout.
+func (r *Repository) CheckIn(entity string, entityID int, notes string) (*models.Checkout, error) {
+    cols := entityColumns[entity]
+
+    var id int
+    query := `
+        UPDATE checkout
+        SET checked_in_at = $2, return_notes = $3
+        WHERE ` + cols.column + ` = $1 AND checked_in_at IS NULL
+        RETURNING id`
+
+    err := r.db.QueryRow(query, entityID, time.Now().UTC(), notes).Scan(&id)
+    if err == sql.ErrNoRows {
+        return nil, ErrNotCheckedOut
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error checking in: %v", err)
+    }
+
+    return r.GetByID(id)
+}
+
+func (r *Repository) GetByID(id int) (*models.Checkout, error) {
+    query := `SELECT ` + checkoutColumns + checkoutJoins + ` WHERE co.id = $1`
+
+    checkout, err := scanCheckout(r.db.QueryRow(query, id))
+    if err == sql.ErrNoRows {
+        return nil, ErrNotCheckedOut
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error getting checkout: %v", err)
+    }
+    return checkout, nil
+}
+
+// GetHistory lists every checkout of the entity, newest first.
+func (r *Repository) GetHistory(entity string, entityID int) ([]*models.Checkout, error) {
+    cols := entityColumns[entity]
+
+    query := `SELECT ` + checkoutColumns + checkoutJoins + `
+        WHERE co.` + cols.column + ` = $1
+        ORDER BY co.checked_out_at DESC, co.id DESC`
+
+    return r.list(query, entityID)
+}
+
+// GetOpen lists the user's open checkouts, those due back soonest first.
+// With overdueOnly it keeps just the ones past their expected return.
+func (r *Repository) GetOpen(userID int, overdueOnly bool) ([]*models.Checkout, error) {
+    query := `SELECT ` + checkoutColumns + checkoutJoins + `
+        WHERE co.user_id = $1 AND co.checked_in_at IS NULL
+          AND (NOT $2 OR co.expected_return_at < NOW())
+        ORDER BY co.expected_return_at ASC NULLS LAST, co.checked_out_at ASC`
+
+    return r.list(query, userID, overdueOnly)
+}
+
+func (r *Repository) list(query string, args ...interface{}) ([]*models.Checkout, error) {
+    rows, err := r.db.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("error querying checkouts: %v", err)
+    }
+    defer rows.Close()
+
+    checkouts := []*models.Checkout{}
+    for rows.Next() {
+        checkout, err := scanCheckout(rows)
+        if err != nil {
+            return nil, fmt.Errorf("error scanning checkout: %v", err)
+        }
+        checkouts = append(checkouts, checkout)
+    }
+
+    return checkouts, rows.Err()
+}
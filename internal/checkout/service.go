@@ -0,0 +1,51 @@
+package checkout
+
+import (
+	"strings"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Service struct {
+    repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+    return &Service{repo: repo}
+}
+
+// CheckOut hands a container or item to someone until it is checked in.
+func (s *Service) CheckOut(userID int, entity string, entityID int, req *CheckoutRequest) (*models.Checkout, error) {
+    req.Holder = strings.TrimSpace(req.Holder)
+    if req.Holder == "" || len(req.Holder) > maxHolderLength {
+        return nil, ErrInvalidHolder
+    }
+    req.Notes = strings.TrimSpace(req.Notes)
+    if len(req.Notes) > maxNotesLength {
+        return nil, ErrNotesTooLong
+    }
+    if req.ExpectedReturnAt != nil && !req.ExpectedReturnAt.After(time.Now()) {
+        return nil, ErrInvalidReturnDate
+    }
+
+    return s.repo.Create(userID, entity, entityID, req)
+}
+
+func (s *Service) CheckIn(entity string, entityID int, req *CheckinRequest) (*models.Checkout, error) {
+    notes := strings.TrimSpace(req.Notes)
+    if len(notes) > maxNotesLength {
+        return nil, ErrNotesTooLong
+    }
+
+    return s.repo.CheckIn(entity, entityID, notes)
+}
+
+func (s *Service) GetHistory(entity string, entityID int) ([]*models.Checkout, error) {
+    return s.repo.GetHistory(entity, entityID)
+}
+
+// GetOpen lists what the user has out, optionally only what is overdue.
+func (s *Service) GetOpen(userID int, overdueOnly bool) ([]*models.Checkout, error) {
+    return s.repo.GetOpen(userID, overdueOnly)
+}
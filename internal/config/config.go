@@ -2,7 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,8 +19,30 @@ type Config struct {
     AWSSecretAccessKey string
     AWSRegion         string
     S3Bucket          string
+    MaxUploadBytes    int64
+    StorageQuotaBytes int64
+    ImageURLTTL       time.Duration
+    ContainerPrefix   string
+    QRBaseURL         string
+    ShareBaseURL      string
+    BaseCurrency      string
+    // ExchangeRates maps a currency code to what one unit of it is worth in
+    // BaseCurrency; the base currency itself is always 1
+    ExchangeRates     map[string]float64
 }
 
+const (
+    defaultMaxUploadBytes    = 10 << 20
+    defaultStorageQuotaBytes = 1 << 30
+    defaultImageURLTTL       = 15 * time.Minute
+    defaultContainerPrefix   = "BOX"
+    maxContainerPrefixLength = 10
+    defaultQRBaseURL         = "stqrage://container"
+    defaultBaseCurrency      = "USD"
+)
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
 func LoadConfig() (*Config, error) {
     err := godotenv.Load()
     if err != nil && !os.IsNotExist(err) {
@@ -47,11 +74,131 @@ func LoadConfig() (*Config, error) {
         return nil, fmt.Errorf("S3_BUCKET environment variable is required")
     }
 
+    maxUploadBytes, err := getEnvInt64("MAX_UPLOAD_BYTES", defaultMaxUploadBytes)
+    if err != nil {
+        return nil, err
+    }
+
+    storageQuotaBytes, err := getEnvInt64("STORAGE_QUOTA_BYTES", defaultStorageQuotaBytes)
+    if err != nil {
+        return nil, err
+    }
+
+    imageURLTTL, err := getEnvDuration("IMAGE_URL_TTL", defaultImageURLTTL)
+    if err != nil {
+        return nil, err
+    }
+
+    // Prefix for new container labels such as BOX-0042. Labels already
+    // printed keep the prefix they were created with.
+    containerPrefix := os.Getenv("CONTAINER_LABEL_PREFIX")
+    if containerPrefix == "" {
+        containerPrefix = defaultContainerPrefix
+    }
+    if len(containerPrefix) > maxContainerPrefixLength {
+        return nil, fmt.Errorf("CONTAINER_LABEL_PREFIX must be at most %d characters", maxContainerPrefixLength)
+    }
+
+    // Labels encode <QR_BASE_URL>/<token> so a phone camera can open them
+    qrBaseURL := os.Getenv("QR_BASE_URL")
+    if qrBaseURL == "" {
+        qrBaseURL = defaultQRBaseURL
+    }
+    if parsed, err := url.Parse(qrBaseURL); err != nil || parsed.Scheme == "" {
+        return nil, fmt.Errorf("QR_BASE_URL must be an absolute URL")
+    }
+
+    // Share links are <SHARE_BASE_URL>/<token> when set, typically the web
+    // app's public page; otherwise only the token is handed out
+    shareBaseURL := os.Getenv("SHARE_BASE_URL")
+    if shareBaseURL != "" {
+        if parsed, err := url.Parse(shareBaseURL); err != nil || parsed.Scheme == "" {
+            return nil, fmt.Errorf("SHARE_BASE_URL must be an absolute URL")
+        }
+    }
+
+    // Valuations are reported in BASE_CURRENCY. EXCHANGE_RATES lists what
+    // other currencies are worth in it, such as EUR=1.08,GBP=1.27
+    baseCurrency := strings.ToUpper(os.Getenv("BASE_CURRENCY"))
+    if baseCurrency == "" {
+        baseCurrency = defaultBaseCurrency
+    }
+    if !currencyCodePattern.MatchString(baseCurrency) {
+        return nil, fmt.Errorf("BASE_CURRENCY must be a three-letter currency code")
+    }
+
+    exchangeRates, err := parseExchangeRates(os.Getenv("EXCHANGE_RATES"), baseCurrency)
+    if err != nil {
+        return nil, err
+    }
+
     return &Config{
         JWTSecret:         jwtSecret,
         AWSAccessKeyID:    awsAccessKey,
         AWSSecretAccessKey: awsSecretKey,
         AWSRegion:         awsRegion,
         S3Bucket:          s3Bucket,
+        MaxUploadBytes:    maxUploadBytes,
+        StorageQuotaBytes: storageQuotaBytes,
+        ImageURLTTL:       imageURLTTL,
+        ContainerPrefix:   containerPrefix,
+        QRBaseURL:         qrBaseURL,
+        ShareBaseURL:      shareBaseURL,
+        BaseCurrency:      baseCurrency,
+        ExchangeRates:     exchangeRates,
     }, nil
+}
+
+func parseExchangeRates(value, baseCurrency string) (map[string]float64, error) {
+    rates := map[string]float64{baseCurrency: 1}
+    if strings.TrimSpace(value) == "" {
+        return rates, nil
+    }
+
+    for _, pair := range strings.Split(value, ",") {
+        code, rate, ok := strings.Cut(strings.TrimSpace(pair), "=")
+        code = strings.ToUpper(strings.TrimSpace(code))
+        if !ok || !currencyCodePattern.MatchString(code) {
+            return nil, fmt.Errorf("EXCHANGE_RATES must look like EUR=1.08,GBP=1.27")
+        }
+
+        parsed, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+        if err != nil || parsed <= 0 {
+            return nil, fmt.Errorf("EXCHANGE_RATES rate for %s must be a positive number", code)
+        }
+        if code == baseCurrency && parsed != 1 {
+            return nil, fmt.Errorf("EXCHANGE_RATES rate for the base currency %s must be 1", code)
+        }
+        rates[code] = parsed
+    }
+
+    return rates, nil
+}
+
+func getEnvInt64(key string, fallback int64) (int64, error) {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback, nil
+    }
+
+    parsed, err := strconv.ParseInt(value, 10, 64)
+    if err != nil || parsed <= 0 {
+        return 0, fmt.Errorf("%s must be a positive integer", key)
+    }
+
+    return parsed, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+    value := os.Getenv(key)
+    if value == "" {
+        return fallback, nil
+    }
+
+    parsed, err := time.ParseDuration(value)
+    if err != nil || parsed <= 0 {
+        return 0, fmt.Errorf("%s must be a positive duration such as 15m", key)
+    }
+
+    return parsed, nil
 }
\ No newline at end of file
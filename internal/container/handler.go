@@ -1,12 +1,19 @@
 package container
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/chrisabs/storage/internal/label"
 	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
 	"github.com/gorilla/mux"
 )
 
@@ -25,9 +32,24 @@ func NewHandler(service *Service, authMiddleware *middleware.AuthMiddleware) *Ha
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/containers", h.authMiddleware.AuthHandler(h.handleGetContainers)).Methods("GET")
 	router.HandleFunc("/containers", h.authMiddleware.AuthHandler(h.handleCreateContainer)).Methods("POST")
+	router.HandleFunc("/containers/labels", h.authMiddleware.AuthHandler(h.handleLabelSheet)).Methods("POST")
+	router.HandleFunc("/containers/labels/templates", h.authMiddleware.AuthHandler(h.handleGetLabelTemplates)).Methods("GET")
+	router.HandleFunc("/containers/free-space", h.authMiddleware.AuthHandler(h.handleGetFreeSpace)).Methods("GET")
 	router.HandleFunc("/containers/{id}", h.authMiddleware.AuthHandler(h.handleGetContainerByID)).Methods("GET")
 	router.HandleFunc("/containers/{id}", h.authMiddleware.AuthHandler(h.handleDeleteContainer)).Methods("DELETE")
 	router.HandleFunc("/containers/{id}", h.authMiddleware.AuthHandler(h.handleUpdateContainer)).Methods("PUT")
+	router.HandleFunc("/containers/{id}/move", h.authMiddleware.AuthHandler(h.handleMoveContainer)).Methods("POST")
+	router.HandleFunc("/containers/{id}/merge", h.authMiddleware.AuthHandler(h.handleMergeContainers)).Methods("POST")
+	router.HandleFunc("/containers/{id}/split", h.authMiddleware.AuthHandler(h.handleSplitContainer)).Methods("POST")
+	router.HandleFunc("/containers/{id}/history", h.authMiddleware.AuthHandler(h.handleGetHistory)).Methods("GET")
+	router.HandleFunc("/containers/{id}/images", h.authMiddleware.AuthHandler(h.handleUploadImages)).Methods("POST")
+	router.HandleFunc("/containers/{id}/images/order", h.authMiddleware.AuthHandler(h.handleReorderImages)).Methods("PUT")
+	router.HandleFunc("/containers/{id}/images/{imageId}", h.authMiddleware.AuthHandler(h.handleDeleteImage)).Methods("DELETE")
+	router.HandleFunc("/containers/{id}/cover", h.authMiddleware.AuthHandler(h.handleSetCover)).Methods("PUT")
+	router.HandleFunc("/containers/{id}/qr", h.authMiddleware.AuthHandler(h.handleGetQRCode)).Methods("GET")
+	router.HandleFunc("/containers/{id}/code", h.authMiddleware.AuthHandler(h.handleGetCode)).Methods("GET")
+	router.HandleFunc("/containers/{id}/ndef", h.authMiddleware.AuthHandler(h.handleGetNDEF)).Methods("GET")
+	router.HandleFunc("/containers/{id}/qr/rotate", h.authMiddleware.AuthHandler(h.handleRotateQRCode)).Methods("POST")
 	router.HandleFunc("/containers/qr/{qrcode}", h.authMiddleware.AuthHandler(h.handleGetContainerByQR)).Methods("GET")
 }
 
@@ -61,7 +83,7 @@ func (h *Handler) handleCreateContainer(w http.ResponseWriter, r *http.Request)
 
 	container, err := h.service.CreateContainer(userID, &req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, hierarchyErrorStatus(err), err.Error())
 		return
 	}
 	writeJSON(w, http.StatusCreated, container)
@@ -126,12 +148,521 @@ func (h *Handler) handleUpdateContainer(w http.ResponseWriter, r *http.Request)
 
     updatedContainer, err := h.service.UpdateContainer(containerID, &req)
     if err != nil {
-        writeError(w, http.StatusInternalServerError, err.Error())
+        writeError(w, hierarchyErrorStatus(err), err.Error())
         return
     }
     writeJSON(w, http.StatusOK, updatedContainer)
 }
 
+func (h *Handler) handleMoveContainer(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	container, err := h.service.GetContainerByID(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if container.UserID != userID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	var req MoveContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	movedContainer, err := h.service.MoveContainer(containerID, &req)
+	if err != nil {
+		writeError(w, hierarchyErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, movedContainer)
+}
+
+// handleGetFreeSpace lists containers in ?workspaceId= with at least
+// ?minFree= percent of their capacity left. Containers without a capacity
+// are never listed.
+func (h *Handler) handleGetFreeSpace(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	query := r.URL.Query()
+	workspaceID, err := strconv.Atoi(query.Get("workspaceId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "workspaceId is required")
+		return
+	}
+
+	minFree := 0.0
+	if value := query.Get("minFree"); value != "" {
+		minFree, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrInvalidFreeSpace.Error())
+			return
+		}
+	}
+
+	containers, err := h.service.GetContainersWithFreeSpace(userID, workspaceID, minFree)
+	if err != nil {
+		writeError(w, hierarchyErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, containers)
+}
+
+// handleMergeContainers empties the containers listed in the body into the
+// one in the URL. Every source must belong to the caller as well.
+func (h *Handler) handleMergeContainers(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	container, err := h.service.GetContainerByID(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if container.UserID != userID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	var req MergeContainersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	merged, err := h.service.MergeContainers(userID, containerID, &req)
+	if err != nil {
+		writeError(w, hierarchyErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, merged)
+}
+
+func (h *Handler) handleSplitContainer(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	container, err := h.service.GetContainerByID(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if container.UserID != userID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	var req SplitContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	created, err := h.service.SplitContainer(userID, containerID, &req)
+	if err != nil {
+		writeError(w, hierarchyErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	container, err := h.service.GetContainerByID(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if container.UserID != userID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	events, err := h.service.GetHistory(containerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// authorizeContainer loads the container in the URL and checks it belongs to
+// the caller, writing the error response when it does not.
+func (h *Handler) authorizeContainer(w http.ResponseWriter, r *http.Request) (*models.Container, bool) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return nil, false
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	container, err := h.service.GetContainerByID(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+
+	if container.UserID != userID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return nil, false
+	}
+
+	return container, true
+}
+
+// handleUploadImages adds the photos in the multipart "images" field, in the
+// order given, after any the container already has.
+func (h *Handler) handleUploadImages(w http.ResponseWriter, r *http.Request) {
+	container, ok := h.authorizeContainer(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse form: %v", err))
+		return
+	}
+
+	files := r.MultipartForm.File["images"]
+	if len(files) == 0 {
+		writeError(w, http.StatusBadRequest, "missing images")
+		return
+	}
+
+	s3Handler, err := storage.NewS3Handler()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, fileHeader := range files {
+		if err := h.service.UploadContainerImage(container.ID, container.UserID, s3Handler, fileHeader); err != nil {
+			writeError(w, imageErrorStatus(err), err.Error())
+			return
+		}
+	}
+
+	updated, err := h.service.GetContainerByID(container.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, updated)
+}
+
+func (h *Handler) handleReorderImages(w http.ResponseWriter, r *http.Request) {
+	container, ok := h.authorizeContainer(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReorderImagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	updated, err := h.service.ReorderContainerImages(container.ID, &req)
+	if err != nil {
+		writeError(w, imageErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) handleDeleteImage(w http.ResponseWriter, r *http.Request) {
+	container, ok := h.authorizeContainer(w, r)
+	if !ok {
+		return
+	}
+
+	imageID, err := strconv.Atoi(mux.Vars(r)["imageId"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid image ID")
+		return
+	}
+
+	updated, err := h.service.DeleteContainerImage(container.ID, imageID)
+	if err != nil {
+		writeError(w, imageErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) handleSetCover(w http.ResponseWriter, r *http.Request) {
+	container, ok := h.authorizeContainer(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetCoverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	updated, err := h.service.SetCoverImage(container.ID, &req)
+	if err != nil {
+		writeError(w, imageErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) handleGetQRCode(w http.ResponseWriter, r *http.Request) {
+	h.serveCode(w, r, label.SymbologyQR)
+}
+
+// handleGetCode renders the container in its own symbology unless the
+// request asks for another.
+func (h *Handler) handleGetCode(w http.ResponseWriter, r *http.Request) {
+	h.serveCode(w, r, "")
+}
+
+// serveCode renders the label code for a container, in symbology when given.
+// The image only changes with the token, the request parameters and the
+// caption text, so clients revalidate with the ETag instead of downloading
+// it again.
+func (h *Handler) serveCode(w http.ResponseWriter, r *http.Request, symbology string) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	container, err := h.service.GetLabelContainer(userID, containerID)
+	if err != nil {
+		writeError(w, labelErrorStatus(err), err.Error())
+		return
+	}
+
+	format, opts, err := parseCodeOptions(r, container, symbology)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d|%s|%d|%s",
+		h.service.QRLink(container), opts.Symbology, format, opts.Size, opts.Level, opts.Margin, opts.Caption)))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	image, err := h.service.RenderCode(container, format, opts)
+	if err != nil {
+		writeError(w, codeErrorStatus(err), err.Error())
+		return
+	}
+
+	contentType := "image/png"
+	if format == "svg" {
+		contentType = "image/svg+xml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// parseCodeOptions reads symbology, format, size, level, margin and caption
+// from the query string. A fixed symbology wins over the parameter, which
+// wins over the container's own. Caption may be name, number or both.
+func parseCodeOptions(r *http.Request, container *models.Container, symbology string) (string, label.Options, error) {
+	query := r.URL.Query()
+	opts := label.DefaultOptions()
+
+	switch {
+	case symbology != "":
+		opts.Symbology = symbology
+	case query.Get("symbology") != "":
+		opts.Symbology = strings.ToLower(query.Get("symbology"))
+	case container.Symbology != "":
+		opts.Symbology = container.Symbology
+	}
+
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		return "", opts, fmt.Errorf("format must be png or svg")
+	}
+
+	if raw := query.Get("size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil {
+			return "", opts, label.ErrInvalidSize
+		}
+		opts.Size = size
+	}
+
+	if raw := query.Get("margin"); raw != "" {
+		margin, err := strconv.Atoi(raw)
+		if err != nil {
+			return "", opts, label.ErrInvalidMargin
+		}
+		opts.Margin = margin
+	}
+
+	if raw := query.Get("level"); raw != "" {
+		opts.Level = strings.ToUpper(raw)
+	}
+
+	number := container.Label
+	if number == "" {
+		number = strconv.Itoa(container.Number)
+	}
+
+	switch query.Get("caption") {
+	case "":
+	case "name":
+		opts.Caption = container.Name
+	case "number":
+		opts.Caption = number
+	case "both":
+		opts.Caption = number + " " + container.Name
+	default:
+		return "", opts, fmt.Errorf("caption must be name, number or both")
+	}
+
+	if err := opts.Validate(); err != nil {
+		return "", opts, err
+	}
+
+	return format, opts, nil
+}
+
+// handleGetNDEF returns the NDEF message to write to an NFC sticker. Tapping
+// the sticker opens the same deep link as the printed code.
+func (h *Handler) handleGetNDEF(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	container, err := h.service.GetContainerByID(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if container.UserID != userID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="container-%d.ndef"`, container.ID))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(h.service.NDEFMessage(container))
+}
+
+func (h *Handler) handleRotateQRCode(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	containerID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	container, err := h.service.GetContainerByID(containerID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if container.UserID != userID {
+		writeError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	rotatedContainer, err := h.service.RotateQRCode(containerID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rotatedContainer)
+}
+
 func (h *Handler) handleDeleteContainer(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(r.Header.Get("UserId"))
 	if err != nil {
@@ -191,6 +722,95 @@ func (h *Handler) handleGetContainerByQR(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, container)
 }
 
+func (h *Handler) handleLabelSheet(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	var req LabelSheetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	pdf, err := h.service.LabelSheet(userID, &req)
+	if err != nil {
+		writeError(w, labelErrorStatus(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="labels.pdf"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(pdf)
+}
+
+func (h *Handler) handleGetLabelTemplates(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, label.Templates())
+}
+
+func labelErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrContainerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrLabelSelection),
+		errors.Is(err, label.ErrUnknownSymbology),
+		errors.Is(err, label.ErrNoLabels),
+		errors.Is(err, label.ErrTooManyLabels),
+		errors.Is(err, label.ErrInvalidStart),
+		errors.Is(err, label.ErrUnknownTemplate),
+		errors.Is(err, label.ErrInvalidTemplate):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// codeErrorStatus reports options the code cannot be rendered with as 422;
+// anything else failing to encode is on the server.
+func codeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, label.ErrInvalidSize),
+		errors.Is(err, label.ErrInvalidMargin),
+		errors.Is(err, label.ErrInvalidLevel),
+		errors.Is(err, label.ErrUnknownSymbology):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func hierarchyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrContainerCycle), errors.Is(err, ErrContainerRetired):
+		return http.StatusConflict
+	case errors.Is(err, ErrContainerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrParentNotFound), errors.Is(err, ErrLocationNotFound),
+		errors.Is(err, label.ErrUnknownSymbology), errors.Is(err, ErrInvalidMerge),
+		errors.Is(err, ErrEmptySplit), errors.Is(err, ErrSplitItems),
+		errors.Is(err, ErrInvalidCapacity), errors.Is(err, ErrInvalidFreeSpace):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func imageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrImageNotFound), errors.Is(err, ErrContainerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTooManyImages), errors.Is(err, ErrContainerRetired):
+		return http.StatusConflict
+	case errors.Is(err, ErrImageOrder):
+		return http.StatusBadRequest
+	default:
+		return storage.UploadErrorStatus(err)
+	}
+}
+
 func getIDFromRequest(r *http.Request) (int, error) {
 	vars := mux.Vars(r)
 	return strconv.Atoi(vars["id"])
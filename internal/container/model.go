@@ -1,5 +1,57 @@
 package container
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chrisabs/storage/internal/label"
+	"github.com/chrisabs/storage/internal/models"
+)
+
+var (
+	ErrParentNotFound    = errors.New("parent container not found")
+	ErrContainerCycle    = errors.New("a container cannot be placed inside itself or one of its own children")
+	ErrLocationNotFound  = errors.New("location not found in the container's workspace")
+	ErrContainerNotFound = errors.New("container not found")
+	ErrLabelSelection    = errors.New("provide either containerIds or a workspaceId")
+	ErrContainerRetired  = errors.New("container has been merged into another and is retired")
+	ErrInvalidMerge      = errors.New("provide at least one source container other than the target")
+	ErrEmptySplit        = errors.New("select at least one item to split off")
+	ErrSplitItems        = errors.New("every item to split off must be in the container")
+	ErrInvalidCapacity   = errors.New("invalid capacity")
+	ErrInvalidFreeSpace  = errors.New("minFree must be a percentage between 0 and 100")
+	ErrImageNotFound     = errors.New("image not found on this container")
+	ErrTooManyImages     = errors.New("container already has the maximum number of images")
+	ErrImageOrder        = errors.New("imageIds must list every image on the container exactly once")
+)
+
+// MaxImages caps the photos kept per container.
+const MaxImages = 20
+
+// Capacity modes, see models.Capacity.
+const (
+	CapacityVolume = "volume"
+	CapacityWeight = "weight"
+	CapacityCount  = "count"
+)
+
+// Upper bounds matching the capacity column types.
+const (
+	maxDimensionCm = 100000
+	maxWeightKg    = 1000000
+	maxVolumeL     = 10000000
+)
+
+// History actions. Each merge or split records one event on every container
+// involved, pointing at the other side.
+const (
+	EventMergedFrom = "merged_from"
+	EventMergedInto = "merged_into"
+	EventSplitInto  = "split_into"
+	EventSplitFrom  = "split_from"
+)
+
 type CreateItemRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
@@ -9,17 +61,132 @@ type CreateItemRequest struct {
 }
 
 type CreateContainerRequest struct {
-    Name        string              `json:"name"`
-	Description string              `json:"description"`
-    Location    string              `json:"location"`
-    WorkspaceID *int                `json:"workspaceId,omitempty"`
-    Items       []CreateItemRequest `json:"items"`
+    Name              string              `json:"name"`
+	Description       string              `json:"description"`
+    Location          string              `json:"location"`
+    WorkspaceID       *int                `json:"workspaceId,omitempty"`
+    ParentContainerID *int                `json:"parentContainerId,omitempty"`
+    LocationID        *int                `json:"locationId,omitempty"`
+    Symbology         string              `json:"symbology,omitempty"`
+    Capacity          *models.Capacity    `json:"capacity,omitempty"`
+    Items             []CreateItemRequest `json:"items"`
 }
 
 type UpdateContainerRequest struct {
+    Name        string           `json:"name"`
+	Description string           `json:"description"`
+    Location    string           `json:"location"`
+    WorkspaceID *int             `json:"workspaceId,omitempty"`
+    LocationID  *int             `json:"locationId,omitempty"`
+    Symbology   string           `json:"symbology,omitempty"`
+    ItemIDs     []int            `json:"itemIds,omitempty"`
+    // Capacity is left alone when omitted; an empty object clears it
+    Capacity    *models.Capacity `json:"capacity,omitempty"`
+}
+
+type MoveContainerRequest struct {
+    ParentContainerID *int `json:"parentContainerId"`
+}
+
+// MergeContainersRequest empties the sources into the container in the URL.
+type MergeContainersRequest struct {
+    SourceIDs []int `json:"sourceIds"`
+}
+
+// SplitContainerRequest moves the chosen items into a new container next to
+// the one in the URL. Name and symbology default to the original's.
+type SplitContainerRequest struct {
+    ItemIDs     []int  `json:"itemIds"`
     Name        string `json:"name"`
-	Description string `json:"description"`
-    Location    string `json:"location"`
-    WorkspaceID *int   `json:"workspaceId,omitempty"`
-    ItemIDs     []int  `json:"itemIds,omitempty"`
+    Description string `json:"description"`
+    Symbology   string `json:"symbology,omitempty"`
+}
+
+// ReorderImagesRequest lists every image on the container in its new order.
+type ReorderImagesRequest struct {
+    ImageIDs []int `json:"imageIds"`
+}
+
+// SetCoverRequest picks the cover image. Without one the first image in
+// display order is the cover.
+type SetCoverRequest struct {
+    ImageID *int `json:"imageId"`
+}
+
+// Event is one entry in a container's history. RelatedContainerID is the
+// other container in the merge or split, and ItemIDs and ChildContainerIDs
+// what moved between them.
+type Event struct {
+    ID                 int       `json:"id"`
+    ContainerID        int       `json:"containerId"`
+    UserID             int       `json:"userId"`
+    Action             string    `json:"action"`
+    RelatedContainerID *int      `json:"relatedContainerId,omitempty"`
+    RelatedLabel       string    `json:"relatedLabel,omitempty"`
+    RelatedName        string    `json:"relatedName,omitempty"`
+    ItemIDs            []int     `json:"itemIds"`
+    ChildContainerIDs  []int     `json:"childContainerIds"`
+    CreatedAt          time.Time `json:"createdAt"`
+}
+
+// LabelSheetRequest selects containers by ID or a whole workspace. Template
+// names a built-in stock unless Custom describes one, and Symbology, when
+// set, overrides each container's own.
+type LabelSheetRequest struct {
+    ContainerIDs  []int           `json:"containerIds"`
+    WorkspaceID   *int            `json:"workspaceId,omitempty"`
+    Template      string          `json:"template"`
+    Custom        *label.Template `json:"custom,omitempty"`
+    StartPosition int             `json:"startPosition"`
+    Symbology     string          `json:"symbology,omitempty"`
+}
+
+// FormatLabel renders the human-friendly label printed on a box, such as
+// BOX-0042.
+func FormatLabel(prefix string, number int) string {
+    return fmt.Sprintf("%s-%04d", prefix, number)
+}
+
+// validateCapacity checks the measurements are sensible and that the mode
+// has what it needs to compute a fill level.
+func validateCapacity(capacity *models.Capacity) error {
+    if capacity == nil {
+        return nil
+    }
+
+    for _, v := range []*float64{capacity.WidthCm, capacity.DepthCm, capacity.HeightCm} {
+        if v != nil && (*v <= 0 || *v > maxDimensionCm) {
+            return fmt.Errorf("%w: dimensions must be between 0 and %d cm", ErrInvalidCapacity, maxDimensionCm)
+        }
+    }
+    if v := capacity.MaxWeightKg; v != nil && (*v <= 0 || *v > maxWeightKg) {
+        return fmt.Errorf("%w: maxWeightKg must be between 0 and %d", ErrInvalidCapacity, maxWeightKg)
+    }
+    if v := capacity.MaxVolumeL; v != nil && (*v <= 0 || *v > maxVolumeL) {
+        return fmt.Errorf("%w: maxVolumeL must be between 0 and %d", ErrInvalidCapacity, maxVolumeL)
+    }
+    if v := capacity.MaxItems; v != nil && *v <= 0 {
+        return fmt.Errorf("%w: maxItems must be positive", ErrInvalidCapacity)
+    }
+
+    switch capacity.Mode {
+    case "":
+    case CapacityVolume:
+        if capacity.MaxVolumeL == nil &&
+            (capacity.WidthCm == nil || capacity.DepthCm == nil || capacity.HeightCm == nil) {
+            return fmt.Errorf("%w: volume needs maxVolumeL or all three dimensions", ErrInvalidCapacity)
+        }
+    case CapacityWeight:
+        if capacity.MaxWeightKg == nil {
+            return fmt.Errorf("%w: weight needs maxWeightKg", ErrInvalidCapacity)
+        }
+    case CapacityCount:
+        if capacity.MaxItems == nil {
+            return fmt.Errorf("%w: count needs maxItems", ErrInvalidCapacity)
+        }
+    default:
+        return fmt.Errorf("%w: mode must be one of volume, weight or count", ErrInvalidCapacity)
+    }
+
+    return nil
 }
@@ -6,7 +6,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/chrisabs/storage/internal/blob"
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
+	"github.com/chrisabs/storage/pkg/utils"
+	"github.com/lib/pq"
 )
 
 type Repository struct {
@@ -17,37 +21,17 @@ func NewRepository(db *sql.DB) *Repository {
     return &Repository{db: db}
 }
 
-func (r *Repository) Create(container *models.Container, itemRequests []CreateItemRequest) error {
+func (r *Repository) Create(container *models.Container, labelPrefix string, itemRequests []CreateItemRequest) error {
     tx, err := r.db.Begin()
     if err != nil {
         return fmt.Errorf("error starting transaction: %v", err)
     }
     defer tx.Rollback()
 
-    containerQuery := `
-        INSERT INTO container (id, name, description qr_code, qr_code_image, number, location, user_id, workspace_id, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-        RETURNING id`
-
-    var containerID int
-    err = tx.QueryRow(
-        containerQuery,
-        container.ID,
-        container.Name,
-        container.Description,
-        container.QRCode,
-        container.QRCodeImage,
-        container.Number,
-        container.Location,
-        container.UserID,
-        container.WorkspaceID,
-        container.CreatedAt,
-        container.UpdatedAt,
-    ).Scan(&containerID)
-
-    if err != nil {
-        return fmt.Errorf("error creating container: %v", err)
+    if err := insertContainer(tx, container, labelPrefix); err != nil {
+        return err
     }
+    containerID := container.ID
 
     if len(itemRequests) > 0 {
         itemQuery := `
@@ -76,10 +60,159 @@ func (r *Repository) Create(container *models.Container, itemRequests []CreateIt
     return tx.Commit()
 }
 
+// insertContainer allocates the next number for the container's owner and
+// inserts it, taking the workspace and location of its parent when nested.
+func insertContainer(tx *sql.Tx, container *models.Container, labelPrefix string) error {
+    // Nested containers sit wherever the box they are in sits
+    if container.ParentContainerID != nil {
+        var parentUserID int
+        err := tx.QueryRow(
+            `SELECT user_id, workspace_id, location_id FROM container WHERE id = $1 AND retired_at IS NULL`,
+            *container.ParentContainerID,
+        ).Scan(&parentUserID, &container.WorkspaceID, &container.LocationID)
+        if err == sql.ErrNoRows || (err == nil && parentUserID != container.UserID) {
+            return ErrParentNotFound
+        }
+        if err != nil {
+            return fmt.Errorf("error checking parent container: %v", err)
+        }
+    } else if err := checkLocation(tx, container.UserID, container.LocationID, container.WorkspaceID); err != nil {
+        return err
+    }
+
+    // The counter row stays locked until commit, so concurrent creates queue
+    // up behind each other and a rolled back create hands its number back
+    err := tx.QueryRow(`
+        INSERT INTO container_counter (user_id, last_number)
+        VALUES ($1, 1)
+        ON CONFLICT (user_id) DO UPDATE SET last_number = container_counter.last_number + 1
+        RETURNING last_number`,
+        container.UserID,
+    ).Scan(&container.Number)
+    if err != nil {
+        return fmt.Errorf("error allocating container number: %v", err)
+    }
+    container.Label = FormatLabel(labelPrefix, container.Number)
+
+    containerQuery := `
+        INSERT INTO container (name, description, qr_code, number, label, symbology, location, user_id, workspace_id, parent_container_id, location_id, created_at, updated_at,
+                               capacity_mode, width_cm, depth_cm, height_cm, max_weight_kg, max_volume_l, max_items)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+        RETURNING id`
+
+    args := []interface{}{
+        container.Name,
+        container.Description,
+        container.QRCode,
+        container.Number,
+        container.Label,
+        container.Symbology,
+        container.Location,
+        container.UserID,
+        container.WorkspaceID,
+        container.ParentContainerID,
+        container.LocationID,
+        container.CreatedAt,
+        container.UpdatedAt,
+    }
+
+    var containerID int
+    err = tx.QueryRow(containerQuery, append(args, capacityValues(container.Capacity)...)...).Scan(&containerID)
+
+    if err != nil {
+        return fmt.Errorf("error creating container: %v", err)
+    }
+    container.ID = containerID
+
+    return nil
+}
+
+// capacityColumns selects a container's capacity as one JSON object, NULL
+// when nothing is set, followed by its fill percentage.
+const capacityColumns = `
+    CASE WHEN num_nonnulls(c.capacity_mode, c.width_cm, c.depth_cm, c.height_cm,
+                           c.max_weight_kg, c.max_volume_l, c.max_items) = 0 THEN NULL
+    ELSE jsonb_strip_nulls(jsonb_build_object(
+        'mode', c.capacity_mode,
+        'widthCm', c.width_cm,
+        'depthCm', c.depth_cm,
+        'heightCm', c.height_cm,
+        'maxWeightKg', c.max_weight_kg,
+        'maxVolumeL', c.max_volume_l,
+        'maxItems', c.max_items
+    )) END,
+    container_fill(c.id)`
+
+func parseCapacity(capacityJSON []byte, container *models.Container) error {
+    if capacityJSON == nil {
+        return nil
+    }
+
+    container.Capacity = new(models.Capacity)
+    if err := json.Unmarshal(capacityJSON, container.Capacity); err != nil {
+        return fmt.Errorf("error parsing capacity: %v", err)
+    }
+    return nil
+}
+
+// capacityValues spreads capacity over the seven capacity columns, all NULL
+// when there is none.
+func capacityValues(capacity *models.Capacity) []interface{} {
+    if capacity == nil {
+        return make([]interface{}, 7)
+    }
+
+    var mode interface{}
+    if capacity.Mode != "" {
+        mode = capacity.Mode
+    }
+    return []interface{}{
+        mode, capacity.WidthCm, capacity.DepthCm, capacity.HeightCm,
+        capacity.MaxWeightKg, capacity.MaxVolumeL, capacity.MaxItems,
+    }
+}
+
+// coverImageColumn selects the container's cover as a JSON image, falling
+// back to the first photo in display order, or NULL when it has none.
+const coverImageColumn = `
+    (SELECT jsonb_build_object(
+                'id', ci.id,
+                'url', ci.url,
+                'width', ci.width,
+                'height', ci.height,
+                'byteSize', ci.byte_size,
+                'variants', ci.variants,
+                'displayOrder', ci.display_order,
+                'createdAt', ci.created_at,
+                'updatedAt', ci.updated_at
+            )
+     FROM container_image ci
+     WHERE ci.container_id = c.id
+     ORDER BY ci.is_cover DESC, ci.display_order, ci.id
+     LIMIT 1)`
+
+func parseCoverImage(coverJSON []byte, container *models.Container) error {
+    if coverJSON == nil {
+        return nil
+    }
+
+    container.CoverImage = new(models.ItemImage)
+    if err := json.Unmarshal(coverJSON, container.CoverImage); err != nil {
+        return fmt.Errorf("error parsing cover image: %v", err)
+    }
+    return nil
+}
+
 func (r *Repository) GetByID(id int) (*models.Container, error) {
     containerQuery := `
-        SELECT c.id, c.name, c.description, c.qr_code, c.qr_code_image, c.number, 
+        SELECT c.id, c.name, c.description, c.qr_code, c.number, COALESCE(c.label, ''), c.symbology,
                c.location, c.user_id, c.workspace_id, c.created_at, c.updated_at,
+               c.parent_container_id, container_path(c.parent_container_id),
+               c.location_id, location_path(c.location_id),
+               ` + capacityColumns + `,
+               ` + coverImageColumn + `,
+               open_checkout(c.id, NULL),
+               c.retired_at, c.merged_into_id,
                w.id, w.name, w.description, w.user_id, w.created_at, w.updated_at
         FROM container c
         LEFT JOIN workspace w ON c.workspace_id = w.id
@@ -87,6 +220,7 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
 
     container := new(models.Container)
     var workspaceID sql.NullInt64
+    var pathJSON, locationPathJSON, capacityJSON, coverJSON, checkoutJSON []byte
     var wsFields struct {
         ID          sql.NullInt64
         Name        sql.NullString
@@ -98,8 +232,12 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
 
     err := r.db.QueryRow(containerQuery, id).Scan(
         &container.ID, &container.Name, &container.Description, &container.QRCode,
-        &container.QRCodeImage, &container.Number, &container.Location,
+        &container.Number, &container.Label, &container.Symbology, &container.Location,
         &container.UserID, &workspaceID, &container.CreatedAt, &container.UpdatedAt,
+        &container.ParentContainerID, &pathJSON,
+        &container.LocationID, &locationPathJSON,
+        &capacityJSON, &container.FillPercent, &coverJSON, &checkoutJSON,
+        &container.RetiredAt, &container.MergedIntoID,
         &wsFields.ID, &wsFields.Name, &wsFields.Description,
         &wsFields.UserID, &wsFields.CreatedAt, &wsFields.UpdatedAt,
     )
@@ -111,6 +249,35 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
         return nil, err
     }
 
+    if err := json.Unmarshal(pathJSON, &container.Path); err != nil {
+        return nil, fmt.Errorf("error parsing path: %v", err)
+    }
+
+    if err := json.Unmarshal(locationPathJSON, &container.LocationPath); err != nil {
+        return nil, fmt.Errorf("error parsing location path: %v", err)
+    }
+
+    if err := parseCapacity(capacityJSON, container); err != nil {
+        return nil, err
+    }
+
+    if err := parseCoverImage(coverJSON, container); err != nil {
+        return nil, err
+    }
+
+    if checkoutJSON != nil {
+        container.CheckedOut = new(models.Checkout)
+        if err := json.Unmarshal(checkoutJSON, container.CheckedOut); err != nil {
+            return nil, fmt.Errorf("error parsing checkout: %v", err)
+        }
+    }
+
+    images, err := r.GetImages(container.ID)
+    if err != nil {
+        return nil, err
+    }
+    container.Images = images
+
     if workspaceID.Valid && wsFields.ID.Valid {
         wsID := int(workspaceID.Int64)
         container.WorkspaceID = &wsID
@@ -130,6 +297,10 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
                    jsonb_agg(
                        jsonb_build_object(
                            'url', url,
+                           'width', width,
+                           'height', height,
+                           'byteSize', byte_size,
+                           'variants', variants,
                            'displayOrder', display_order,
                            'createdAt', created_at,
                            'updatedAt', updated_at
@@ -138,7 +309,7 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
             FROM item_image
             GROUP BY item_id
         )
-        SELECT i.id, i.name, i.description, i.quantity, 
+        SELECT i.id, i.name, i.description, i.quantity, i.unit,
                i.container_id, i.created_at, i.updated_at,
                COALESCE(img.images, '[]'::jsonb) as images,
                COALESCE(
@@ -158,7 +329,7 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
         LEFT JOIN item_tag it ON i.id = it.item_id
         LEFT JOIN tag t ON it.tag_id = t.id
         WHERE i.container_id = $1
-        GROUP BY i.id, i.name, i.description, i.quantity, 
+        GROUP BY i.id, i.name, i.description, i.quantity, i.unit,
                  i.container_id, i.created_at, i.updated_at,
                  img.images`
 
@@ -175,7 +346,7 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
 
         err := rows.Scan(
             &item.ID, &item.Name, &item.Description,
-            &item.Quantity, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
+            &item.Quantity, &item.Unit, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
             &imagesJSON, &tagsJSON,
         )
         if err != nil {
@@ -192,18 +363,90 @@ func (r *Repository) GetByID(id int) (*models.Container, error) {
 
         container.Items = append(container.Items, item)
     }
+    container.ItemCount = len(container.Items)
+
+    container.Children, err = r.getChildren(id)
+    if err != nil {
+        return nil, err
+    }
 
     return container, nil
 }
 
+// getChildren loads every container nested below id as a tree. Children carry
+// item counts rather than their items.
+func (r *Repository) getChildren(id int) ([]models.Container, error) {
+    query := `
+        WITH RECURSIVE descendants AS (
+            SELECT id, 1 AS depth
+            FROM container
+            WHERE parent_container_id = $1
+            UNION ALL
+            SELECT c.id, d.depth + 1
+            FROM container c
+            JOIN descendants d ON c.parent_container_id = d.id
+            WHERE d.depth < 32
+        )
+        SELECT c.id, c.name, c.description, c.qr_code, c.number, COALESCE(c.label, ''), c.location,
+               c.user_id, c.workspace_id, c.parent_container_id, c.location_id, c.created_at, c.updated_at,
+               (SELECT COUNT(*) FROM item i WHERE i.container_id = c.id) as item_count,
+               ` + capacityColumns + `
+        FROM descendants d
+        JOIN container c ON c.id = d.id
+        ORDER BY d.depth, c.name`
+
+    rows, err := r.db.Query(query, id)
+    if err != nil {
+        return nil, fmt.Errorf("error querying child containers: %v", err)
+    }
+    defer rows.Close()
+
+    byParent := make(map[int][]models.Container)
+    for rows.Next() {
+        var child models.Container
+        var parentID int
+        var capacityJSON []byte
+        err := rows.Scan(
+            &child.ID, &child.Name, &child.Description, &child.QRCode,
+            &child.Number, &child.Label, &child.Location, &child.UserID, &child.WorkspaceID,
+            &parentID, &child.LocationID, &child.CreatedAt, &child.UpdatedAt, &child.ItemCount,
+            &capacityJSON, &child.FillPercent,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error scanning child container: %v", err)
+        }
+
+        if err := parseCapacity(capacityJSON, &child); err != nil {
+            return nil, err
+        }
+
+        child.ParentContainerID = &parentID
+        byParent[parentID] = append(byParent[parentID], child)
+    }
+
+    return attachChildren(id, byParent), nil
+}
+
+func attachChildren(parentID int, byParent map[int][]models.Container) []models.Container {
+    children := byParent[parentID]
+    for i := range children {
+        children[i].Children = attachChildren(children[i].ID, byParent)
+    }
+    return children
+}
+
 func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
     query := `
-        SELECT c.id, c.name, c.description, c.qr_code, c.qr_code_image, c.number, 
+        SELECT c.id, c.name, c.description, c.qr_code, c.number, COALESCE(c.label, ''), c.symbology,
                c.location, c.user_id, c.workspace_id, c.created_at, c.updated_at,
+               c.parent_container_id, container_path(c.parent_container_id),
+               c.location_id, location_path(c.location_id),
+               ` + capacityColumns + `,
+               ` + coverImageColumn + `,
                w.id, w.name, w.description, w.user_id, w.created_at, w.updated_at
         FROM container c
         LEFT JOIN workspace w ON c.workspace_id = w.id
-        WHERE c.user_id = $1
+        WHERE c.user_id = $1 AND c.retired_at IS NULL
         ORDER BY c.created_at DESC`
 
     rows, err := r.db.Query(query, userID)
@@ -216,6 +459,7 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
     for rows.Next() {
         container := new(models.Container)
         var workspaceID sql.NullInt64
+        var pathJSON, locationPathJSON, capacityJSON, coverJSON []byte
         var wsFields struct {
             ID          sql.NullInt64
             Name        sql.NullString
@@ -227,8 +471,11 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
 
         err := rows.Scan(
             &container.ID, &container.Name, &container.Description, &container.QRCode,
-            &container.QRCodeImage, &container.Number, &container.Location,
+            &container.Number, &container.Label, &container.Symbology, &container.Location,
             &container.UserID, &workspaceID, &container.CreatedAt, &container.UpdatedAt,
+            &container.ParentContainerID, &pathJSON,
+            &container.LocationID, &locationPathJSON,
+            &capacityJSON, &container.FillPercent, &coverJSON,
             &wsFields.ID, &wsFields.Name, &wsFields.Description,
             &wsFields.UserID, &wsFields.CreatedAt, &wsFields.UpdatedAt,
         )
@@ -236,6 +483,22 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
             return nil, fmt.Errorf("error scanning container: %v", err)
         }
 
+        if err := json.Unmarshal(pathJSON, &container.Path); err != nil {
+            return nil, fmt.Errorf("error parsing path: %v", err)
+        }
+
+        if err := json.Unmarshal(locationPathJSON, &container.LocationPath); err != nil {
+            return nil, fmt.Errorf("error parsing location path: %v", err)
+        }
+
+        if err := parseCapacity(capacityJSON, container); err != nil {
+            return nil, err
+        }
+
+        if err := parseCoverImage(coverJSON, container); err != nil {
+            return nil, err
+        }
+
         if workspaceID.Valid && wsFields.ID.Valid {
             wsID := int(workspaceID.Int64)
             container.WorkspaceID = &wsID
@@ -255,6 +518,10 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
                        jsonb_agg(
                            jsonb_build_object(
                                'url', url,
+                               'width', width,
+                               'height', height,
+                               'byteSize', byte_size,
+                               'variants', variants,
                                'displayOrder', display_order,
                                'createdAt', created_at,
                                'updatedAt', updated_at
@@ -263,7 +530,7 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
                 FROM item_image
                 GROUP BY item_id
             )
-            SELECT i.id, i.name, i.description, i.quantity, 
+            SELECT i.id, i.name, i.description, i.quantity, i.unit,
                    i.container_id, i.created_at, i.updated_at,
                    COALESCE(img.images, '[]'::jsonb) as images,
                    COALESCE(
@@ -283,7 +550,7 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
             LEFT JOIN item_tag it ON i.id = it.item_id
             LEFT JOIN tag t ON it.tag_id = t.id
             WHERE i.container_id = $1
-            GROUP BY i.id, i.name, i.description, i.quantity, 
+            GROUP BY i.id, i.name, i.description, i.quantity, i.unit,
                      i.container_id, i.created_at, i.updated_at,
                      img.images`
 
@@ -301,7 +568,7 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
 
                 err := itemRows.Scan(
                     &item.ID, &item.Name, &item.Description,
-                    &item.Quantity, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
+                    &item.Quantity, &item.Unit, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
                     &imagesJSON, &tagsJSON,
                 )
                 if err != nil {
@@ -319,6 +586,7 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Container, error) {
                 container.Items = append(container.Items, item)
             }
         }()
+        container.ItemCount = len(container.Items)
 
         containers = append(containers, container)
     }
@@ -330,10 +598,141 @@ func (r *Repository) GetByQR(qrCode string) (*models.Container, error) {
     return r.GetByQRWithItems(qrCode, true)
 }
 
+// GetForLabels loads just what a printed label needs, in the order the IDs
+// were given or by number for a whole workspace, reading at most limit
+// containers. IDs that are missing or belong to someone else fail the whole
+// request.
+func (r *Repository) GetForLabels(userID int, ids []int, workspaceID *int, limit int) ([]*models.Container, error) {
+    query := `
+        SELECT c.id, c.name, c.qr_code, c.number, COALESCE(c.label, ''), c.symbology,
+               COALESCE(c.location, ''), location_path(c.location_id)
+        FROM container c
+        WHERE c.user_id = $1 AND c.retired_at IS NULL AND (c.id = ANY($2) OR c.workspace_id = $3)
+        ORDER BY c.number
+        LIMIT $4`
+
+    rows, err := r.db.Query(query, userID, pq.Array(ids), workspaceID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("error querying containers for labels: %v", err)
+    }
+    defer rows.Close()
+
+    byID := make(map[int]*models.Container)
+    var containers []*models.Container
+    for rows.Next() {
+        container := new(models.Container)
+        var locationPathJSON []byte
+        err := rows.Scan(
+            &container.ID, &container.Name, &container.QRCode, &container.Number,
+            &container.Label, &container.Symbology, &container.Location, &locationPathJSON,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error scanning container for labels: %v", err)
+        }
+
+        if err := json.Unmarshal(locationPathJSON, &container.LocationPath); err != nil {
+            return nil, fmt.Errorf("error parsing location path: %v", err)
+        }
+
+        byID[container.ID] = container
+        containers = append(containers, container)
+    }
+
+    if workspaceID != nil && len(ids) == 0 {
+        return containers, nil
+    }
+
+    ordered := make([]*models.Container, 0, len(ids))
+    for _, id := range ids {
+        container, ok := byID[id]
+        if !ok {
+            return nil, fmt.Errorf("%w: %d", ErrContainerNotFound, id)
+        }
+        ordered = append(ordered, container)
+    }
+    return ordered, nil
+}
+
+// GetWithFreeSpace lists the user's containers in a workspace that have a
+// capacity and at least minFree percent of it left, emptiest first.
+func (r *Repository) GetWithFreeSpace(userID, workspaceID int, minFree float64) ([]*models.Container, error) {
+    query := `
+        SELECT c.id, c.name, c.description, c.qr_code, c.number, COALESCE(c.label, ''),
+               COALESCE(c.location, ''), c.user_id, c.workspace_id, c.parent_container_id,
+               c.location_id, location_path(c.location_id), c.created_at, c.updated_at,
+               ` + capacityColumns + `
+        FROM container c
+        WHERE c.user_id = $1 AND c.workspace_id = $2 AND c.retired_at IS NULL
+          AND container_fill(c.id) <= 100 - $3
+        ORDER BY container_fill(c.id), c.number`
+
+    rows, err := r.db.Query(query, userID, workspaceID, minFree)
+    if err != nil {
+        return nil, fmt.Errorf("error querying containers with free space: %v", err)
+    }
+    defer rows.Close()
+
+    containers := make([]*models.Container, 0)
+    for rows.Next() {
+        container := new(models.Container)
+        var locationPathJSON, capacityJSON []byte
+        err := rows.Scan(
+            &container.ID, &container.Name, &container.Description, &container.QRCode,
+            &container.Number, &container.Label, &container.Location, &container.UserID,
+            &container.WorkspaceID, &container.ParentContainerID, &container.LocationID,
+            &locationPathJSON, &container.CreatedAt, &container.UpdatedAt,
+            &capacityJSON, &container.FillPercent,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error scanning container: %v", err)
+        }
+
+        if err := json.Unmarshal(locationPathJSON, &container.LocationPath); err != nil {
+            return nil, fmt.Errorf("error parsing location path: %v", err)
+        }
+
+        if err := parseCapacity(capacityJSON, container); err != nil {
+            return nil, err
+        }
+
+        container.Items = make([]models.Item, 0)
+        containers = append(containers, container)
+    }
+
+    return containers, rows.Err()
+}
+
+// UpdateQRCode replaces a container's token.
+func (r *Repository) UpdateQRCode(id int, qrToken string) error {
+    query := `
+        UPDATE container
+        SET qr_code = $2, updated_at = $3
+        WHERE id = $1`
+
+    result, err := r.db.Exec(query, id, qrToken, time.Now().UTC())
+    if err != nil {
+        return fmt.Errorf("error updating QR code: %v", err)
+    }
+
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking QR code update: %v", err)
+    }
+    if rows == 0 {
+        return fmt.Errorf("container not found")
+    }
+
+    return nil
+}
+
+// GetByQRWithItems accepts the scanned deep link or the bare token.
 func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models.Container, error) {
     query := `
-        SELECT c.id, c.name, c.description, c.qr_code, c.qr_code_image, c.number, 
+        SELECT c.id, c.name, c.description, c.qr_code, c.number, COALESCE(c.label, ''), c.symbology,
                c.location, c.user_id, c.workspace_id, c.created_at, c.updated_at,
+               c.parent_container_id, container_path(c.parent_container_id),
+               c.location_id, location_path(c.location_id),
+               ` + capacityColumns + `,
                w.id, w.name, w.description, w.user_id, w.created_at, w.updated_at
         FROM container c
         LEFT JOIN workspace w ON c.workspace_id = w.id
@@ -342,11 +741,15 @@ func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models
     container := new(models.Container)
     var workspaceID sql.NullInt64
     var workspace models.Workspace
+    var pathJSON, locationPathJSON, capacityJSON []byte
 
-    err := r.db.QueryRow(query, qrCode).Scan(
+    err := r.db.QueryRow(query, utils.QRToken(qrCode)).Scan(
         &container.ID, &container.Name, &container.Description, &container.QRCode,
-        &container.QRCodeImage, &container.Number, &container.Location,
+        &container.Number, &container.Label, &container.Symbology, &container.Location,
         &container.UserID, &workspaceID, &container.CreatedAt, &container.UpdatedAt,
+        &container.ParentContainerID, &pathJSON,
+        &container.LocationID, &locationPathJSON,
+        &capacityJSON, &container.FillPercent,
         &workspace.ID, &workspace.Name, &workspace.Description,
         &workspace.UserID, &workspace.CreatedAt, &workspace.UpdatedAt,
     )
@@ -358,6 +761,18 @@ func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models
         return nil, err
     }
 
+    if err := json.Unmarshal(pathJSON, &container.Path); err != nil {
+        return nil, fmt.Errorf("error parsing path: %v", err)
+    }
+
+    if err := json.Unmarshal(locationPathJSON, &container.LocationPath); err != nil {
+        return nil, fmt.Errorf("error parsing location path: %v", err)
+    }
+
+    if err := parseCapacity(capacityJSON, container); err != nil {
+        return nil, err
+    }
+
     if workspaceID.Valid {
         wsID := int(workspaceID.Int64)
         container.WorkspaceID = &wsID
@@ -371,6 +786,10 @@ func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models
                        jsonb_agg(
                            jsonb_build_object(
                                'url', url,
+                               'width', width,
+                               'height', height,
+                               'byteSize', byte_size,
+                               'variants', variants,
                                'displayOrder', display_order,
                                'createdAt', created_at,
                                'updatedAt', updated_at
@@ -379,7 +798,7 @@ func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models
                 FROM item_image
                 GROUP BY item_id
             )
-            SELECT i.id, i.name, i.description, i.quantity, 
+            SELECT i.id, i.name, i.description, i.quantity, i.unit,
                    i.container_id, i.created_at, i.updated_at,
                    COALESCE(img.images, '[]'::jsonb) as images,
                    COALESCE(
@@ -399,7 +818,7 @@ func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models
             LEFT JOIN item_tag it ON i.id = it.item_id
             LEFT JOIN tag t ON it.tag_id = t.id
             WHERE i.container_id = $1
-            GROUP BY i.id, i.name, i.description, i.quantity, 
+            GROUP BY i.id, i.name, i.description, i.quantity, i.unit,
                      i.container_id, i.created_at, i.updated_at,
                      img.images`
 
@@ -416,7 +835,7 @@ func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models
 
             err := rows.Scan(
                 &item.ID, &item.Name, &item.Description,
-                &item.Quantity, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
+                &item.Quantity, &item.Unit, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
                 &imagesJSON, &tagsJSON,
             )
             if err != nil {
@@ -433,31 +852,49 @@ func (r *Repository) GetByQRWithItems(qrCode string, includeItems bool) (*models
 
             container.Items = append(container.Items, item)
         }
+        container.ItemCount = len(container.Items)
     }
 
     return container, nil
 }
 
+// subtreeQuery selects the container $1 together with every container nested
+// inside it, at any depth.
+const subtreeQuery = `
+    WITH RECURSIVE subtree AS (
+        SELECT id FROM container WHERE id = $1
+        UNION
+        SELECT c.id FROM container c JOIN subtree s ON c.parent_container_id = s.id
+    )`
+
 func (r *Repository) Update(container *models.Container) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    if err := lockActive(tx, container.ID); err != nil {
+        return err
+    }
+
     query := `
         UPDATE container
-        SET name = $2, description = $3, location = $4, workspace_id = $5, updated_at = $6
+        SET name = $2, description = $3, location = $4, symbology = $5, updated_at = $6,
+            capacity_mode = $7, width_cm = $8, depth_cm = $9, height_cm = $10,
+            max_weight_kg = $11, max_volume_l = $12, max_items = $13
         WHERE id = $1`
 
-    var workspaceID sql.NullInt64
-    if container.WorkspaceID != nil {
-        workspaceID = sql.NullInt64{Int64: int64(*container.WorkspaceID), Valid: true}
-    }
-
-    result, err := r.db.Exec(
-        query,
+    args := []interface{}{
         container.ID,
         container.Name,
         container.Description,
         container.Location,
-        workspaceID,
+        container.Symbology,
         time.Now().UTC(),
-    )
+    }
+
+    result, err := tx.Exec(query, append(args, capacityValues(container.Capacity)...)...)
     if err != nil {
         return fmt.Errorf("error updating container: %v", err)
     }
@@ -471,6 +908,134 @@ func (r *Repository) Update(container *models.Container) error {
         return fmt.Errorf("container not found")
     }
 
+    if err := checkLocation(tx, container.UserID, container.LocationID, container.WorkspaceID); err != nil {
+        return err
+    }
+
+    if err := setSubtreePlacement(tx, container.ID, container.WorkspaceID, container.LocationID); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// lockActive locks the container for an update, which a merge may not retire
+// it during. Retired containers are kept for their history only.
+func lockActive(tx *sql.Tx, id int) error {
+    var retired bool
+    err := tx.QueryRow(`SELECT retired_at IS NOT NULL FROM container WHERE id = $1 FOR UPDATE`, id).Scan(&retired)
+    if err == sql.ErrNoRows {
+        return ErrContainerNotFound
+    }
+    if err != nil {
+        return fmt.Errorf("error locking container: %v", err)
+    }
+    if retired {
+        return ErrContainerRetired
+    }
+    return nil
+}
+
+// Move places the container inside parentID, or at the top level when
+// parentID is nil. Nested containers and items travel with it and the whole
+// subtree adopts the new parent's workspace and location.
+func (r *Repository) Move(id int, parentID *int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    if err := lockActive(tx, id); err != nil {
+        return err
+    }
+
+    var userID int
+    var workspaceID, locationID *int
+    err = tx.QueryRow(
+        `SELECT user_id, workspace_id, location_id FROM container WHERE id = $1`, id,
+    ).Scan(&userID, &workspaceID, &locationID)
+    if err != nil {
+        return fmt.Errorf("error loading container: %v", err)
+    }
+
+    if parentID != nil {
+        var parentUserID int
+        err = tx.QueryRow(
+            `SELECT user_id, workspace_id, location_id FROM container WHERE id = $1 AND retired_at IS NULL FOR UPDATE`, *parentID,
+        ).Scan(&parentUserID, &workspaceID, &locationID)
+        if err == sql.ErrNoRows || (err == nil && parentUserID != userID) {
+            return ErrParentNotFound
+        }
+        if err != nil {
+            return fmt.Errorf("error loading parent container: %v", err)
+        }
+
+        var createsCycle bool
+        err = tx.QueryRow(
+            subtreeQuery+` SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`, id, *parentID,
+        ).Scan(&createsCycle)
+        if err != nil {
+            return fmt.Errorf("error checking container hierarchy: %v", err)
+        }
+        if createsCycle {
+            return ErrContainerCycle
+        }
+    }
+
+    _, err = tx.Exec(
+        `UPDATE container SET parent_container_id = $2, updated_at = $3 WHERE id = $1`,
+        id, parentID, time.Now().UTC(),
+    )
+    if err != nil {
+        return fmt.Errorf("error moving container: %v", err)
+    }
+
+    if err := setSubtreePlacement(tx, id, workspaceID, locationID); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+func setSubtreePlacement(tx *sql.Tx, id int, workspaceID, locationID *int) error {
+    query := subtreeQuery + `
+        UPDATE container
+        SET workspace_id = $2, location_id = $3, updated_at = $4
+        WHERE id IN (SELECT id FROM subtree)`
+
+    if _, err := tx.Exec(query, id, workspaceID, locationID, time.Now().UTC()); err != nil {
+        return fmt.Errorf("error updating nested containers: %v", err)
+    }
+
+    return nil
+}
+
+// checkLocation verifies that a container's location belongs to its
+// workspace, and that the workspace belongs to the container's owner.
+func checkLocation(tx *sql.Tx, userID int, locationID, workspaceID *int) error {
+    if locationID == nil {
+        return nil
+    }
+    if workspaceID == nil {
+        return ErrLocationNotFound
+    }
+
+    var locationWorkspaceID int
+    err := tx.QueryRow(`
+        SELECT l.workspace_id
+        FROM location l
+        JOIN workspace w ON l.workspace_id = w.id
+        WHERE l.id = $1 AND w.user_id = $2`,
+        *locationID, userID,
+    ).Scan(&locationWorkspaceID)
+    if err == sql.ErrNoRows || (err == nil && locationWorkspaceID != *workspaceID) {
+        return ErrLocationNotFound
+    }
+    if err != nil {
+        return fmt.Errorf("error checking location: %v", err)
+    }
+
     return nil
 }
 
@@ -501,6 +1066,24 @@ func (r *Repository) Delete(id int) error {
         return fmt.Errorf("error removing workspace reference: %v", err)
     }
 
+    // Boxes inside the deleted container move up one level
+    childrenQuery := `
+        UPDATE container
+        SET parent_container_id = (SELECT parent_container_id FROM container WHERE id = $1),
+            updated_at = $2
+        WHERE parent_container_id = $1`
+
+    _, err = tx.Exec(childrenQuery, id, time.Now().UTC())
+    if err != nil {
+        return fmt.Errorf("error moving nested containers: %v", err)
+    }
+
+    // Release the container's photos and queue their blobs for deletion
+    imageQuery := `DELETE FROM container_image WHERE container_id = $1 RETURNING url, variants, user_id, storage_bytes, blob_key`
+    if _, err := blob.DeleteImages(tx, imageQuery, id); err != nil {
+        return fmt.Errorf("error removing container images: %v", err)
+    }
+
     containerQuery := `DELETE FROM container WHERE id = $1`
     result, err := tx.Exec(containerQuery, id)
     if err != nil {
@@ -517,4 +1100,456 @@ func (r *Repository) Delete(id int) error {
     }
 
     return tx.Commit()
-}
\ No newline at end of file
+}
+
+// GetImages returns the container's photos in display order.
+func (r *Repository) GetImages(containerID int) ([]models.ItemImage, error) {
+    query := `
+        SELECT id, url, width, height, byte_size, variants, display_order, created_at, updated_at
+        FROM container_image
+        WHERE container_id = $1
+        ORDER BY display_order, id`
+
+    rows, err := r.db.Query(query, containerID)
+    if err != nil {
+        return nil, fmt.Errorf("error querying container images: %v", err)
+    }
+    defer rows.Close()
+
+    images := []models.ItemImage{}
+    for rows.Next() {
+        var image models.ItemImage
+        var width, height sql.NullInt64
+        var byteSize sql.NullInt64
+        var variantsJSON []byte
+        if err := rows.Scan(
+            &image.ID, &image.URL, &width, &height, &byteSize,
+            &variantsJSON, &image.DisplayOrder, &image.CreatedAt, &image.UpdatedAt,
+        ); err != nil {
+            return nil, fmt.Errorf("error scanning container image: %v", err)
+        }
+
+        image.Width = int(width.Int64)
+        image.Height = int(height.Int64)
+        image.ByteSize = byteSize.Int64
+        if err := json.Unmarshal(variantsJSON, &image.Variants); err != nil {
+            return nil, fmt.Errorf("error parsing image variants: %v", err)
+        }
+        images = append(images, image)
+    }
+
+    return images, rows.Err()
+}
+
+// AddImage stores the upload, or reuses identical content stored before, and
+// appends it to the container's photos. The container stays locked from the
+// count check to the insert, so concurrent uploads cannot exceed MaxImages.
+// The stored bytes are charged to the blob, so the image row carries none.
+func (r *Repository) AddImage(containerID, userID int, store blob.Storer, upload *storage.Upload) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    if err := lockActive(tx, containerID); err != nil {
+        return err
+    }
+
+    var count int
+    err = tx.QueryRow(`SELECT COUNT(*) FROM container_image WHERE container_id = $1`, containerID).Scan(&count)
+    if err != nil {
+        return fmt.Errorf("error counting container images: %v", err)
+    }
+    if count >= MaxImages {
+        return ErrTooManyImages
+    }
+
+    image, err := blob.Acquire(tx, store, userID, upload)
+    if err != nil {
+        return err
+    }
+
+    variantsJSON, err := json.Marshal(image.Variants)
+    if err != nil {
+        return fmt.Errorf("error encoding image variants: %v", err)
+    }
+
+    query := `
+        INSERT INTO container_image (container_id, user_id, blob_key, url, width, height, byte_size, variants, display_order)
+        SELECT $1, $2, $3, $4, $5, $6, $7, $8, COALESCE(MAX(display_order) + 1, 0)
+        FROM container_image
+        WHERE container_id = $1`
+
+    _, err = tx.Exec(
+        query, containerID, userID, upload.Key, image.URL, image.Width, image.Height,
+        image.ByteSize, variantsJSON,
+    )
+    if err != nil {
+        return fmt.Errorf("error adding container image: %v", err)
+    }
+
+    return tx.Commit()
+}
+
+func (r *Repository) DeleteImage(containerID, imageID int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    query := `
+        DELETE FROM container_image
+        WHERE container_id = $1 AND id = $2
+        RETURNING url, variants, user_id, storage_bytes, blob_key`
+
+    deleted, err := blob.DeleteImages(tx, query, containerID, imageID)
+    if err != nil {
+        return fmt.Errorf("error deleting container image: %v", err)
+    }
+
+    if deleted == 0 {
+        return ErrImageNotFound
+    }
+
+    return tx.Commit()
+}
+
+// ReorderImages sets the display order to the position of each image in
+// imageIDs, which must name every image on the container.
+func (r *Repository) ReorderImages(containerID int, imageIDs []int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var total int
+    countQuery := `SELECT COUNT(*) FROM container_image WHERE container_id = $1`
+    if err := tx.QueryRow(countQuery, containerID).Scan(&total); err != nil {
+        return fmt.Errorf("error counting container images: %v", err)
+    }
+    if total != len(imageIDs) {
+        return ErrImageOrder
+    }
+
+    query := `
+        UPDATE container_image ci
+        SET display_order = o.position - 1, updated_at = $3
+        FROM unnest($2::int[]) WITH ORDINALITY AS o(id, position)
+        WHERE ci.id = o.id AND ci.container_id = $1`
+
+    result, err := tx.Exec(query, containerID, pq.Array(toInt64s(imageIDs)), time.Now().UTC())
+    if err != nil {
+        return fmt.Errorf("error reordering container images: %v", err)
+    }
+
+    updated, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking reorder result: %v", err)
+    }
+    if int(updated) != total {
+        return ErrImageOrder
+    }
+
+    return tx.Commit()
+}
+
+// SetCover marks imageID as the container's cover, or clears the choice when
+// imageID is nil.
+func (r *Repository) SetCover(containerID int, imageID *int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    // Clear the old cover first; the unique index allows only one per container
+    clearQuery := `
+        UPDATE container_image
+        SET is_cover = FALSE, updated_at = $2
+        WHERE container_id = $1 AND is_cover`
+
+    if _, err := tx.Exec(clearQuery, containerID, time.Now().UTC()); err != nil {
+        return fmt.Errorf("error clearing cover image: %v", err)
+    }
+
+    if imageID != nil {
+        setQuery := `
+            UPDATE container_image
+            SET is_cover = TRUE, updated_at = $3
+            WHERE container_id = $1 AND id = $2`
+
+        result, err := tx.Exec(setQuery, containerID, *imageID, time.Now().UTC())
+        if err != nil {
+            return fmt.Errorf("error setting cover image: %v", err)
+        }
+
+        updated, err := result.RowsAffected()
+        if err != nil {
+            return fmt.Errorf("error checking cover result: %v", err)
+        }
+        if updated == 0 {
+            return ErrImageNotFound
+        }
+    }
+
+    return tx.Commit()
+}
+
+// Merge moves every item and nested container out of the sources into the
+// target and retires the sources, all or nothing. Retired containers keep
+// their labels, so a scan still finds them and points at where things went.
+func (r *Repository) Merge(userID, targetID int, sourceIDs []int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    // Lock in ID order so overlapping merges cannot deadlock
+    ids := append([]int{targetID}, sourceIDs...)
+    rows, err := tx.Query(`
+        SELECT id, user_id, retired_at IS NOT NULL, workspace_id, location_id
+        FROM container
+        WHERE id = ANY($1)
+        ORDER BY id
+        FOR UPDATE`,
+        pq.Array(ids),
+    )
+    if err != nil {
+        return fmt.Errorf("error locking containers: %v", err)
+    }
+
+    var workspaceID, locationID *int
+    found := 0
+    for rows.Next() {
+        var id, ownerID int
+        var retired bool
+        var rowWorkspaceID, rowLocationID *int
+        if err := rows.Scan(&id, &ownerID, &retired, &rowWorkspaceID, &rowLocationID); err != nil {
+            rows.Close()
+            return fmt.Errorf("error scanning container: %v", err)
+        }
+        if ownerID != userID {
+            rows.Close()
+            return fmt.Errorf("%w: %d", ErrContainerNotFound, id)
+        }
+        if retired {
+            rows.Close()
+            return fmt.Errorf("%w: %d", ErrContainerRetired, id)
+        }
+        if id == targetID {
+            workspaceID, locationID = rowWorkspaceID, rowLocationID
+        }
+        found++
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("error locking containers: %v", err)
+    }
+    if found != len(ids) {
+        return ErrContainerNotFound
+    }
+
+    now := time.Now().UTC()
+    for _, sourceID := range sourceIDs {
+        var containsTarget bool
+        err = tx.QueryRow(
+            subtreeQuery+` SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`, sourceID, targetID,
+        ).Scan(&containsTarget)
+        if err != nil {
+            return fmt.Errorf("error checking container hierarchy: %v", err)
+        }
+        if containsTarget {
+            return ErrContainerCycle
+        }
+
+        itemIDs, err := collectIDs(tx, `
+            UPDATE item SET container_id = $1, updated_at = $3
+            WHERE container_id = $2
+            RETURNING id`,
+            targetID, sourceID, now,
+        )
+        if err != nil {
+            return fmt.Errorf("error moving items: %v", err)
+        }
+
+        childIDs, err := collectIDs(tx, `
+            UPDATE container SET parent_container_id = $1, updated_at = $3
+            WHERE parent_container_id = $2
+            RETURNING id`,
+            targetID, sourceID, now,
+        )
+        if err != nil {
+            return fmt.Errorf("error moving nested containers: %v", err)
+        }
+
+        _, err = tx.Exec(`
+            UPDATE container
+            SET retired_at = $2, merged_into_id = $3, parent_container_id = NULL, updated_at = $2
+            WHERE id = $1`,
+            sourceID, now, targetID,
+        )
+        if err != nil {
+            return fmt.Errorf("error retiring container: %v", err)
+        }
+
+        if err := recordEvent(tx, targetID, userID, EventMergedFrom, sourceID, itemIDs, childIDs, now); err != nil {
+            return err
+        }
+        if err := recordEvent(tx, sourceID, userID, EventMergedInto, targetID, itemIDs, childIDs, now); err != nil {
+            return err
+        }
+    }
+
+    // Boxes that came across now sit wherever the target sits
+    if err := setSubtreePlacement(tx, targetID, workspaceID, locationID); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// Split creates container alongside sourceID, in the same parent, workspace
+// and location, and moves itemIDs into it.
+func (r *Repository) Split(sourceID int, container *models.Container, labelPrefix string, itemIDs []int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var ownerID int
+    var retired bool
+    err = tx.QueryRow(`
+        SELECT user_id, retired_at IS NOT NULL, workspace_id, location_id, parent_container_id
+        FROM container
+        WHERE id = $1
+        FOR UPDATE`,
+        sourceID,
+    ).Scan(&ownerID, &retired, &container.WorkspaceID, &container.LocationID, &container.ParentContainerID)
+    if err == sql.ErrNoRows || (err == nil && ownerID != container.UserID) {
+        return ErrContainerNotFound
+    }
+    if err != nil {
+        return fmt.Errorf("error loading container: %v", err)
+    }
+    if retired {
+        return ErrContainerRetired
+    }
+
+    if err := insertContainer(tx, container, labelPrefix); err != nil {
+        return err
+    }
+
+    now := time.Now().UTC()
+    movedIDs, err := collectIDs(tx, `
+        UPDATE item SET container_id = $1, updated_at = $4
+        WHERE id = ANY($2) AND container_id = $3
+        RETURNING id`,
+        container.ID, pq.Array(itemIDs), sourceID, now,
+    )
+    if err != nil {
+        return fmt.Errorf("error moving items: %v", err)
+    }
+    if len(movedIDs) != len(itemIDs) {
+        return ErrSplitItems
+    }
+
+    if err := recordEvent(tx, sourceID, container.UserID, EventSplitInto, container.ID, movedIDs, nil, now); err != nil {
+        return err
+    }
+    if err := recordEvent(tx, container.ID, container.UserID, EventSplitFrom, sourceID, movedIDs, nil, now); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// GetEvents returns a container's history, newest first.
+func (r *Repository) GetEvents(containerID int) ([]Event, error) {
+    query := `
+        SELECT e.id, e.container_id, e.user_id, e.action, e.related_container_id,
+               COALESCE(rc.label, ''), COALESCE(rc.name, ''),
+               e.item_ids, e.child_container_ids, e.created_at
+        FROM container_event e
+        LEFT JOIN container rc ON e.related_container_id = rc.id
+        WHERE e.container_id = $1
+        ORDER BY e.created_at DESC, e.id DESC`
+
+    rows, err := r.db.Query(query, containerID)
+    if err != nil {
+        return nil, fmt.Errorf("error querying container history: %v", err)
+    }
+    defer rows.Close()
+
+    events := make([]Event, 0)
+    for rows.Next() {
+        var event Event
+        var itemIDs, childIDs pq.Int64Array
+        err := rows.Scan(
+            &event.ID, &event.ContainerID, &event.UserID, &event.Action, &event.RelatedContainerID,
+            &event.RelatedLabel, &event.RelatedName, &itemIDs, &childIDs, &event.CreatedAt,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error scanning container history: %v", err)
+        }
+
+        event.ItemIDs = toInts(itemIDs)
+        event.ChildContainerIDs = toInts(childIDs)
+        events = append(events, event)
+    }
+
+    return events, rows.Err()
+}
+
+func recordEvent(tx *sql.Tx, containerID, userID int, action string, relatedID int, itemIDs, childIDs []int, at time.Time) error {
+    _, err := tx.Exec(`
+        INSERT INTO container_event (container_id, user_id, action, related_container_id, item_ids, child_container_ids, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+        containerID, userID, action, relatedID, pq.Array(toInt64s(itemIDs)), pq.Array(toInt64s(childIDs)), at,
+    )
+    if err != nil {
+        return fmt.Errorf("error recording container history: %v", err)
+    }
+
+    return nil
+}
+
+// collectIDs runs an UPDATE ... RETURNING id and gathers the IDs.
+func collectIDs(tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
+    rows, err := tx.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    ids := make([]int, 0)
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err != nil {
+            return nil, err
+        }
+        ids = append(ids, id)
+    }
+
+    return ids, rows.Err()
+}
+
+func toInt64s(ids []int) []int64 {
+    out := make([]int64, len(ids))
+    for i, id := range ids {
+        out[i] = int64(id)
+    }
+    return out
+}
+
+func toInts(ids []int64) []int {
+    out := make([]int, len(ids))
+    for i, id := range ids {
+        out[i] = int(id)
+    }
+    return out
+}
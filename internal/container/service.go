@@ -2,37 +2,53 @@ package container
 
 import (
 	"fmt"
-	"math/rand"
+	"math"
+	"mime/multipart"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/chrisabs/storage/internal/label"
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
 	"github.com/chrisabs/storage/pkg/utils"
 )
 
 type Service struct {
-	repo *Repository
+	repo        *Repository
+	signer      *storage.URLSigner
+	labelPrefix string
+	qrBaseURL   string
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo *Repository, signer *storage.URLSigner, labelPrefix, qrBaseURL string) *Service {
+	return &Service{repo: repo, signer: signer, labelPrefix: labelPrefix, qrBaseURL: qrBaseURL}
 }
 
 func (s *Service) CreateContainer(userID int, req *CreateContainerRequest) (*models.Container, error) {
-    containerID := rand.Intn(10000)
-    qrString, qrImage, err := utils.GenerateQRCode(containerID)
+    symbology := req.Symbology
+    if symbology == "" {
+        symbology = label.SymbologyQR
+    }
+    if !label.ValidSymbology(symbology) {
+        return nil, label.ErrUnknownSymbology
+    }
+    if err := validateCapacity(req.Capacity); err != nil {
+        return nil, err
+    }
+
+    qrToken, err := utils.NewQRToken()
     if err != nil {
-        qrString = fmt.Sprintf("STQRAGE-CONTAINER-%d", containerID)
-        qrImage = ""
+        return nil, fmt.Errorf("failed to create container: %v", err)
     }
 
     container := &models.Container{
-        ID:          containerID,
         Name:        req.Name,
 		Description: req.Description,
-        QRCode:      qrString,
-        QRCodeImage: qrImage,
-        Number:      rand.Intn(1000),
+        QRCode:      qrToken,
+        Symbology:   symbology,
         Location:    req.Location,
+        Capacity:    req.Capacity,
         UserID:      userID,
         WorkspaceID: nil, 
         CreatedAt:   time.Now().UTC(),
@@ -43,22 +59,36 @@ func (s *Service) CreateContainer(userID int, req *CreateContainerRequest) (*mod
         container.WorkspaceID = req.WorkspaceID
     }
 
-	if err := s.repo.Create(container, req.Items); err != nil {
-		return nil, fmt.Errorf("failed to create container with items: %v", err)
+	// A nested container takes its parent's workspace and location when it is created
+	container.ParentContainerID = req.ParentContainerID
+	container.LocationID = req.LocationID
+
+	if err := s.repo.Create(container, s.labelPrefix, req.Items); err != nil {
+		return nil, fmt.Errorf("failed to create container with items: %w", err)
 	}
 
-	return s.repo.GetByID(container.ID)
+	return s.GetContainerByID(container.ID)
 }
 func (s *Service) GetContainerByID(id int) (*models.Container, error) {
 	container, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("error getting container: %v", err)
 	}
+
+	s.signer.SignContainer(container)
 	return container, nil
 }
 
 func (s *Service) GetContainersByUserID(userID int) ([]*models.Container, error) {
-	return s.repo.GetByUserID(userID)
+	containers, err := s.repo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, container := range containers {
+		s.signer.SignContainer(container)
+	}
+	return containers, nil
 }
 
 func (s *Service) UpdateContainer(id int, req *UpdateContainerRequest) (*models.Container, error) {
@@ -67,23 +97,326 @@ func (s *Service) UpdateContainer(id int, req *UpdateContainerRequest) (*models.
 		return nil, fmt.Errorf("container not found: %v", err)
 	}
 
+	// Nested containers always share their parent's workspace and location
+	if container.ParentContainerID != nil &&
+		(!sameID(container.WorkspaceID, req.WorkspaceID) || !sameID(container.LocationID, req.LocationID)) {
+		return nil, fmt.Errorf("nested containers follow their parent's workspace and location; move the container out first")
+	}
+
 	container.Name = req.Name
 	container.Description = req.Description
 	container.Location = req.Location
 	container.WorkspaceID = req.WorkspaceID
+	container.LocationID = req.LocationID
 	container.UpdatedAt = time.Now().UTC()
 
+	if req.Symbology != "" {
+		if !label.ValidSymbology(req.Symbology) {
+			return nil, label.ErrUnknownSymbology
+		}
+		container.Symbology = req.Symbology
+	}
+
+	if req.Capacity != nil {
+		if err := validateCapacity(req.Capacity); err != nil {
+			return nil, err
+		}
+		container.Capacity = req.Capacity
+	}
+
 	if err := s.repo.Update(container); err != nil {
-		return nil, fmt.Errorf("failed to update container: %v", err)
+		return nil, fmt.Errorf("failed to update container: %w", err)
 	}
 
-	return container, nil
+	// Reload so the fill level reflects any new capacity
+	return s.GetContainerByID(id)
+}
+
+func (s *Service) MoveContainer(id int, req *MoveContainerRequest) (*models.Container, error) {
+	if req.ParentContainerID != nil && *req.ParentContainerID == id {
+		return nil, ErrContainerCycle
+	}
+
+	if err := s.repo.Move(id, req.ParentContainerID); err != nil {
+		return nil, err
+	}
+
+	return s.GetContainerByID(id)
+}
+
+func sameID(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// GetContainersWithFreeSpace lists containers in a workspace with at least
+// minFree percent of their capacity left.
+func (s *Service) GetContainersWithFreeSpace(userID, workspaceID int, minFree float64) ([]*models.Container, error) {
+	if math.IsNaN(minFree) || minFree < 0 || minFree > 100 {
+		return nil, ErrInvalidFreeSpace
+	}
+
+	return s.repo.GetWithFreeSpace(userID, workspaceID, minFree)
+}
+
+// MergeContainers empties the sources into targetID and retires them.
+func (s *Service) MergeContainers(userID, targetID int, req *MergeContainersRequest) (*models.Container, error) {
+	sourceIDs := uniqueIDs(req.SourceIDs)
+	if len(sourceIDs) == 0 {
+		return nil, ErrInvalidMerge
+	}
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return nil, ErrInvalidMerge
+		}
+	}
+
+	if err := s.repo.Merge(userID, targetID, sourceIDs); err != nil {
+		return nil, err
+	}
+
+	return s.GetContainerByID(targetID)
+}
+
+// SplitContainer moves the chosen items out of sourceID into a new container
+// and returns the new one.
+func (s *Service) SplitContainer(userID, sourceID int, req *SplitContainerRequest) (*models.Container, error) {
+	itemIDs := uniqueIDs(req.ItemIDs)
+	if len(itemIDs) == 0 {
+		return nil, ErrEmptySplit
+	}
+
+	source, err := s.repo.GetByID(sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrContainerNotFound, err)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = source.Name
+	}
+
+	symbology := req.Symbology
+	if symbology == "" {
+		symbology = source.Symbology
+	}
+	if !label.ValidSymbology(symbology) {
+		return nil, label.ErrUnknownSymbology
+	}
+
+	qrToken, err := utils.NewQRToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to split container: %v", err)
+	}
+
+	container := &models.Container{
+		Name:        name,
+		Description: req.Description,
+		QRCode:      qrToken,
+		Symbology:   symbology,
+		Location:    source.Location,
+		UserID:      userID,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}
+
+	if err := s.repo.Split(sourceID, container, s.labelPrefix, itemIDs); err != nil {
+		return nil, err
+	}
+
+	return s.GetContainerByID(container.ID)
+}
+
+func (s *Service) GetHistory(id int) ([]Event, error) {
+	return s.repo.GetEvents(id)
+}
+
+// uniqueIDs drops repeated IDs, keeping the first occurrence.
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// UploadContainerImage stores a photo of the container through the same
+// deduplicated, quota-checked path as item photos.
+func (s *Service) UploadContainerImage(containerID, userID int, store *storage.S3Handler, file *multipart.FileHeader) error {
+    upload, err := store.ReadImage(file)
+    if err != nil {
+        return err
+    }
+
+    return s.repo.AddImage(containerID, userID, store, upload)
+}
+
+func (s *Service) DeleteContainerImage(containerID, imageID int) (*models.Container, error) {
+    if err := s.repo.DeleteImage(containerID, imageID); err != nil {
+        return nil, err
+    }
+
+    return s.GetContainerByID(containerID)
+}
+
+func (s *Service) ReorderContainerImages(containerID int, req *ReorderImagesRequest) (*models.Container, error) {
+    if err := s.repo.ReorderImages(containerID, uniqueIDs(req.ImageIDs)); err != nil {
+        return nil, err
+    }
+
+    return s.GetContainerByID(containerID)
+}
+
+func (s *Service) SetCoverImage(containerID int, req *SetCoverRequest) (*models.Container, error) {
+    if err := s.repo.SetCover(containerID, req.ImageID); err != nil {
+        return nil, err
+    }
+
+    return s.GetContainerByID(containerID)
 }
 
 func (s *Service) DeleteContainer(id int) error {
 	return s.repo.Delete(id)
 }
 
+// RotateQRCode gives a container a fresh token, so labels printed with the
+// old one stop resolving.
+func (s *Service) RotateQRCode(id int) (*models.Container, error) {
+	qrToken, err := utils.NewQRToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateQRCode(id, qrToken); err != nil {
+		return nil, err
+	}
+
+	return s.GetContainerByID(id)
+}
+
+// QRLink is the deep link printed on a container's label.
+func (s *Service) QRLink(container *models.Container) string {
+	return utils.QRLink(s.qrBaseURL, container.QRCode)
+}
+
+// Payload is what a label in the given symbology encodes. Barcodes carry just
+// the token to keep the bars short; QR and Data Matrix carry the deep link.
+// The QR lookup accepts either.
+func (s *Service) Payload(container *models.Container, symbology string) string {
+	if label.Linear(symbology) {
+		return container.QRCode
+	}
+	return s.QRLink(container)
+}
+
+// RenderCode draws a container's label code as an SVG or PNG image.
+func (s *Service) RenderCode(container *models.Container, format string, opts label.Options) ([]byte, error) {
+	payload := s.Payload(container, opts.Symbology)
+	if format == "svg" {
+		return label.RenderSVG(payload, opts)
+	}
+	return label.RenderPNG(payload, opts)
+}
+
+// NDEFMessage is the record to write to an NFC sticker for a container.
+func (s *Service) NDEFMessage(container *models.Container) []byte {
+	return label.NDEFMessage(s.QRLink(container))
+}
+
+// GetLabelContainer loads just what a label needs for one of userID's
+// containers, without the items, children and images GetContainerByID
+// gathers.
+func (s *Service) GetLabelContainer(userID, id int) (*models.Container, error) {
+	containers, err := s.repo.GetForLabels(userID, []int{id}, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	return containers[0], nil
+}
+
+// LabelSheet renders a PDF of labels for the requested containers, defaulting
+// to L7160 stock.
+func (s *Service) LabelSheet(userID int, req *LabelSheetRequest) ([]byte, error) {
+	if (len(req.ContainerIDs) == 0) == (req.WorkspaceID == nil) {
+		return nil, ErrLabelSelection
+	}
+	if len(req.ContainerIDs) > label.MaxSheetLabels {
+		return nil, label.ErrTooManyLabels
+	}
+	if req.Symbology != "" && !label.ValidSymbology(req.Symbology) {
+		return nil, label.ErrUnknownSymbology
+	}
+
+	var tpl label.Template
+	if req.Custom != nil {
+		tpl = *req.Custom
+	} else {
+		name := req.Template
+		if name == "" {
+			name = "L7160"
+		}
+
+		var err error
+		tpl, err = label.LookupTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Read one past the cap so an oversized workspace is still refused
+	containers, err := s.repo.GetForLabels(userID, req.ContainerIDs, req.WorkspaceID, label.MaxSheetLabels+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) > label.MaxSheetLabels {
+		return nil, label.ErrTooManyLabels
+	}
+
+	labels := make([]label.Label, len(containers))
+	for i, container := range containers {
+		number := container.Label
+		if number == "" {
+			number = strconv.Itoa(container.Number)
+		}
+
+		location := container.Location
+		if len(container.LocationPath) > 0 {
+			names := make([]string, len(container.LocationPath))
+			for j, crumb := range container.LocationPath {
+				names[j] = crumb.Name
+			}
+			location = strings.Join(names, " / ")
+		}
+
+		symbology := container.Symbology
+		if req.Symbology != "" {
+			symbology = req.Symbology
+		}
+
+		labels[i] = label.Label{
+			Symbology: symbology,
+			Payload:   s.Payload(container, symbology),
+			Name:      container.Name,
+			Number:    number,
+			Location:  location,
+		}
+	}
+
+	return label.RenderSheet(labels, tpl, req.StartPosition)
+}
+
 func (s *Service) GetContainerByQR(qrCode string) (*models.Container, error) {
-	return s.repo.GetByQR(qrCode)
+	container, err := s.repo.GetByQR(qrCode)
+	if err != nil {
+		return nil, err
+	}
+
+	s.signer.SignContainer(container)
+	return container, nil
 }
@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	MaxOriginalDimension = 2048
+	AvatarDimension      = 512
+	JPEGQuality          = 85
+	// MaxPixels bounds the decoded size of an upload, which can be far larger
+	// than the file itself
+	MaxPixels = 40_000_000
+)
+
+var ErrTooManyPixels = fmt.Errorf("image is larger than %d pixels", MaxPixels)
+
+type Size struct {
+	Name         string
+	MaxDimension int
+}
+
+var ThumbnailSizes = []Size{
+	{Name: "thumbnail", MaxDimension: 160},
+	{Name: "small", MaxDimension: 480},
+	{Name: "medium", MaxDimension: 1024},
+}
+
+type Encoded struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+type Variant struct {
+	Image Encoded
+	WebP  Encoded
+}
+
+type Result struct {
+	Original Encoded
+	Variants map[string]Variant
+}
+
+// Process decodes an uploaded image, rotates it upright according to its EXIF
+// orientation and re-encodes it no larger than maxDimension, which drops all
+// embedded metadata (GPS position, camera details). Each of sizes is rendered
+// in the original format and as WebP.
+func Process(data []byte, maxDimension int, sizes []Size) (*Result, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	decoded, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+
+	img := applyOrientation(toNRGBA(decoded), readOrientation(data))
+
+	// Formats that may carry transparency are kept lossless
+	keepPNG := format == "png" || format == "gif" || format == "webp"
+
+	original, err := encode(fit(img, maxDimension), keepPNG)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Original: *original,
+		Variants: make(map[string]Variant, len(sizes)),
+	}
+
+	for _, size := range sizes {
+		scaled := fit(img, size.MaxDimension)
+
+		encoded, err := encode(scaled, keepPNG)
+		if err != nil {
+			return nil, err
+		}
+
+		webp, err := encodeWebP(scaled)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Variants[size.Name] = Variant{Image: *encoded, WebP: *webp}
+	}
+
+	return result, nil
+}
+
+// TotalBytes is the storage consumed by the original and all renditions.
+func (r *Result) TotalBytes() int64 {
+	total := int64(len(r.Original.Data))
+	for _, variant := range r.Variants {
+		total += int64(len(variant.Image.Data) + len(variant.WebP.Data))
+	}
+	return total
+}
+
+func encode(img *image.NRGBA, asPNG bool) (*Encoded, error) {
+	var buf bytes.Buffer
+	encoded := &Encoded{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	if asPNG {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("error encoding png: %v", err)
+		}
+		encoded.ContentType = "image/png"
+		encoded.Extension = ".png"
+	} else {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+			return nil, fmt.Errorf("error encoding jpeg: %v", err)
+		}
+		encoded.ContentType = "image/jpeg"
+		encoded.Extension = ".jpg"
+	}
+
+	encoded.Data = buf.Bytes()
+	return encoded, nil
+}
+
+func encodeWebP(img *image.NRGBA) (*Encoded, error) {
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, fmt.Errorf("error encoding webp: %v", err)
+	}
+
+	return &Encoded{
+		Data:        buf.Bytes(),
+		ContentType: "image/webp",
+		Extension:   ".webp",
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
@@ -2,7 +2,9 @@ package item
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -33,10 +35,21 @@ func NewHandler(service *Service, containerService ContainerService, authMiddlew
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/items", h.authMiddleware.AuthHandler(h.handleGetItems)).Methods("GET")
 	router.HandleFunc("/items", h.authMiddleware.AuthHandler(h.handleCreateItem)).Methods("POST")
+	router.HandleFunc("/items/move", h.authMiddleware.AuthHandler(h.handleBulkMove)).Methods("POST")
+	router.HandleFunc("/items/low-stock", h.authMiddleware.AuthHandler(h.handleGetLowStock)).Methods("GET")
+	router.HandleFunc("/items/expiring", h.authMiddleware.AuthHandler(h.handleGetExpiring)).Methods("GET")
+	router.HandleFunc("/items/shopping-list", h.authMiddleware.AuthHandler(h.handleGetShoppingList)).Methods("GET")
+	router.HandleFunc("/items/shopping-list/restock", h.authMiddleware.AuthHandler(h.handleRestock)).Methods("POST")
 
 	router.HandleFunc("/items/{id}", h.authMiddleware.AuthHandler(h.handleGetItem)).Methods("GET")
 	router.HandleFunc("/items/{id}", h.authMiddleware.AuthHandler(h.handleUpdateItem)).Methods("PUT")
 	router.HandleFunc("/items/{id}", h.authMiddleware.AuthHandler(h.handleDeleteItem)).Methods("DELETE")
+	router.HandleFunc("/items/{id}/adjust", h.authMiddleware.AuthHandler(h.handleAdjustQuantity)).Methods("POST")
+	router.HandleFunc("/items/{id}/movements", h.authMiddleware.AuthHandler(h.handleGetMovements)).Methods("GET")
+	router.HandleFunc("/items/{id}/batches", h.authMiddleware.AuthHandler(h.handleAddBatch)).Methods("POST")
+	router.HandleFunc("/items/{id}/batches/{batchId}", h.authMiddleware.AuthHandler(h.handleDiscardBatch)).Methods("DELETE")
+	router.HandleFunc("/items/{id}/receipts", h.authMiddleware.AuthHandler(h.handleUploadReceipt)).Methods("POST")
+	router.HandleFunc("/items/{id}/receipts/{receiptId}", h.authMiddleware.AuthHandler(h.handleDeleteReceipt)).Methods("DELETE")
 }
 
 func (h *Handler) handleGetItems(w http.ResponseWriter, r *http.Request) {
@@ -77,16 +90,43 @@ func (h *Handler) handleCreateItem(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusForbidden, "access denied")
 			return
 		}
+		if container.RetiredAt != nil {
+			writeError(w, http.StatusConflict, "container has been merged into another and is retired")
+			return
+		}
 	}
 
 	item, err := h.service.CreateItem(&req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, valueErrorStatus(err), err.Error())
 		return
 	}
 	writeJSON(w, http.StatusCreated, item)
 }
 
+// handleBulkMove moves many items at once. The response lists every item as
+// moved, unchanged or failed; only a bad destination fails the request.
+func (h *Handler) handleBulkMove(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	var req BulkMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	result, err := h.service.BulkMoveItems(userID, &req)
+	if err != nil {
+		writeError(w, bulkMoveErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 func (h *Handler) handleGetItem(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(r.Header.Get("UserId"))
 	if err != nil {
@@ -182,14 +222,8 @@ func (h *Handler) handleUpdateItem(w http.ResponseWriter, r *http.Request) {
             }
 
             for _, fileHeader := range files {
-                url, err := s3Handler.UploadFile(fileHeader, fmt.Sprintf("items/%d", itemID))
-                if err != nil {
-                    writeError(w, http.StatusInternalServerError, err.Error())
-                    return
-                }
-
-                if err := h.service.AddItemImage(itemID, url); err != nil {
-                    writeError(w, http.StatusInternalServerError, err.Error())
+                if err := h.service.UploadItemImage(itemID, userID, s3Handler, fileHeader); err != nil {
+                    writeError(w, storage.UploadErrorStatus(err), err.Error())
                     return
                 }
             }
@@ -213,9 +247,9 @@ func (h *Handler) handleUpdateItem(w http.ResponseWriter, r *http.Request) {
     }
 
     // Update the item
-    updatedItem, err := h.service.UpdateItem(itemID, &req)
+    updatedItem, err := h.service.UpdateItem(userID, itemID, &req)
     if err != nil {
-        writeError(w, http.StatusInternalServerError, err.Error())
+        writeError(w, valueErrorStatus(err), err.Error())
         return
     }
  
@@ -260,6 +294,437 @@ func (h *Handler) handleDeleteItem(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]int{"deleted": itemID})
 }
 
+func (h *Handler) handleAdjustQuantity(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	itemID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	if status, err := h.authorizeItem(userID, itemID); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	var req AdjustQuantityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	movement, err := h.service.AdjustQuantity(userID, itemID, &req)
+	if err != nil {
+		writeError(w, adjustErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, movement)
+}
+
+func (h *Handler) handleGetMovements(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	itemID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	if status, err := h.authorizeItem(userID, itemID); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	movements, err := h.service.GetMovements(itemID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, movements)
+}
+
+func (h *Handler) handleAddBatch(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	itemID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	if status, err := h.authorizeItem(userID, itemID); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	var req AddBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	batch, err := h.service.AddBatch(userID, itemID, &req)
+	if err != nil {
+		writeError(w, batchErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, batch)
+}
+
+func (h *Handler) handleDiscardBatch(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	itemID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	batchID, err := strconv.Atoi(mux.Vars(r)["batchId"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid batch ID")
+		return
+	}
+
+	if status, err := h.authorizeItem(userID, itemID); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	movement, err := h.service.DiscardBatch(userID, itemID, batchID)
+	if err != nil {
+		writeError(w, batchErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": batchID, "movement": movement})
+}
+
+// handleUploadReceipt attaches a receipt sent as the multipart field
+// "receipt".
+func (h *Handler) handleUploadReceipt(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	itemID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	if status, err := h.authorizeItem(userID, itemID); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse form: %v", err))
+		return
+	}
+
+	_, fileHeader, err := r.FormFile("receipt")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "missing receipt file")
+		return
+	}
+
+	s3Handler, err := storage.NewS3Handler()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	receipt, err := h.service.UploadReceipt(itemID, userID, s3Handler, fileHeader)
+	if err != nil {
+		writeError(w, storage.UploadErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, receipt)
+}
+
+func (h *Handler) handleDeleteReceipt(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	itemID, err := getIDFromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid item ID")
+		return
+	}
+
+	receiptID, err := strconv.Atoi(mux.Vars(r)["receiptId"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid receipt ID")
+		return
+	}
+
+	if status, err := h.authorizeItem(userID, itemID); err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	if err := h.service.DeleteReceipt(itemID, receiptID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrReceiptNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"deleted": receiptID})
+}
+
+func (h *Handler) handleGetExpiring(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	batches, err := h.service.GetExpiring(userID, r.URL.Query().Get("within"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidWithin) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, batches)
+}
+
+// authorizeItem checks that the item exists and, if it is in a container,
+// that the container belongs to userID.
+func (h *Handler) authorizeItem(userID, itemID int) (int, error) {
+	item, err := h.service.GetItemByID(itemID)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	// Items outside any container have no owner, so nobody may change them
+	if item.ContainerID == nil {
+		return http.StatusForbidden, errors.New("access denied")
+	}
+
+	container, err := h.containerService.GetContainerByID(*item.ContainerID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if container.UserID != userID {
+		return http.StatusForbidden, errors.New("access denied")
+	}
+	return http.StatusOK, nil
+}
+
+func adjustErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidDelta), errors.Is(err, ErrInvalidReason),
+		errors.Is(err, ErrReasonDirection), errors.Is(err, ErrNoteTooLong),
+		errors.Is(err, ErrFractionalQuantity), errors.Is(err, ErrInvalidQuantity):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInsufficientStock):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *Handler) handleGetLowStock(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	workspaceID, err := getWorkspaceFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := h.service.GetLowStock(userID, workspaceID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h *Handler) handleGetShoppingList(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	workspaceID, err := getWorkspaceFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+	if format != FormatJSON && format != FormatText && format != FormatCSV {
+		writeError(w, http.StatusBadRequest, ErrInvalidFormat.Error())
+		return
+	}
+
+	list, err := h.service.GetShoppingList(userID, workspaceID, r.URL.Query().Get("groupBy"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidGroupBy) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	switch format {
+	case FormatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="shopping-list.txt"`)
+		if err := WriteShoppingListText(w, list); err != nil {
+			log.Printf("Error writing shopping list: %v", err)
+		}
+	case FormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="shopping-list.csv"`)
+		if err := WriteShoppingListCSV(w, list); err != nil {
+			log.Printf("Error writing shopping list: %v", err)
+		}
+	default:
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+func (h *Handler) handleRestock(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("UserId"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	var req RestockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	movements, err := h.service.Restock(userID, &req)
+	if err != nil {
+		writeError(w, restockErrorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, movements)
+}
+
+// valueErrorStatus reports bad quantities and purchase details, unusable
+// destination containers and conflicting stock as client errors. Other
+// create and update failures stay server errors as before.
+func valueErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidPrice), errors.Is(err, ErrUnknownCurrency),
+		errors.Is(err, ErrInvalidPurchaseDate), errors.Is(err, ErrVendorTooLong),
+		errors.Is(err, ErrInvalidQuantity), errors.Is(err, ErrFractionalQuantity),
+		errors.Is(err, ErrInvalidThreshold), errors.Is(err, ErrUnitDimension):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrDestinationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDestinationRetired), errors.Is(err, ErrQuantityLent),
+		errors.Is(err, ErrUnitInUse):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func batchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBatchNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrBatchQuantity), errors.Is(err, ErrInvalidExpiry),
+		errors.Is(err, ErrInvalidDateKind), errors.Is(err, ErrLotTooLong),
+		errors.Is(err, ErrFractionalQuantity), errors.Is(err, ErrInvalidQuantity):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInsufficientStock):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func restockErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRestockNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNothingToRestock), errors.Is(err, ErrTooManyRestock), errors.Is(err, ErrRestockQuantity),
+		errors.Is(err, ErrFractionalQuantity), errors.Is(err, ErrInvalidQuantity):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// getWorkspaceFilter reads the optional workspaceId query parameter.
+func getWorkspaceFilter(r *http.Request) (*int, error) {
+	raw := r.URL.Query().Get("workspaceId")
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, errors.New("invalid workspace ID")
+	}
+	return &id, nil
+}
+
+func bulkMoveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNoItems), errors.Is(err, ErrTooManyItems):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrDestinationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrDestinationRetired):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func getIDFromRequest(r *http.Request) (int, error) {
 	vars := mux.Vars(r)
 	return strconv.Atoi(vars["id"])
@@ -1,23 +1,248 @@
 package item
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+// MaxBulkMove bounds a single bulk move request.
+const MaxBulkMove = 500
+
+var (
+    ErrNoItems             = errors.New("at least one item ID is required")
+    ErrTooManyItems        = fmt.Errorf("at most %d items can be moved at once", MaxBulkMove)
+    ErrDestinationNotFound = errors.New("destination container not found")
+    ErrDestinationRetired  = errors.New("destination container has been merged into another and is retired")
+    ErrInvalidDelta        = errors.New("delta must be a non-zero number")
+    ErrInvalidQuantity     = fmt.Errorf("quantity must be between 0 and %d", maxQuantity)
+    ErrFractionalQuantity  = errors.New("items counted in pieces need whole-number quantities")
+    ErrInvalidReason       = errors.New("reason must be one of consumed, purchased, found, lost or correction")
+    ErrReasonDirection     = errors.New("consumed and lost take stock away; purchased and found add it")
+    ErrInsufficientStock   = errors.New("not enough in stock for this adjustment")
+    ErrNoteTooLong         = fmt.Errorf("note must be at most %d characters", maxNoteLength)
+    ErrInvalidThreshold    = fmt.Errorf("minQuantity and reorderQuantity must be between 0 and %d", maxQuantity)
+    ErrStoreTooLong        = fmt.Errorf("preferredStore must be at most %d characters", maxStoreLength)
+    ErrInvalidGroupBy      = errors.New("groupBy must be tag or store")
+    ErrInvalidFormat       = errors.New("format must be json, text or csv")
+    ErrNothingToRestock    = errors.New("at least one item is required")
+    ErrTooManyRestock      = fmt.Errorf("at most %d items can be restocked at once", MaxBulkMove)
+    ErrRestockQuantity     = fmt.Errorf("restock quantity must be more than 0 and at most %d", maxQuantity)
+    ErrRestockNotFound     = errors.New("item not found in your containers")
+    ErrBatchNotFound       = errors.New("batch not found")
+    ErrBatchQuantity       = fmt.Errorf("batch quantity must be more than 0 and at most %d", maxQuantity)
+    ErrInvalidExpiry       = errors.New("expiresOn must be a date in the form YYYY-MM-DD")
+    ErrInvalidDateKind     = errors.New("dateKind must be expiry or best_before")
+    ErrLotTooLong          = fmt.Errorf("lot must be at most %d characters", maxLotLength)
+    ErrInvalidWithin       = fmt.Errorf("within must be a number of days or weeks, such as 30d or 2w, up to %d days", maxExpiringDays)
+    ErrInvalidPrice        = fmt.Errorf("purchasePrice and estimatedValue must be between 0 and %d", maxPrice)
+    ErrUnknownCurrency     = errors.New("currency is not one with a configured exchange rate")
+    ErrInvalidPurchaseDate = errors.New("purchaseDate must be a date in the form YYYY-MM-DD")
+    ErrVendorTooLong       = fmt.Errorf("vendor must be at most %d characters", maxVendorLength)
+    ErrReceiptNotFound     = errors.New("receipt not found")
+    ErrQuantityLent        = errors.New("quantity cannot be less than the amount currently lent out")
+    ErrUnitDimension       = errors.New("unit must measure the same thing as the item's current unit")
+    ErrUnitInUse           = errors.New("unit cannot change while the item has batches or is lent out")
+)
+
+// Reasons a stock movement is recorded for. Edits through UpdateItem are
+// recorded as corrections.
+const (
+    ReasonConsumed   = "consumed"
+    ReasonPurchased  = "purchased"
+    ReasonFound      = "found"
+    ReasonLost       = "lost"
+    ReasonCorrection = "correction"
+)
+
+const (
+    maxNoteLength        = 1000
+    defaultMovementLimit = 50
+    maxMovementLimit     = 500
+    maxStoreLength       = 100
+    maxLotLength         = 64
+    defaultExpiringDays  = 30
+    maxExpiringDays      = 3650
+    maxVendorLength      = 100
+    maxPrice             = 1000000000000
+    // Quantities are stored as NUMERIC(14, 3)
+    maxQuantity          = 99999999999
+    maxFilenameLength    = 255
+)
+
+// What a batch date means.
+const (
+    DateKindExpiry     = "expiry"
+    DateKindBestBefore = "best_before"
+)
+
+// Shopping list groupings and export formats.
+const (
+    GroupByTag   = "tag"
+    GroupByStore = "store"
+
+    FormatJSON = "json"
+    FormatText = "text"
+    FormatCSV  = "csv"
+)
+
+// Upper bounds matching the item size columns.
+const (
+    maxDimensionCm = 100000
+    maxWeightKg    = 1000000
+)
+
 type CreateItemRequest struct {
-    Name        string   `json:"name"`
-    Description string   `json:"description"`
-    Quantity    int      `json:"quantity"`
-    ContainerID *int     `json:"containerId,omitempty"`
-    TagNames    []string `json:"tagNames"`
+    Name            string   `json:"name"`
+    Description     string   `json:"description"`
+    Quantity        float64  `json:"quantity"`
+    Unit            string   `json:"unit,omitempty"`
+    Barcode         string   `json:"barcode,omitempty"`
+    WeightKg        *float64 `json:"weightKg,omitempty"`
+    WidthCm         *float64 `json:"widthCm,omitempty"`
+    DepthCm         *float64 `json:"depthCm,omitempty"`
+    HeightCm        *float64 `json:"heightCm,omitempty"`
+    MinQuantity     *float64 `json:"minQuantity,omitempty"`
+    ReorderQuantity *float64 `json:"reorderQuantity,omitempty"`
+    PreferredStore  string   `json:"preferredStore,omitempty"`
+    PurchasePrice   *float64 `json:"purchasePrice,omitempty"`
+    Currency        string   `json:"currency,omitempty"`
+    PurchaseDate    string   `json:"purchaseDate,omitempty"`
+    Vendor          string   `json:"vendor,omitempty"`
+    EstimatedValue  *float64 `json:"estimatedValue,omitempty"`
+    ContainerID     *int     `json:"containerId,omitempty"`
+    TagNames        []string `json:"tagNames"`
 }
 
+// UpdateItemRequest replaces an item's details. Quantities are given in the
+// item's current unit; a new Unit converts them.
 type UpdateItemRequest struct {
-    Name        string   `json:"name"`
-    Description string   `json:"description"`
-    Quantity    int      `json:"quantity"`
-    ContainerID *int     `json:"containerId,omitempty"`
-    Tags        []int    `json:"tags,omitempty"`
-    ImagesToDelete []string `json:"imagesToDelete,omitempty"`
+    Name            string   `json:"name"`
+    Description     string   `json:"description"`
+    Quantity        float64  `json:"quantity"`
+    Unit            string   `json:"unit,omitempty"`
+    Barcode         string   `json:"barcode,omitempty"`
+    WeightKg        *float64 `json:"weightKg,omitempty"`
+    WidthCm         *float64 `json:"widthCm,omitempty"`
+    DepthCm         *float64 `json:"depthCm,omitempty"`
+    HeightCm        *float64 `json:"heightCm,omitempty"`
+    MinQuantity     *float64 `json:"minQuantity,omitempty"`
+    ReorderQuantity *float64 `json:"reorderQuantity,omitempty"`
+    PreferredStore  string   `json:"preferredStore,omitempty"`
+    PurchasePrice   *float64 `json:"purchasePrice,omitempty"`
+    Currency        string   `json:"currency,omitempty"`
+    PurchaseDate    string   `json:"purchaseDate,omitempty"`
+    Vendor          string   `json:"vendor,omitempty"`
+    EstimatedValue  *float64 `json:"estimatedValue,omitempty"`
+    ContainerID     *int     `json:"containerId,omitempty"`
+    Tags            []int    `json:"tags,omitempty"`
+    ImagesToDelete  []string `json:"imagesToDelete,omitempty"`
 }
 
 type AddImageRequest struct {
     ItemID      int    `json:"itemId"`
     ImageURL    string `json:"imageUrl"`
-}
\ No newline at end of file
+}
+
+type BulkMoveRequest struct {
+    ItemIDs     []int `json:"itemIds"`
+    ContainerID int   `json:"containerId"`
+}
+
+// BulkMoveResult reports what happened to every requested item. Items that
+// were already in the destination are listed as unchanged rather than failed.
+type BulkMoveResult struct {
+    ContainerID int               `json:"containerId"`
+    Moved       []int             `json:"moved"`
+    Unchanged   []int             `json:"unchanged"`
+    Failed      []BulkMoveFailure `json:"failed"`
+}
+
+type BulkMoveFailure struct {
+    ItemID int    `json:"itemId"`
+    Error  string `json:"error"`
+}
+
+// AdjustQuantityRequest changes an item's quantity by Delta rather than
+// overwriting it, so concurrent adjustments all count.
+type AdjustQuantityRequest struct {
+    Delta  float64 `json:"delta"`
+    Reason string  `json:"reason"`
+    Note   string  `json:"note"`
+}
+
+// Movement is one entry in an item's stock ledger.
+type Movement struct {
+    ID            int       `json:"id"`
+    ItemID        int       `json:"itemId"`
+    UserID        *int      `json:"userId,omitempty"`
+    Delta         float64   `json:"delta"`
+    Reason        string    `json:"reason"`
+    Note          string    `json:"note,omitempty"`
+    QuantityAfter float64   `json:"quantityAfter"`
+    Unit          string    `json:"unit"`
+    CreatedAt     time.Time `json:"createdAt"`
+}
+
+// LowStockItem is an item whose quantity has fallen below its minimum.
+// ToBuy is the reorder quantity, or the shortfall when that is larger or
+// unset.
+type LowStockItem struct {
+    ItemID          int      `json:"itemId"`
+    Name            string   `json:"name"`
+    Quantity        float64  `json:"quantity"`
+    Unit            string   `json:"unit"`
+    MinQuantity     float64  `json:"minQuantity"`
+    ReorderQuantity *float64 `json:"reorderQuantity,omitempty"`
+    ToBuy           float64  `json:"toBuy"`
+    PreferredStore  string   `json:"preferredStore,omitempty"`
+    ContainerID     int      `json:"containerId"`
+    ContainerName   string   `json:"containerName"`
+    WorkspaceID     *int     `json:"workspaceId,omitempty"`
+    WorkspaceName   string   `json:"workspaceName,omitempty"`
+    Tags            []string `json:"tags"`
+}
+
+// ShoppingList is the low-stock view grouped for taking to the shops.
+type ShoppingList struct {
+    GroupBy string              `json:"groupBy"`
+    Groups  []ShoppingListGroup `json:"groups"`
+}
+
+type ShoppingListGroup struct {
+    Name  string         `json:"name"`
+    Items []LowStockItem `json:"items"`
+}
+
+// RestockRequest records purchases for items on the shopping list. Lines
+// without a quantity are restocked by their ToBuy amount.
+type RestockRequest struct {
+    Items []RestockLine `json:"items"`
+}
+
+type RestockLine struct {
+    ItemID   int      `json:"itemId"`
+    Quantity *float64 `json:"quantity,omitempty"`
+}
+
+// AddBatchRequest adds Quantity of new stock that expires on ExpiresOn,
+// given as YYYY-MM-DD.
+type AddBatchRequest struct {
+    Quantity  float64 `json:"quantity"`
+    ExpiresOn string  `json:"expiresOn"`
+    DateKind  string  `json:"dateKind,omitempty"`
+    Lot       string  `json:"lot,omitempty"`
+}
+
+// ExpiringBatch is a batch on the expiring list with enough of its item to
+// go and find it. DaysLeft is negative once the date has passed.
+type ExpiringBatch struct {
+    models.Batch
+    DaysLeft      int    `json:"daysLeft"`
+    ItemName      string `json:"itemName"`
+    Unit          string `json:"unit"`
+    ContainerID   int    `json:"containerId"`
+    ContainerName string `json:"containerName"`
+}
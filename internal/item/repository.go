@@ -4,9 +4,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
+	"github.com/chrisabs/storage/internal/blob"
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
+	"github.com/lib/pq"
 )
 
 type Repository struct {
@@ -25,8 +29,12 @@ func (r *Repository) Create(item *models.Item, tagNames []string) (*models.Item,
     defer tx.Rollback()
 
     itemQuery := `
-        INSERT INTO item (name, description, quantity, container_id, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO item (name, description, quantity, barcode, container_id, created_at, updated_at,
+                          weight_kg, width_cm, depth_cm, height_cm,
+                          min_quantity, reorder_quantity, preferred_store, unit,
+                          purchase_price, currency, purchase_date, vendor, estimated_value)
+        VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11, $12, $13, NULLIF($14, ''), $15,
+                $16, NULLIF($17, ''), NULLIF($18, '')::date, NULLIF($19, ''), $20)
         RETURNING id, created_at, updated_at`
 
     err = tx.QueryRow(
@@ -34,9 +42,23 @@ func (r *Repository) Create(item *models.Item, tagNames []string) (*models.Item,
         item.Name,
         item.Description,
         item.Quantity,
+        item.Barcode,
         item.ContainerID,
         time.Now().UTC(),
         time.Now().UTC(),
+        item.WeightKg,
+        item.WidthCm,
+        item.DepthCm,
+        item.HeightCm,
+        item.MinQuantity,
+        item.ReorderQuantity,
+        item.PreferredStore,
+        item.Unit,
+        item.PurchasePrice,
+        item.Currency,
+        item.PurchaseDate,
+        item.Vendor,
+        item.EstimatedValue,
     ).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
 
     if err != nil {
@@ -84,6 +106,10 @@ func (r *Repository) GetByID(id int) (*models.Item, error) {
                    jsonb_agg(
                        jsonb_build_object(
                            'url', url,
+                           'width', width,
+                           'height', height,
+                           'byteSize', byte_size,
+                           'variants', variants,
                            'displayOrder', display_order,
                            'createdAt', created_at,
                            'updatedAt', updated_at
@@ -92,7 +118,11 @@ func (r *Repository) GetByID(id int) (*models.Item, error) {
             FROM item_image
             GROUP BY item_id
         )
-        SELECT i.id, i.name, i.description, i.quantity, 
+        SELECT i.id, i.name, i.description, i.quantity, i.unit,
+               COALESCE(i.barcode, ''), i.weight_kg, i.width_cm, i.depth_cm, i.height_cm,
+               i.min_quantity, i.reorder_quantity, COALESCE(i.preferred_store, ''),
+               i.purchase_price, COALESCE(i.currency, ''), COALESCE(to_char(i.purchase_date, 'YYYY-MM-DD'), ''),
+               COALESCE(i.vendor, ''), i.estimated_value,
                i.container_id, i.created_at, i.updated_at,
                COALESCE(img.images, '[]'::jsonb) as images,
                COALESCE(
@@ -101,11 +131,12 @@ func (r *Repository) GetByID(id int) (*models.Item, error) {
                         'name', c.name,
                         'description', c.description,
                         'qrCode', c.qr_code,
-                        'qrCodeImage', c.qr_code_image,
                         'number', c.number,
+                        'label', c.label,
                         'location', c.location,
                         'userId', c.user_id,
                         'workspaceId', c.workspace_id,
+                        'parentContainerId', c.parent_container_id,
                         'workspace', CASE 
                             WHEN w.id IS NOT NULL THEN
                                 jsonb_build_object(
@@ -134,7 +165,24 @@ func (r *Repository) GetByID(id int) (*models.Item, error) {
                        )
                    ) FILTER (WHERE t.id IS NOT NULL),
                    '[]'
-               ) as tags
+               ) as tags,
+               container_path(i.container_id) as path,
+               open_checkout(NULL, i.id) as checkout,
+               outstanding_loans(i.id) as loans,
+               item_batches(i.id) as batches,
+               COALESCE((
+                   SELECT jsonb_agg(jsonb_build_object(
+                       'id', r.id,
+                       'itemId', r.item_id,
+                       'url', r.url,
+                       'filename', r.filename,
+                       'contentType', r.content_type,
+                       'byteSize', r.byte_size,
+                       'createdAt', r.created_at
+                   ) ORDER BY r.created_at, r.id)
+                   FROM item_receipt r
+                   WHERE r.item_id = i.id
+               ), '[]'::jsonb) as receipts
         FROM item i
         LEFT JOIN item_images img ON i.id = img.item_id
         LEFT JOIN container c ON i.container_id = c.id
@@ -142,20 +190,26 @@ func (r *Repository) GetByID(id int) (*models.Item, error) {
         LEFT JOIN item_tag it ON i.id = it.item_id
         LEFT JOIN tag t ON it.tag_id = t.id
         WHERE i.id = $1
-        GROUP BY i.id, i.name, i.description, i.quantity, 
+        GROUP BY i.id, i.name, i.description, i.quantity, i.unit,
+                 i.barcode, i.weight_kg, i.width_cm, i.depth_cm, i.height_cm,
+                 i.min_quantity, i.reorder_quantity, i.preferred_store,
+                 i.purchase_price, i.currency, i.purchase_date, i.vendor, i.estimated_value,
                  i.container_id, i.created_at, i.updated_at,
                  img.images,
-                 c.id, c.name, c.description, c.qr_code, c.qr_code_image, c.number, c.location,
-                 c.user_id, c.workspace_id, c.created_at, c.updated_at,
+                 c.id, c.name, c.description, c.qr_code, c.number, c.label, c.location,
+                 c.user_id, c.workspace_id, c.parent_container_id, c.created_at, c.updated_at,
                  w.id, w.name, w.description, w.user_id, w.created_at, w.updated_at`
 
     item := new(models.Item)
-    var imagesJSON, containerJSON, tagsJSON []byte
+    var imagesJSON, containerJSON, tagsJSON, pathJSON, checkoutJSON, loansJSON, batchesJSON, receiptsJSON []byte
 
     err := r.db.QueryRow(query, id).Scan(
         &item.ID, &item.Name, &item.Description,
-        &item.Quantity, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
-        &imagesJSON, &containerJSON, &tagsJSON,
+        &item.Quantity, &item.Unit, &item.Barcode, &item.WeightKg, &item.WidthCm, &item.DepthCm, &item.HeightCm,
+        &item.MinQuantity, &item.ReorderQuantity, &item.PreferredStore,
+        &item.PurchasePrice, &item.Currency, &item.PurchaseDate, &item.Vendor, &item.EstimatedValue,
+        &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
+        &imagesJSON, &containerJSON, &tagsJSON, &pathJSON, &checkoutJSON, &loansJSON, &batchesJSON, &receiptsJSON,
     )
 
     if err == sql.ErrNoRows {
@@ -179,6 +233,37 @@ func (r *Repository) GetByID(id int) (*models.Item, error) {
         return nil, fmt.Errorf("error parsing tags: %v", err)
     }
 
+    if err := json.Unmarshal(pathJSON, &item.Path); err != nil {
+        return nil, fmt.Errorf("error parsing path: %v", err)
+    }
+
+    if checkoutJSON != nil {
+        item.CheckedOut = new(models.Checkout)
+        if err := json.Unmarshal(checkoutJSON, item.CheckedOut); err != nil {
+            return nil, fmt.Errorf("error parsing checkout: %v", err)
+        }
+    }
+
+    if err := json.Unmarshal(loansJSON, &item.Loans); err != nil {
+        return nil, fmt.Errorf("error parsing loans: %v", err)
+    }
+
+    if err := json.Unmarshal(batchesJSON, &item.Batches); err != nil {
+        return nil, fmt.Errorf("error parsing batches: %v", err)
+    }
+
+    if err := json.Unmarshal(receiptsJSON, &item.Receipts); err != nil {
+        return nil, fmt.Errorf("error parsing receipts: %v", err)
+    }
+
+    item.LowStock = item.MinQuantity != nil && item.Quantity < *item.MinQuantity
+
+    available := item.Quantity
+    for _, loan := range item.Loans {
+        available -= loan.Quantity
+    }
+    item.AvailableQuantity = &available
+
     return item, nil
 }
 
@@ -189,6 +274,10 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Item, error) {
                    jsonb_agg(
                        jsonb_build_object(
                            'url', url,
+                           'width', width,
+                           'height', height,
+                           'byteSize', byte_size,
+                           'variants', variants,
                            'displayOrder', display_order,
                            'createdAt', created_at,
                            'updatedAt', updated_at
@@ -197,7 +286,11 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Item, error) {
             FROM item_image
             GROUP BY item_id
         )
-        SELECT DISTINCT i.id, i.name, i.description, i.quantity, 
+        SELECT DISTINCT i.id, i.name, i.description, i.quantity, i.unit,
+               COALESCE(i.barcode, ''), i.weight_kg, i.width_cm, i.depth_cm, i.height_cm,
+               i.min_quantity, i.reorder_quantity, COALESCE(i.preferred_store, ''),
+               i.purchase_price, COALESCE(i.currency, ''), COALESCE(to_char(i.purchase_date, 'YYYY-MM-DD'), ''),
+               COALESCE(i.vendor, ''), i.estimated_value,
                i.container_id, i.created_at, i.updated_at,
                COALESCE(img.images, '[]'::jsonb) as images,
                COALESCE(
@@ -206,11 +299,12 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Item, error) {
                         'name', c.name,
                         'description', c.description,
                         'qrCode', c.qr_code,
-                        'qrCodeImage', c.qr_code_image,
                         'number', c.number,
+                        'label', c.label,
                         'location', c.location,
                         'userId', c.user_id,
                         'workspaceId', c.workspace_id,
+                        'parentContainerId', c.parent_container_id,
                         'workspace', CASE 
                             WHEN w.id IS NOT NULL THEN
                                 jsonb_build_object(
@@ -239,7 +333,8 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Item, error) {
                        )
                    ) FILTER (WHERE t.id IS NOT NULL),
                    '[]'
-               ) as tags
+               ) as tags,
+               container_path(i.container_id) as path
         FROM item i
         LEFT JOIN item_images img ON i.id = img.item_id
         LEFT JOIN container c ON i.container_id = c.id
@@ -247,11 +342,14 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Item, error) {
         LEFT JOIN item_tag it ON i.id = it.item_id
         LEFT JOIN tag t ON it.tag_id = t.id
         WHERE c.user_id = $1 OR c.user_id IS NULL
-        GROUP BY i.id, i.name, i.description, i.quantity, 
+        GROUP BY i.id, i.name, i.description, i.quantity, i.unit,
+                 i.barcode, i.weight_kg, i.width_cm, i.depth_cm, i.height_cm,
+                 i.min_quantity, i.reorder_quantity, i.preferred_store,
+                 i.purchase_price, i.currency, i.purchase_date, i.vendor, i.estimated_value,
                  i.container_id, i.created_at, i.updated_at,
                  img.images,
-                 c.id, c.name, c.description, c.qr_code, c.qr_code_image, c.number, c.location,
-                 c.user_id, c.workspace_id, c.created_at, c.updated_at,
+                 c.id, c.name, c.description, c.qr_code, c.number, c.label, c.location,
+                 c.user_id, c.workspace_id, c.parent_container_id, c.created_at, c.updated_at,
                  w.id, w.name, w.description, w.user_id, w.created_at, w.updated_at
         ORDER BY i.created_at DESC`
 
@@ -264,12 +362,15 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Item, error) {
     var items []*models.Item
     for rows.Next() {
         item := new(models.Item)
-        var imagesJSON, containerJSON, tagsJSON []byte
+        var imagesJSON, containerJSON, tagsJSON, pathJSON []byte
 
         err := rows.Scan(
             &item.ID, &item.Name, &item.Description,
-            &item.Quantity, &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
-            &imagesJSON, &containerJSON, &tagsJSON,
+            &item.Quantity, &item.Unit, &item.Barcode, &item.WeightKg, &item.WidthCm, &item.DepthCm, &item.HeightCm,
+            &item.MinQuantity, &item.ReorderQuantity, &item.PreferredStore,
+            &item.PurchasePrice, &item.Currency, &item.PurchaseDate, &item.Vendor, &item.EstimatedValue,
+            &item.ContainerID, &item.CreatedAt, &item.UpdatedAt,
+            &imagesJSON, &containerJSON, &tagsJSON, &pathJSON,
         )
         if err != nil {
             return nil, err
@@ -289,23 +390,89 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Item, error) {
             return nil, fmt.Errorf("error parsing tags: %v", err)
         }
 
+        if err := json.Unmarshal(pathJSON, &item.Path); err != nil {
+            return nil, fmt.Errorf("error parsing path: %v", err)
+        }
+
+        item.LowStock = item.MinQuantity != nil && item.Quantity < *item.MinQuantity
+
         items = append(items, item)
     }
 
     return items, nil
 }
 
-func (r *Repository) Update(item *models.Item) error {
+// Update saves the item. A changed quantity is recorded in the stock ledger
+// as a correction by userID.
+func (r *Repository) Update(userID int, item *models.Item) error {
     tx, err := r.db.Begin()
     if err != nil {
         return fmt.Errorf("error starting transaction: %v", err)
     }
     defer tx.Rollback()
 
+    var previousQuantity float64
+    var previousContainerID *int
+    var previousUnit string
+    err = tx.QueryRow(
+        `SELECT COALESCE(quantity, 0), container_id, unit FROM item WHERE id = $1 FOR UPDATE`, item.ID,
+    ).Scan(&previousQuantity, &previousContainerID, &previousUnit)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("item not found")
+    }
+    if err != nil {
+        return fmt.Errorf("error locking item: %v", err)
+    }
+
+    // Loans may have gone out since the caller checked; lending locks the
+    // item too, so the sum cannot change again before this commits
+    var lent float64
+    err = tx.QueryRow(
+        `SELECT COALESCE(SUM(quantity), 0) FROM item_loan WHERE item_id = $1 AND returned_at IS NULL`, item.ID,
+    ).Scan(&lent)
+    if err != nil {
+        return fmt.Errorf("error checking loans: %v", err)
+    }
+    if item.Quantity < lent {
+        return fmt.Errorf("%w: %s", ErrQuantityLent, formatQuantity(lent))
+    }
+
+    if item.Unit != previousUnit {
+        // Loans and batches hold amounts in the old unit
+        var batched bool
+        err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM item_batch WHERE item_id = $1)`, item.ID).Scan(&batched)
+        if err != nil {
+            return fmt.Errorf("error checking batches: %v", err)
+        }
+        if lent > 0 || batched {
+            return ErrUnitInUse
+        }
+
+        // Compare like with like so the ledger records the real change
+        err = tx.QueryRow(
+            `SELECT $1 * f.factor / t.factor FROM unit f, unit t WHERE f.code = $2 AND t.code = $3`,
+            previousQuantity, previousUnit, item.Unit,
+        ).Scan(&previousQuantity)
+        if err != nil {
+            return fmt.Errorf("error converting quantity: %v", err)
+        }
+        previousQuantity = roundQuantity(previousQuantity)
+    }
+
+    if item.ContainerID != nil && (previousContainerID == nil || *previousContainerID != *item.ContainerID) {
+        if err := lockDestination(tx, userID, *item.ContainerID); err != nil {
+            return err
+        }
+    }
+
     query := `
         UPDATE item
         SET name = $2, description = $3,
-            quantity = $4, container_id = $5, updated_at = $6
+            quantity = $4, barcode = NULLIF($5, ''), container_id = $6, updated_at = $7,
+            weight_kg = $8, width_cm = $9, depth_cm = $10, height_cm = $11,
+            min_quantity = $12, reorder_quantity = $13, preferred_store = NULLIF($14, ''), unit = $15,
+            purchase_price = $16, currency = NULLIF($17, ''), purchase_date = NULLIF($18, '')::date,
+            vendor = NULLIF($19, ''), estimated_value = $20
         WHERE id = $1`
 
     result, err := tx.Exec(
@@ -314,8 +481,22 @@ func (r *Repository) Update(item *models.Item) error {
         item.Name,
         item.Description,
         item.Quantity,
+        item.Barcode,
         item.ContainerID,
         time.Now().UTC(),
+        item.WeightKg,
+        item.WidthCm,
+        item.DepthCm,
+        item.HeightCm,
+        item.MinQuantity,
+        item.ReorderQuantity,
+        item.PreferredStore,
+        item.Unit,
+        item.PurchasePrice,
+        item.Currency,
+        item.PurchaseDate,
+        item.Vendor,
+        item.EstimatedValue,
     )
     if err != nil {
         return fmt.Errorf("error updating item: %v", err)
@@ -330,6 +511,17 @@ func (r *Repository) Update(item *models.Item) error {
         return fmt.Errorf("item not found")
     }
 
+    if delta := roundQuantity(item.Quantity - previousQuantity); delta != 0 {
+        if _, err := recordMovement(tx, item.ID, userID, delta, ReasonCorrection, "", item.Quantity); err != nil {
+            return err
+        }
+        if delta < 0 {
+            if err := consumeBatches(tx, item.ID, -delta); err != nil {
+                return err
+            }
+        }
+    }
+
     _, err = tx.Exec("DELETE FROM item_tag WHERE item_id = $1", item.ID)
     if err != nil {
         return fmt.Errorf("error removing old tags: %v", err)
@@ -348,37 +540,123 @@ func (r *Repository) Update(item *models.Item) error {
     return tx.Commit()
 }
 
-func (r *Repository) AddItemImage(itemID int, url string, displayOrder int) error {
+// AddItemImage stores the upload, or reuses identical content stored
+// before, and appends it to the item's photos. The stored bytes are charged
+// to the blob, so the image row itself carries none.
+func (r *Repository) AddItemImage(itemID, userID int, store blob.Storer, upload *storage.Upload) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    image, err := blob.Acquire(tx, store, userID, upload)
+    if err != nil {
+        return err
+    }
+
+    variantsJSON, err := json.Marshal(image.Variants)
+    if err != nil {
+        return fmt.Errorf("error encoding image variants: %v", err)
+    }
+
     query := `
-        INSERT INTO item_image (item_id, url, display_order)
-        VALUES ($1, $2, $3)`
+        INSERT INTO item_image (item_id, user_id, blob_key, url, width, height, byte_size, variants, display_order)
+        SELECT $1, $2, $3, $4, $5, $6, $7, $8, COALESCE(MAX(display_order) + 1, 0)
+        FROM item_image
+        WHERE item_id = $1`
     
-    _, err := r.db.Exec(query, itemID, url, displayOrder)
+    _, err = tx.Exec(
+        query, itemID, userID, upload.Key, image.URL, image.Width, image.Height,
+        image.ByteSize, variantsJSON,
+    )
     if err != nil {
         return fmt.Errorf("error adding item image: %v", err)
     }
     
-    return nil
+    return tx.Commit()
 }
 
+
 func (r *Repository) DeleteItemImage(itemID int, url string) error {
-    query := `DELETE FROM item_image WHERE item_id = $1 AND url = $2`
-    
-    result, err := r.db.Exec(query, itemID, url)
+    tx, err := r.db.Begin()
     if err != nil {
-        return fmt.Errorf("error deleting item image: %v", err)
+        return fmt.Errorf("error starting transaction: %v", err)
     }
-    
-    rowsAffected, err := result.RowsAffected()
+    defer tx.Rollback()
+
+    query := `
+        DELETE FROM item_image
+        WHERE item_id = $1 AND url = $2
+        RETURNING url, variants, user_id, storage_bytes, blob_key`
+
+    deleted, err := blob.DeleteImages(tx, query, itemID, url)
     if err != nil {
-        return fmt.Errorf("error checking delete result: %v", err)
+        return fmt.Errorf("error deleting item image: %v", err)
     }
-    
-    if rowsAffected == 0 {
+
+    if deleted == 0 {
         return fmt.Errorf("image not found")
     }
-    
-    return nil
+
+    return tx.Commit()
+}
+
+// AddReceipt stores the upload, or reuses an identical receipt stored
+// before, and records it against its item. The stored bytes are charged to
+// the blob, so the receipt row itself carries none.
+func (r *Repository) AddReceipt(userID int, store blob.Storer, upload *storage.Upload, receipt *models.Receipt) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    stored, err := blob.Acquire(tx, store, userID, upload)
+    if err != nil {
+        return err
+    }
+    receipt.URL = stored.URL
+    receipt.ByteSize = stored.ByteSize
+
+    query := `
+        INSERT INTO item_receipt (item_id, user_id, blob_key, url, filename, content_type, byte_size)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at`
+
+    err = tx.QueryRow(
+        query, receipt.ItemID, userID, upload.Key, receipt.URL, receipt.Filename,
+        receipt.ContentType, receipt.ByteSize,
+    ).Scan(&receipt.ID, &receipt.CreatedAt)
+    if err != nil {
+        return fmt.Errorf("error adding item receipt: %v", err)
+    }
+
+    return tx.Commit()
+}
+
+func (r *Repository) DeleteReceipt(itemID, receiptID int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    query := `
+        DELETE FROM item_receipt
+        WHERE item_id = $1 AND id = $2
+        RETURNING url, '{}'::jsonb, user_id, storage_bytes, blob_key`
+
+    deleted, err := blob.DeleteImages(tx, query, itemID, receiptID)
+    if err != nil {
+        return fmt.Errorf("error deleting item receipt: %v", err)
+    }
+
+    if deleted == 0 {
+        return ErrReceiptNotFound
+    }
+
+    return tx.Commit()
 }
 
 func (r *Repository) Delete(id int) error {
@@ -388,13 +666,20 @@ func (r *Repository) Delete(id int) error {
     }
     defer tx.Rollback()
 
-    // Remove the item's images
-    imageQuery := `DELETE FROM item_image WHERE item_id = $1`
-    _, err = tx.Exec(imageQuery, id)
+    // Remove the item's images and queue their blobs for deletion
+    imageQuery := `DELETE FROM item_image WHERE item_id = $1 RETURNING url, variants, user_id, storage_bytes, blob_key`
+    _, err = blob.DeleteImages(tx, imageQuery, id)
     if err != nil {
         return fmt.Errorf("error removing item images: %v", err)
     }
 
+    // Receipts are released the same way; they have no renditions
+    receiptQuery := `DELETE FROM item_receipt WHERE item_id = $1 RETURNING url, '{}'::jsonb, user_id, storage_bytes, blob_key`
+    _, err = blob.DeleteImages(tx, receiptQuery, id)
+    if err != nil {
+        return fmt.Errorf("error removing item receipts: %v", err)
+    }
+
     // Remove item-tag associations
     itemTagQuery := `DELETE FROM item_tag WHERE item_id = $1`
     _, err = tx.Exec(itemTagQuery, id)
@@ -419,4 +704,581 @@ func (r *Repository) Delete(id int) error {
     }
 
     return tx.Commit()
-}
\ No newline at end of file
+}
+// lockDestination checks that items may be moved into containerID: it must
+// belong to userID and not be retired. The share lock keeps a merge from
+// retiring it before tx ends.
+func lockDestination(tx *sql.Tx, userID, containerID int) error {
+    var ownerID int
+    var retired bool
+    err := tx.QueryRow(
+        `SELECT user_id, retired_at IS NOT NULL FROM container WHERE id = $1 FOR SHARE`, containerID,
+    ).Scan(&ownerID, &retired)
+    if err == sql.ErrNoRows || (err == nil && ownerID != userID) {
+        return ErrDestinationNotFound
+    }
+    if err != nil {
+        return fmt.Errorf("error checking destination container: %v", err)
+    }
+    if retired {
+        return ErrDestinationRetired
+    }
+    return nil
+}
+
+// BulkMove moves itemIDs into containerID in one transaction. The destination
+// must belong to userID; items outside userID's containers, or missing ones,
+// are reported back rather than failing the whole move.
+func (r *Repository) BulkMove(userID, containerID int, itemIDs []int) (*BulkMoveResult, error) {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    if err := lockDestination(tx, userID, containerID); err != nil {
+        return nil, err
+    }
+
+    // Lock the items so a concurrent move cannot slip one past the ownership
+    // check below
+    rows, err := tx.Query(`
+        SELECT i.id, i.container_id, c.user_id
+        FROM item i
+        LEFT JOIN container c ON i.container_id = c.id
+        WHERE i.id = ANY($1)
+        FOR UPDATE OF i`,
+        pq.Array(itemIDs),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("error loading items: %v", err)
+    }
+
+    type current struct {
+        containerID *int
+        ownerID     *int
+    }
+    found := make(map[int]current, len(itemIDs))
+    for rows.Next() {
+        var id int
+        var c current
+        if err := rows.Scan(&id, &c.containerID, &c.ownerID); err != nil {
+            rows.Close()
+            return nil, fmt.Errorf("error scanning item: %v", err)
+        }
+        found[id] = c
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("error loading items: %v", err)
+    }
+
+    result := &BulkMoveResult{
+        ContainerID: containerID,
+        Moved:       make([]int, 0, len(itemIDs)),
+        Unchanged:   make([]int, 0),
+        Failed:      make([]BulkMoveFailure, 0),
+    }
+    for _, id := range itemIDs {
+        c, ok := found[id]
+        switch {
+        case !ok:
+            result.Failed = append(result.Failed, BulkMoveFailure{ItemID: id, Error: "item not found"})
+        // Items outside any container have no owner to check against, so
+        // they cannot be claimed by moving them
+        case c.ownerID == nil || *c.ownerID != userID:
+            result.Failed = append(result.Failed, BulkMoveFailure{ItemID: id, Error: "access denied"})
+        case c.containerID != nil && *c.containerID == containerID:
+            result.Unchanged = append(result.Unchanged, id)
+        default:
+            result.Moved = append(result.Moved, id)
+        }
+    }
+
+    if len(result.Moved) > 0 {
+        _, err = tx.Exec(
+            `UPDATE item SET container_id = $1, updated_at = $3 WHERE id = ANY($2)`,
+            containerID, pq.Array(result.Moved), time.Now().UTC(),
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error moving items: %v", err)
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return result, nil
+}
+
+// AdjustQuantity adds delta to the item's quantity and records the movement.
+// The item row is locked for the duration, so concurrent adjustments apply
+// one after another and the quantity never drops below what is lent out.
+func (r *Repository) AdjustQuantity(userID, itemID int, delta float64, reason, note string) (*Movement, error) {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var quantity float64
+    var whole bool
+    lockQuery := `
+        SELECT COALESCE(i.quantity, 0), u.dimension = 'count'
+        FROM item i
+        JOIN unit u ON i.unit = u.code
+        WHERE i.id = $1
+        FOR UPDATE OF i`
+    err = tx.QueryRow(lockQuery, itemID).Scan(&quantity, &whole)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("item not found")
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error locking item: %v", err)
+    }
+    if whole && delta != math.Trunc(delta) {
+        return nil, ErrFractionalQuantity
+    }
+
+    var lent float64
+    lentQuery := `SELECT COALESCE(SUM(quantity), 0) FROM item_loan WHERE item_id = $1 AND returned_at IS NULL`
+    if err := tx.QueryRow(lentQuery, itemID).Scan(&lent); err != nil {
+        return nil, fmt.Errorf("error summing loans: %v", err)
+    }
+
+    quantityAfter := roundQuantity(quantity + delta)
+    if quantityAfter < lent {
+        return nil, fmt.Errorf("%w: %s on hand", ErrInsufficientStock, formatQuantity(quantity-lent))
+    }
+    if quantityAfter > maxQuantity {
+        return nil, ErrInvalidQuantity
+    }
+
+    _, err = tx.Exec(
+        `UPDATE item SET quantity = $2, updated_at = $3 WHERE id = $1`,
+        itemID, quantityAfter, time.Now().UTC(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("error adjusting quantity: %v", err)
+    }
+
+    movement, err := recordMovement(tx, itemID, userID, delta, reason, note, quantityAfter)
+    if err != nil {
+        return nil, err
+    }
+
+    if delta < 0 {
+        if err := consumeBatches(tx, itemID, -delta); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return movement, nil
+}
+
+func recordMovement(tx *sql.Tx, itemID, userID int, delta float64, reason, note string, quantityAfter float64) (*Movement, error) {
+    movement := &Movement{
+        ItemID:        itemID,
+        UserID:        &userID,
+        Delta:         delta,
+        Reason:        reason,
+        Note:          note,
+        QuantityAfter: quantityAfter,
+        CreatedAt:     time.Now().UTC(),
+    }
+
+    // The unit is copied so history still reads right after the item's changes
+    query := `
+        INSERT INTO stock_movement (item_id, user_id, delta, reason, note, quantity_after, unit, created_at)
+        SELECT $1, $2, $3, $4, $5, $6, i.unit, $7
+        FROM item i
+        WHERE i.id = $1
+        RETURNING id, unit`
+
+    err := tx.QueryRow(
+        query, itemID, userID, delta, reason, note, quantityAfter, movement.CreatedAt,
+    ).Scan(&movement.ID, &movement.Unit)
+    if err != nil {
+        return nil, fmt.Errorf("error recording stock movement: %v", err)
+    }
+
+    return movement, nil
+}
+
+// GetMovements returns the item's stock ledger, newest first.
+func (r *Repository) GetMovements(itemID, limit int) ([]Movement, error) {
+    query := `
+        SELECT id, item_id, user_id, delta, reason, note, quantity_after, COALESCE(unit, ''), created_at
+        FROM stock_movement
+        WHERE item_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2`
+
+    rows, err := r.db.Query(query, itemID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("error querying stock movements: %v", err)
+    }
+    defer rows.Close()
+
+    movements := []Movement{}
+    for rows.Next() {
+        var movement Movement
+        if err := rows.Scan(
+            &movement.ID, &movement.ItemID, &movement.UserID, &movement.Delta,
+            &movement.Reason, &movement.Note, &movement.QuantityAfter, &movement.Unit, &movement.CreatedAt,
+        ); err != nil {
+            return nil, fmt.Errorf("error scanning stock movement: %v", err)
+        }
+        movements = append(movements, movement)
+    }
+
+    return movements, rows.Err()
+}
+
+// GetLowStock lists items in the user's containers whose quantity is below
+// their minimum, optionally limited to one workspace.
+func (r *Repository) GetLowStock(userID int, workspaceID *int) ([]LowStockItem, error) {
+    query := `
+        SELECT i.id, i.name, i.quantity, i.unit, i.min_quantity, i.reorder_quantity,
+               GREATEST(COALESCE(i.reorder_quantity, 0), i.min_quantity - i.quantity),
+               COALESCE(i.preferred_store, ''),
+               c.id, c.name, w.id, COALESCE(w.name, ''),
+               COALESCE(array_agg(t.name ORDER BY t.name) FILTER (WHERE t.id IS NOT NULL), '{}')
+        FROM item i
+        JOIN container c ON i.container_id = c.id
+        LEFT JOIN workspace w ON c.workspace_id = w.id
+        LEFT JOIN item_tag it ON i.id = it.item_id
+        LEFT JOIN tag t ON it.tag_id = t.id
+        WHERE c.user_id = $1
+          AND i.quantity < i.min_quantity
+          AND ($2::INTEGER IS NULL OR c.workspace_id = $2)
+        GROUP BY i.id, c.id, w.id
+        ORDER BY i.name, i.id`
+
+    rows, err := r.db.Query(query, userID, workspaceID)
+    if err != nil {
+        return nil, fmt.Errorf("error querying low stock items: %v", err)
+    }
+    defer rows.Close()
+
+    items := []LowStockItem{}
+    for rows.Next() {
+        var item LowStockItem
+        err := rows.Scan(
+            &item.ItemID, &item.Name, &item.Quantity, &item.Unit, &item.MinQuantity, &item.ReorderQuantity,
+            &item.ToBuy, &item.PreferredStore,
+            &item.ContainerID, &item.ContainerName, &item.WorkspaceID, &item.WorkspaceName,
+            pq.Array(&item.Tags),
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error scanning low stock item: %v", err)
+        }
+        items = append(items, item)
+    }
+
+    return items, rows.Err()
+}
+
+// Restock records a purchase for every line in one transaction. Lines
+// without a quantity are topped up by the amount the shopping list asked
+// for, so restocked items drop off the list.
+func (r *Repository) Restock(userID int, lines []RestockLine) ([]Movement, error) {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    query := `
+        SELECT COALESCE(i.quantity, 0), u.dimension = 'count',
+               CASE WHEN i.quantity < i.min_quantity
+                    THEN GREATEST(COALESCE(i.reorder_quantity, 0), i.min_quantity - i.quantity)
+                    ELSE 0
+               END
+        FROM item i
+        JOIN unit u ON i.unit = u.code
+        JOIN container c ON i.container_id = c.id
+        WHERE i.id = $1 AND c.user_id = $2
+        FOR UPDATE OF i`
+
+    movements := []Movement{}
+    for _, line := range lines {
+        var quantity, toBuy float64
+        var whole bool
+        err := tx.QueryRow(query, line.ItemID, userID).Scan(&quantity, &whole, &toBuy)
+        if err == sql.ErrNoRows {
+            return nil, fmt.Errorf("%w: %d", ErrRestockNotFound, line.ItemID)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("error locking item: %v", err)
+        }
+
+        if line.Quantity != nil {
+            toBuy = *line.Quantity
+        }
+        if toBuy <= 0 {
+            continue
+        }
+        if whole && toBuy != math.Trunc(toBuy) {
+            return nil, fmt.Errorf("%w: %d", ErrFractionalQuantity, line.ItemID)
+        }
+        quantityAfter := roundQuantity(quantity + toBuy)
+        if quantityAfter > maxQuantity {
+            return nil, fmt.Errorf("%w: %d", ErrInvalidQuantity, line.ItemID)
+        }
+
+        _, err = tx.Exec(
+            `UPDATE item SET quantity = $2, updated_at = $3 WHERE id = $1`,
+            line.ItemID, quantityAfter, time.Now().UTC(),
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error restocking item: %v", err)
+        }
+
+        movement, err := recordMovement(tx, line.ItemID, userID, toBuy, ReasonPurchased, "restocked from shopping list", quantityAfter)
+        if err != nil {
+            return nil, err
+        }
+        movements = append(movements, *movement)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return movements, nil
+}
+
+// consumeBatches takes amount out of the item's batches, the first to
+// expire first, deleting any that run out. Stock beyond what the batches
+// hold has no date and is left alone.
+func consumeBatches(tx *sql.Tx, itemID int, amount float64) error {
+    rows, err := tx.Query(`
+        SELECT id, quantity
+        FROM item_batch
+        WHERE item_id = $1
+        ORDER BY expires_on, id
+        FOR UPDATE`, itemID)
+    if err != nil {
+        return fmt.Errorf("error locking batches: %v", err)
+    }
+
+    type batchQuantity struct {
+        id       int
+        quantity float64
+    }
+    var batches []batchQuantity
+    for rows.Next() {
+        var b batchQuantity
+        if err := rows.Scan(&b.id, &b.quantity); err != nil {
+            rows.Close()
+            return fmt.Errorf("error scanning batch: %v", err)
+        }
+        batches = append(batches, b)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("error reading batches: %v", err)
+    }
+
+    for _, b := range batches {
+        if amount <= 0 {
+            break
+        }
+
+        if b.quantity <= amount {
+            if _, err := tx.Exec(`DELETE FROM item_batch WHERE id = $1`, b.id); err != nil {
+                return fmt.Errorf("error removing batch: %v", err)
+            }
+        } else {
+            _, err := tx.Exec(
+                `UPDATE item_batch SET quantity = $2, updated_at = $3 WHERE id = $1`,
+                b.id, roundQuantity(b.quantity-amount), time.Now().UTC(),
+            )
+            if err != nil {
+                return fmt.Errorf("error updating batch: %v", err)
+            }
+        }
+        amount = roundQuantity(amount - b.quantity)
+    }
+
+    return nil
+}
+
+// AddBatch adds newly bought stock with an expiry date to the item and
+// records it as a purchase.
+func (r *Repository) AddBatch(userID, itemID int, batch *models.Batch) (*models.Batch, error) {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var quantity float64
+    var whole bool
+    lockQuery := `
+        SELECT COALESCE(i.quantity, 0), u.dimension = 'count'
+        FROM item i
+        JOIN unit u ON i.unit = u.code
+        WHERE i.id = $1
+        FOR UPDATE OF i`
+    err = tx.QueryRow(lockQuery, itemID).Scan(&quantity, &whole)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("item not found")
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error locking item: %v", err)
+    }
+    if whole && batch.Quantity != math.Trunc(batch.Quantity) {
+        return nil, ErrFractionalQuantity
+    }
+    // Check before inserting the batch so the item can hold it
+    if roundQuantity(quantity+batch.Quantity) > maxQuantity {
+        return nil, ErrInvalidQuantity
+    }
+
+    insertQuery := `
+        INSERT INTO item_batch (item_id, quantity, expires_on, date_kind, lot, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $6)
+        RETURNING id, expires_on < CURRENT_DATE, created_at`
+
+    batch.ItemID = itemID
+    err = tx.QueryRow(
+        insertQuery, itemID, batch.Quantity, batch.ExpiresOn, batch.DateKind, batch.Lot, time.Now().UTC(),
+    ).Scan(&batch.ID, &batch.Expired, &batch.CreatedAt)
+    if err != nil {
+        return nil, fmt.Errorf("error creating batch: %v", err)
+    }
+
+    quantityAfter := roundQuantity(quantity + batch.Quantity)
+    _, err = tx.Exec(
+        `UPDATE item SET quantity = $2, updated_at = $3 WHERE id = $1`,
+        itemID, quantityAfter, time.Now().UTC(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("error adding batch stock: %v", err)
+    }
+
+    note := "batch expiring " + batch.ExpiresOn
+    if _, err := recordMovement(tx, itemID, userID, batch.Quantity, ReasonPurchased, note, quantityAfter); err != nil {
+        return nil, err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return batch, nil
+}
+
+// DiscardBatch throws a batch away, taking its stock off the item and
+// recording it as lost.
+func (r *Repository) DiscardBatch(userID, itemID, batchID int) (*Movement, error) {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var quantity float64
+    err = tx.QueryRow(`SELECT COALESCE(quantity, 0) FROM item WHERE id = $1 FOR UPDATE`, itemID).Scan(&quantity)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("item not found")
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error locking item: %v", err)
+    }
+
+    var batchQuantity float64
+    var expiresOn string
+    err = tx.QueryRow(`
+        DELETE FROM item_batch
+        WHERE id = $1 AND item_id = $2
+        RETURNING quantity, to_char(expires_on, 'YYYY-MM-DD')`,
+        batchID, itemID,
+    ).Scan(&batchQuantity, &expiresOn)
+    if err == sql.ErrNoRows {
+        return nil, ErrBatchNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error removing batch: %v", err)
+    }
+
+    var lent float64
+    lentQuery := `SELECT COALESCE(SUM(quantity), 0) FROM item_loan WHERE item_id = $1 AND returned_at IS NULL`
+    if err := tx.QueryRow(lentQuery, itemID).Scan(&lent); err != nil {
+        return nil, fmt.Errorf("error summing loans: %v", err)
+    }
+
+    // Batches never hold more than the item, but quantities set before
+    // batches were tracked may disagree
+    quantityAfter := math.Max(roundQuantity(quantity-batchQuantity), 0)
+    if quantityAfter < lent {
+        return nil, fmt.Errorf("%w: %s on hand", ErrInsufficientStock, formatQuantity(quantity-lent))
+    }
+    delta := roundQuantity(quantityAfter - quantity)
+
+    _, err = tx.Exec(
+        `UPDATE item SET quantity = $2, updated_at = $3 WHERE id = $1`,
+        itemID, quantityAfter, time.Now().UTC(),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("error discarding batch stock: %v", err)
+    }
+
+    var movement *Movement
+    if delta != 0 {
+        movement, err = recordMovement(tx, itemID, userID, delta, ReasonLost, "discarded batch expiring "+expiresOn, quantityAfter)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return movement, nil
+}
+
+// GetExpiring lists batches in the user's containers that expire within the
+// given number of days, including those already past their date, soonest
+// first.
+func (r *Repository) GetExpiring(userID, days int) ([]ExpiringBatch, error) {
+    query := `
+        SELECT b.id, b.item_id, b.quantity, to_char(b.expires_on, 'YYYY-MM-DD'), b.date_kind, b.lot,
+               b.expires_on < CURRENT_DATE, b.created_at, b.expires_on - CURRENT_DATE,
+               i.name, i.unit, c.id, c.name
+        FROM item_batch b
+        JOIN item i ON b.item_id = i.id
+        JOIN container c ON i.container_id = c.id
+        WHERE c.user_id = $1 AND b.expires_on <= CURRENT_DATE + $2::INTEGER
+        ORDER BY b.expires_on, i.name, b.id`
+
+    rows, err := r.db.Query(query, userID, days)
+    if err != nil {
+        return nil, fmt.Errorf("error querying expiring batches: %v", err)
+    }
+    defer rows.Close()
+
+    batches := []ExpiringBatch{}
+    for rows.Next() {
+        var b ExpiringBatch
+        err := rows.Scan(
+            &b.ID, &b.ItemID, &b.Quantity, &b.ExpiresOn, &b.DateKind, &b.Lot,
+            &b.Expired, &b.CreatedAt, &b.DaysLeft,
+            &b.ItemName, &b.Unit, &b.ContainerID, &b.ContainerName,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("error scanning expiring batch: %v", err)
+        }
+        batches = append(batches, b)
+    }
+
+    return batches, rows.Err()
+}
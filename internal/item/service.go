@@ -1,18 +1,46 @@
 package item
 
 import (
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/chrisabs/storage/internal/blob"
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
+	"github.com/chrisabs/storage/internal/unit"
+	"github.com/chrisabs/storage/pkg/utils"
 )
 
+// UnitService looks up the units of measure item quantities are kept in.
+type UnitService interface {
+    GetUnit(code string) (*models.Unit, error)
+    Convert(value float64, from, to string) (*unit.Conversion, error)
+}
+
+// CurrencyService knows which currencies item values may be recorded in.
+type CurrencyService interface {
+    BaseCurrency() string
+    KnownCurrency(code string) bool
+}
+
 type Service struct {
-    repo *Repository
+    repo       *Repository
+    signer     *storage.URLSigner
+    units      UnitService
+    currencies CurrencyService
 }
 
-func NewService(repo *Repository) *Service {
-    return &Service{repo: repo}
+func NewService(repo *Repository, signer *storage.URLSigner, units UnitService, currencies CurrencyService) *Service {
+    return &Service{repo: repo, signer: signer, units: units, currencies: currencies}
 }
 
 func (s *Service) CreateItem(req *CreateItemRequest) (*models.Item, error) {
@@ -20,15 +48,53 @@ func (s *Service) CreateItem(req *CreateItemRequest) (*models.Item, error) {
         return nil, fmt.Errorf("item name is required")
     }
 
+    barcode := utils.NormalizeBarcode(req.Barcode)
+    if len(barcode) > utils.MaxBarcodeLength {
+        return nil, fmt.Errorf("barcode must be at most %d characters", utils.MaxBarcodeLength)
+    }
+    if err := validateSize(req.WeightKg, req.WidthCm, req.DepthCm, req.HeightCm); err != nil {
+        return nil, err
+    }
+    store := strings.TrimSpace(req.PreferredStore)
+    if err := validateStock(req.MinQuantity, req.ReorderQuantity, store); err != nil {
+        return nil, err
+    }
+    unit, err := s.units.GetUnit(req.Unit)
+    if err != nil {
+        return nil, err
+    }
+    quantity, err := validateQuantity(req.Quantity, unit)
+    if err != nil {
+        return nil, err
+    }
+    value, err := s.validateValue(req.PurchasePrice, req.EstimatedValue, req.Currency, req.PurchaseDate, req.Vendor)
+    if err != nil {
+        return nil, err
+    }
+
     item := &models.Item{
-        Name:        req.Name,
-        Description: req.Description,
-        Quantity:    req.Quantity,
-        ContainerID: req.ContainerID,
-        Images:      []models.ItemImage{},
-        Tags:        make([]models.Tag, 0),
-        CreatedAt:   time.Now().UTC(),
-        UpdatedAt:   time.Now().UTC(),
+        Name:            req.Name,
+        Description:     req.Description,
+        Quantity:        quantity,
+        Unit:            unit.Code,
+        Barcode:         barcode,
+        WeightKg:        req.WeightKg,
+        WidthCm:         req.WidthCm,
+        DepthCm:         req.DepthCm,
+        HeightCm:        req.HeightCm,
+        MinQuantity:     req.MinQuantity,
+        ReorderQuantity: req.ReorderQuantity,
+        PreferredStore:  store,
+        PurchasePrice:   value.purchasePrice,
+        Currency:        value.currency,
+        PurchaseDate:    value.purchaseDate,
+        Vendor:          value.vendor,
+        EstimatedValue:  value.estimatedValue,
+        ContainerID:     req.ContainerID,
+        Images:          []models.ItemImage{},
+        Tags:            make([]models.Tag, 0),
+        CreatedAt:       time.Now().UTC(),
+        UpdatedAt:       time.Now().UTC(),
     }
 
     createdItem, err := s.repo.Create(item, req.TagNames)
@@ -40,22 +106,87 @@ func (s *Service) CreateItem(req *CreateItemRequest) (*models.Item, error) {
 }
 
 func (s *Service) GetItemByID(id int) (*models.Item, error) {
-    return s.repo.GetByID(id)
+    item, err := s.repo.GetByID(id)
+    if err != nil {
+        return nil, err
+    }
+
+    s.signer.SignItem(item)
+    return item, nil
 }
 
 func (s *Service) GetItemsByUserID(userID int) ([]*models.Item, error) {
-    return s.repo.GetByUserID(userID)
+    items, err := s.repo.GetByUserID(userID)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, item := range items {
+        s.signer.SignItem(item)
+    }
+    return items, nil
 }
 
-func (s *Service) UpdateItem(id int, req *UpdateItemRequest) (*models.Item, error) {
+func (s *Service) UpdateItem(userID, id int, req *UpdateItemRequest) (*models.Item, error) {
     item, err := s.repo.GetByID(id)
     if err != nil {
         return nil, fmt.Errorf("item not found: %v", err)
     }
 
+    barcode := utils.NormalizeBarcode(req.Barcode)
+    if len(barcode) > utils.MaxBarcodeLength {
+        return nil, fmt.Errorf("barcode must be at most %d characters", utils.MaxBarcodeLength)
+    }
+    if err := validateSize(req.WeightKg, req.WidthCm, req.DepthCm, req.HeightCm); err != nil {
+        return nil, err
+    }
+    // Clients that predate units leave it out; keep what the item had
+    unitCode := req.Unit
+    if unitCode == "" {
+        unitCode = item.Unit
+    }
+    unit, err := s.units.GetUnit(unitCode)
+    if err != nil {
+        return nil, err
+    }
+    requested := req.Quantity
+    minQuantity, reorderQuantity := req.MinQuantity, req.ReorderQuantity
+    if unit.Code != item.Unit {
+        requested, minQuantity, reorderQuantity, err = s.convertQuantities(item.Unit, unit.Code, requested, minQuantity, reorderQuantity)
+        if err != nil {
+            return nil, err
+        }
+    }
+    store := strings.TrimSpace(req.PreferredStore)
+    if err := validateStock(minQuantity, reorderQuantity, store); err != nil {
+        return nil, err
+    }
+    quantity, err := validateQuantity(requested, unit)
+    if err != nil {
+        return nil, err
+    }
+    value, err := s.validateValue(req.PurchasePrice, req.EstimatedValue, req.Currency, req.PurchaseDate, req.Vendor)
+    if err != nil {
+        return nil, err
+    }
+
     item.Name = req.Name
     item.Description = req.Description
-    item.Quantity = req.Quantity
+    item.Quantity = quantity
+    item.Unit = unit.Code
+    item.Barcode = barcode
+    item.WeightKg = req.WeightKg
+    item.WidthCm = req.WidthCm
+    item.DepthCm = req.DepthCm
+    item.HeightCm = req.HeightCm
+    item.MinQuantity = minQuantity
+    item.ReorderQuantity = reorderQuantity
+    item.PreferredStore = store
+    item.PurchasePrice = value.purchasePrice
+    item.Currency = value.currency
+    item.PurchaseDate = value.purchaseDate
+    item.Vendor = value.vendor
+    item.EstimatedValue = value.estimatedValue
     
     if req.ContainerID != nil {
         item.ContainerID = req.ContainerID
@@ -74,25 +205,487 @@ func (s *Service) UpdateItem(id int, req *UpdateItemRequest) (*models.Item, erro
         item.Tags = []models.Tag{} 
     }
 
-    if err := s.repo.Update(item); err != nil {
-        return nil, fmt.Errorf("failed to update item: %v", err)
+    if err := s.repo.Update(userID, item); err != nil {
+        return nil, fmt.Errorf("failed to update item: %w", err)
     }
 
-    return s.repo.GetByID(id)
+    return s.GetItemByID(id)
 }
 
-func (s *Service) AddItemImage(itemID int, url string) error {
-    item, err := s.repo.GetByID(itemID)
+// convertQuantities re-expresses an update's quantities, given in the item's
+// current unit, in the unit it is switching to. Units of another dimension
+// are refused rather than relabelling the numbers.
+func (s *Service) convertQuantities(from, to string, quantity float64, minQuantity, reorderQuantity *float64) (float64, *float64, *float64, error) {
+    convert := func(value float64) (float64, error) {
+        conversion, err := s.units.Convert(value, from, to)
+        if errors.Is(err, unit.ErrDimensionMismatch) {
+            return 0, ErrUnitDimension
+        }
+        if err != nil {
+            return 0, err
+        }
+        return conversion.Result, nil
+    }
+
+    converted, err := convert(quantity)
     if err != nil {
+        return 0, nil, nil, err
+    }
+
+    thresholds := []*float64{minQuantity, reorderQuantity}
+    for i, threshold := range thresholds {
+        if threshold == nil {
+            continue
+        }
+        value, err := convert(*threshold)
+        if err != nil {
+            return 0, nil, nil, err
+        }
+        thresholds[i] = &value
+    }
+
+    return converted, thresholds[0], thresholds[1], nil
+}
+
+// AdjustQuantity changes an item's quantity by a delta and records why.
+func (s *Service) AdjustQuantity(userID, itemID int, req *AdjustQuantityRequest) (*Movement, error) {
+    if roundQuantity(req.Delta) == 0 {
+        return nil, ErrInvalidDelta
+    }
+
+    switch req.Reason {
+    case ReasonConsumed, ReasonLost:
+        if req.Delta > 0 {
+            return nil, ErrReasonDirection
+        }
+    case ReasonPurchased, ReasonFound:
+        if req.Delta < 0 {
+            return nil, ErrReasonDirection
+        }
+    case ReasonCorrection:
+    default:
+        return nil, ErrInvalidReason
+    }
+
+    note := strings.TrimSpace(req.Note)
+    if len(note) > maxNoteLength {
+        return nil, ErrNoteTooLong
+    }
+
+    return s.repo.AdjustQuantity(userID, itemID, roundQuantity(req.Delta), req.Reason, note)
+}
+
+func (s *Service) GetMovements(itemID, limit int) ([]Movement, error) {
+    if limit <= 0 {
+        limit = defaultMovementLimit
+    }
+    if limit > maxMovementLimit {
+        limit = maxMovementLimit
+    }
+
+    return s.repo.GetMovements(itemID, limit)
+}
+
+func (s *Service) GetLowStock(userID int, workspaceID *int) ([]LowStockItem, error) {
+    return s.repo.GetLowStock(userID, workspaceID)
+}
+
+// GetShoppingList groups the low-stock items by their first tag or their
+// preferred store. Items without one are gathered at the end.
+func (s *Service) GetShoppingList(userID int, workspaceID *int, groupBy string) (*ShoppingList, error) {
+    if groupBy == "" {
+        groupBy = GroupByStore
+    }
+    if groupBy != GroupByTag && groupBy != GroupByStore {
+        return nil, ErrInvalidGroupBy
+    }
+
+    items, err := s.repo.GetLowStock(userID, workspaceID)
+    if err != nil {
+        return nil, err
+    }
+
+    list := &ShoppingList{GroupBy: groupBy, Groups: []ShoppingListGroup{}}
+    index := make(map[string]int)
+    var ungrouped []LowStockItem
+
+    for _, item := range items {
+        name := item.PreferredStore
+        if groupBy == GroupByTag {
+            name = ""
+            if len(item.Tags) > 0 {
+                name = item.Tags[0]
+            }
+        }
+
+        if name == "" {
+            ungrouped = append(ungrouped, item)
+            continue
+        }
+        if _, ok := index[name]; !ok {
+            index[name] = len(list.Groups)
+            list.Groups = append(list.Groups, ShoppingListGroup{Name: name})
+        }
+        group := &list.Groups[index[name]]
+        group.Items = append(group.Items, item)
+    }
+
+    sort.Slice(list.Groups, func(i, j int) bool {
+        return strings.ToLower(list.Groups[i].Name) < strings.ToLower(list.Groups[j].Name)
+    })
+
+    if len(ungrouped) > 0 {
+        name := "Any store"
+        if groupBy == GroupByTag {
+            name = "Untagged"
+        }
+        list.Groups = append(list.Groups, ShoppingListGroup{Name: name, Items: ungrouped})
+    }
+
+    return list, nil
+}
+
+// Restock adds purchased stock to the items and records it in their ledgers.
+func (s *Service) Restock(userID int, req *RestockRequest) ([]Movement, error) {
+    if len(req.Items) == 0 {
+        return nil, ErrNothingToRestock
+    }
+    if len(req.Items) > MaxBulkMove {
+        return nil, ErrTooManyRestock
+    }
+    for _, line := range req.Items {
+        if line.Quantity != nil {
+            if *line.Quantity = roundQuantity(*line.Quantity); *line.Quantity <= 0 || *line.Quantity > maxQuantity {
+                return nil, ErrRestockQuantity
+            }
+        }
+    }
+
+    return s.repo.Restock(userID, req.Items)
+}
+
+// AddBatch adds stock that expires on a given date.
+func (s *Service) AddBatch(userID, itemID int, req *AddBatchRequest) (*models.Batch, error) {
+    quantity := roundQuantity(req.Quantity)
+    if quantity <= 0 || quantity > maxQuantity {
+        return nil, ErrBatchQuantity
+    }
+
+    expiresOn, err := time.Parse("2006-01-02", strings.TrimSpace(req.ExpiresOn))
+    if err != nil {
+        return nil, ErrInvalidExpiry
+    }
+
+    dateKind := req.DateKind
+    if dateKind == "" {
+        dateKind = DateKindExpiry
+    }
+    if dateKind != DateKindExpiry && dateKind != DateKindBestBefore {
+        return nil, ErrInvalidDateKind
+    }
+
+    lot := strings.TrimSpace(req.Lot)
+    if len(lot) > maxLotLength {
+        return nil, ErrLotTooLong
+    }
+
+    batch := &models.Batch{
+        Quantity:  quantity,
+        ExpiresOn: expiresOn.Format("2006-01-02"),
+        DateKind:  dateKind,
+        Lot:       lot,
+    }
+    return s.repo.AddBatch(userID, itemID, batch)
+}
+
+func (s *Service) DiscardBatch(userID, itemID, batchID int) (*Movement, error) {
+    return s.repo.DiscardBatch(userID, itemID, batchID)
+}
+
+// GetExpiring lists batches that have expired or will within a window such
+// as "30d" or "2w". A bare number is taken as days.
+func (s *Service) GetExpiring(userID int, within string) ([]ExpiringBatch, error) {
+    days := defaultExpiringDays
+    if within != "" {
+        var err error
+        days, err = parseDays(within)
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    return s.repo.GetExpiring(userID, days)
+}
+
+func parseDays(within string) (int, error) {
+    within = strings.ToLower(strings.TrimSpace(within))
+    multiplier := 1
+    switch {
+    case strings.HasSuffix(within, "d"):
+        within = strings.TrimSuffix(within, "d")
+    case strings.HasSuffix(within, "w"):
+        within = strings.TrimSuffix(within, "w")
+        multiplier = 7
+    }
+
+    // Divide rather than multiply so a huge count cannot overflow past the cap
+    n, err := strconv.Atoi(within)
+    if err != nil || n < 0 || n > maxExpiringDays/multiplier {
+        return 0, ErrInvalidWithin
+    }
+    return n * multiplier, nil
+}
+
+// WriteShoppingListText writes the list as plain text, one line per item
+// under a heading for each group.
+func WriteShoppingListText(w io.Writer, list *ShoppingList) error {
+    for i, group := range list.Groups {
+        if i > 0 {
+            if _, err := fmt.Fprintln(w); err != nil {
+                return err
+            }
+        }
+        if _, err := fmt.Fprintln(w, group.Name); err != nil {
+            return err
+        }
+        for _, item := range group.Items {
+            amount := describeAmount(item.ToBuy, item.Unit)
+            if item.Unit == "" || item.Unit == "each" {
+                amount += " x"
+            }
+            if _, err := fmt.Fprintf(w, "- %s %s (have %s, want %s)\n", amount, item.Name,
+                describeAmount(item.Quantity, item.Unit), describeAmount(item.MinQuantity, item.Unit)); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// WriteShoppingListCSV writes the list as CSV with a header row.
+func WriteShoppingListCSV(w io.Writer, list *ShoppingList) error {
+    writer := csv.NewWriter(w)
+    header := []string{"group", "item_id", "name", "unit", "to_buy", "quantity", "min_quantity", "preferred_store", "container", "workspace"}
+    if err := writer.Write(header); err != nil {
+        return err
+    }
+
+    for _, group := range list.Groups {
+        for _, item := range group.Items {
+            record := []string{
+                csvText(group.Name),
+                strconv.Itoa(item.ItemID),
+                csvText(item.Name),
+                csvText(item.Unit),
+                formatQuantity(item.ToBuy),
+                formatQuantity(item.Quantity),
+                formatQuantity(item.MinQuantity),
+                csvText(item.PreferredStore),
+                csvText(item.ContainerName),
+                csvText(item.WorkspaceName),
+            }
+            if err := writer.Write(record); err != nil {
+                return err
+            }
+        }
+    }
+
+    writer.Flush()
+    return writer.Error()
+}
+
+// csvText keeps user-entered text from being run as a formula when the file
+// is opened in a spreadsheet, by quoting cells that start like one.
+func csvText(s string) string {
+    if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+        return "'" + s
+    }
+    return s
+}
+
+// validateQuantity rounds q to the precision quantities are stored with and
+// checks it suits the unit.
+func validateQuantity(q float64, unit *models.Unit) (float64, error) {
+    q = roundQuantity(q)
+    if q < 0 || q > maxQuantity {
+        return 0, ErrInvalidQuantity
+    }
+    if unit.Whole() && q != math.Trunc(q) {
+        return 0, ErrFractionalQuantity
+    }
+    return q, nil
+}
+
+// roundQuantity rounds to the three decimal places quantities are stored with.
+func roundQuantity(q float64) float64 {
+    return math.Round(q*1000) / 1000
+}
+
+func formatQuantity(q float64) string {
+    return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// describeAmount reads as "4" for single pieces and "2.5 m" otherwise.
+func describeAmount(q float64, unit string) string {
+    if unit == "" || unit == "each" {
+        return formatQuantity(q)
+    }
+    return formatQuantity(q) + " " + unit
+}
+
+// itemValue is the validated purchase details and value of an item.
+type itemValue struct {
+    purchasePrice  *float64
+    estimatedValue *float64
+    currency       string
+    purchaseDate   string
+    vendor         string
+}
+
+// validateValue checks an item's purchase details. Prices are per unit of
+// quantity and rounded to cents; a price without a currency is taken to be
+// in the base currency.
+func (s *Service) validateValue(purchasePrice, estimatedValue *float64, currency, purchaseDate, vendor string) (*itemValue, error) {
+    value := &itemValue{
+        currency: strings.ToUpper(strings.TrimSpace(currency)),
+        vendor:   strings.TrimSpace(vendor),
+    }
+
+    for _, price := range []**float64{&purchasePrice, &estimatedValue} {
+        if *price == nil {
+            continue
+        }
+        rounded := math.Round(**price*100) / 100
+        if rounded < 0 || rounded >= maxPrice {
+            return nil, ErrInvalidPrice
+        }
+        *price = &rounded
+    }
+    value.purchasePrice = purchasePrice
+    value.estimatedValue = estimatedValue
+
+    if value.currency == "" && (purchasePrice != nil || estimatedValue != nil) {
+        value.currency = s.currencies.BaseCurrency()
+    }
+    if value.currency != "" && !s.currencies.KnownCurrency(value.currency) {
+        return nil, ErrUnknownCurrency
+    }
+
+    if date := strings.TrimSpace(purchaseDate); date != "" {
+        parsed, err := time.Parse("2006-01-02", date)
+        if err != nil {
+            return nil, ErrInvalidPurchaseDate
+        }
+        value.purchaseDate = parsed.Format("2006-01-02")
+    }
+
+    if len(value.vendor) > maxVendorLength {
+        return nil, ErrVendorTooLong
+    }
+
+    return value, nil
+}
+
+func validateStock(minQuantity, reorderQuantity *float64, store string) error {
+    for _, threshold := range []*float64{minQuantity, reorderQuantity} {
+        if threshold != nil && (*threshold < 0 || *threshold > maxQuantity) {
+            return ErrInvalidThreshold
+        }
+    }
+    if len(store) > maxStoreLength {
+        return ErrStoreTooLong
+    }
+    return nil
+}
+
+// validateSize checks the optional weight and dimensions, which count
+// towards how full a container is.
+func validateSize(weightKg, widthCm, depthCm, heightCm *float64) error {
+    if weightKg != nil && (*weightKg <= 0 || *weightKg > maxWeightKg) {
+        return fmt.Errorf("weightKg must be between 0 and %d", maxWeightKg)
+    }
+    for _, v := range []*float64{widthCm, depthCm, heightCm} {
+        if v != nil && (*v <= 0 || *v > maxDimensionCm) {
+            return fmt.Errorf("dimensions must be between 0 and %d cm", maxDimensionCm)
+        }
+    }
+    return nil
+}
+
+// BulkMoveItems moves items into one of userID's containers. Repeated IDs
+// are only moved once.
+func (s *Service) BulkMoveItems(userID int, req *BulkMoveRequest) (*BulkMoveResult, error) {
+    seen := make(map[int]bool, len(req.ItemIDs))
+    itemIDs := make([]int, 0, len(req.ItemIDs))
+    for _, id := range req.ItemIDs {
+        if !seen[id] {
+            seen[id] = true
+            itemIDs = append(itemIDs, id)
+        }
+    }
+
+    if len(itemIDs) == 0 {
+        return nil, ErrNoItems
+    }
+    if len(itemIDs) > MaxBulkMove {
+        return nil, ErrTooManyItems
+    }
+
+    return s.repo.BulkMove(userID, req.ContainerID, itemIDs)
+}
+
+func (s *Service) UploadItemImage(itemID, userID int, store *storage.S3Handler, file *multipart.FileHeader) error {
+    if _, err := s.repo.GetByID(itemID); err != nil {
         return fmt.Errorf("item not found: %v", err)
     }
 
-    displayOrder := len(item.Images)
-    return s.repo.AddItemImage(itemID, url, displayOrder)
+    upload, err := store.ReadImage(file)
+    if err != nil {
+        return err
+    }
+
+    return s.repo.AddItemImage(itemID, userID, store, upload)
 }
 
+// DeleteItemImage accepts either the stored key or a signed URL previously
+// handed to the client.
 func (s *Service) DeleteItemImage(itemID int, url string) error {
-    return s.repo.DeleteItemImage(itemID, url)
+    return s.repo.DeleteItemImage(itemID, blob.KeyFromURL(url))
+}
+
+// UploadReceipt attaches a photo or PDF of the item's proof of purchase.
+func (s *Service) UploadReceipt(itemID, userID int, store *storage.S3Handler, file *multipart.FileHeader) (*models.Receipt, error) {
+    upload, err := store.ReadReceipt(file)
+    if err != nil {
+        return nil, err
+    }
+
+    // The same receipt often covers several items; it is stored once and
+    // charged only to the first upload
+    receipt := &models.Receipt{
+        ItemID:      itemID,
+        Filename:    receiptFilename(file.Filename),
+        ContentType: upload.ContentType,
+    }
+    if err := s.repo.AddReceipt(userID, store, upload, receipt); err != nil {
+        return nil, err
+    }
+
+    receipt.URL = s.signer.SignURL(receipt.URL)
+    return receipt, nil
+}
+
+// receiptFilename keeps the base name of an upload, cut to fit its column.
+func receiptFilename(name string) string {
+    name = filepath.Base(name)
+    if len(name) > maxFilenameLength {
+        name = strings.ToValidUTF8(name[:maxFilenameLength], "")
+    }
+    return name
+}
+
+func (s *Service) DeleteReceipt(itemID, receiptID int) error {
+    return s.repo.DeleteReceipt(itemID, receiptID)
 }
 
 func (s *Service) DeleteItem(id int) error {
@@ -0,0 +1,46 @@
+package label
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// uriPrefixes are the abbreviations defined by the NFC Forum URI record type,
+// longest first so https://www. wins over https://.
+var uriPrefixes = []struct {
+	code   byte
+	prefix string
+}{
+	{0x01, "http://www."},
+	{0x02, "https://www."},
+	{0x03, "http://"},
+	{0x04, "https://"},
+}
+
+// NDEFMessage returns a single well-known URI record for uri, which tag
+// writing apps can put on an NFC sticker as is.
+func NDEFMessage(uri string) []byte {
+	code := byte(0x00)
+	for _, p := range uriPrefixes {
+		if strings.HasPrefix(uri, p.prefix) {
+			code = p.code
+			uri = uri[len(p.prefix):]
+			break
+		}
+	}
+
+	payload := append([]byte{code}, uri...)
+
+	// Message begin, message end and the well-known type name format, with
+	// the short record flag when the payload fits in a single length byte
+	header := byte(0xC1)
+	var message []byte
+	if len(payload) <= 0xFF {
+		message = []byte{header | 0x10, 1, byte(len(payload))}
+	} else {
+		message = []byte{header, 1}
+		message = binary.BigEndian.AppendUint32(message, uint32(len(payload)))
+	}
+	message = append(message, 'U')
+	return append(message, payload...)
+}
@@ -0,0 +1,170 @@
+package label
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+)
+
+const pointsPerMM = 72 / 25.4
+
+// pdfDocument is the small subset of PDF needed for label sheets: pages of
+// filled rectangles and text in the standard Helvetica faces, which every
+// reader ships so nothing has to be embedded.
+type pdfDocument struct {
+	width  float64
+	height float64
+	pages  []*bytes.Buffer
+}
+
+func newPDFDocument(widthMM, heightMM float64) *pdfDocument {
+	return &pdfDocument{width: widthMM * pointsPerMM, height: heightMM * pointsPerMM}
+}
+
+func (d *pdfDocument) addPage() *bytes.Buffer {
+	page := new(bytes.Buffer)
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// bytes lays the document out as catalog, page tree, two fonts and then a
+// page and content stream object per page.
+func (d *pdfDocument) bytes() ([]byte, error) {
+	var out bytes.Buffer
+	var offsets []int
+
+	startObject := func() int {
+		offsets = append(offsets, out.Len())
+		id := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n", id)
+		return id
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	startObject()
+	out.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	startObject()
+	fmt.Fprintf(&out, "<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>\nendobj\n",
+		strings.Join(kids, " "), len(d.pages), d.width, d.height)
+
+	startObject()
+	out.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\nendobj\n")
+	startObject()
+	out.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>\nendobj\n")
+
+	for _, page := range d.pages {
+		pageID := startObject()
+		fmt.Fprintf(&out, "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>\nendobj\n", pageID+1)
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return nil, fmt.Errorf("failed to compress page: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress page: %v", err)
+		}
+
+		startObject()
+		fmt.Fprintf(&out, "<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+		out.Write(compressed.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes(), nil
+}
+
+// helveticaWidths are the advance widths of printable ASCII in Helvetica, in
+// thousandths of the font size. Bold is a little wider, see textWidth.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 222, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	222, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// winAnsi maps text onto the single-byte encoding used by the fonts. Latin-1
+// letters survive, anything else becomes a question mark.
+func winAnsi(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r >= 0x20 && r <= 0x7e, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+func textWidth(text []byte, size float64, bold bool) float64 {
+	total := 0
+	for _, b := range text {
+		if b >= 0x20 && b <= 0x7e {
+			total += helveticaWidths[b-0x20]
+		} else {
+			total += 556
+		}
+	}
+
+	width := float64(total) * size / 1000
+	if bold {
+		width *= 1.08
+	}
+	return width
+}
+
+// fitText shortens text with a trailing ellipsis until it fits maxWidth.
+func fitText(text string, size, maxWidth float64, bold bool) []byte {
+	encoded := winAnsi(text)
+	if textWidth(encoded, size, bold) <= maxWidth {
+		return encoded
+	}
+
+	for len(encoded) > 0 {
+		encoded = encoded[:len(encoded)-1]
+		candidate := append(append([]byte{}, encoded...), "..."...)
+		if textWidth(candidate, size, bold) <= maxWidth {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// writeText draws an already encoded line with its baseline at x, y.
+func writeText(page *bytes.Buffer, x, y, size float64, bold bool, text []byte) {
+	if len(text) == 0 {
+		return
+	}
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	fmt.Fprintf(page, "BT /%s %.2f Tf %.2f %.2f Td (", font, size, x, y)
+	for _, b := range text {
+		if b == '(' || b == ')' || b == '\\' {
+			page.WriteByte('\\')
+		}
+		page.WriteByte(b)
+	}
+	page.WriteString(") Tj ET\n")
+}
@@ -0,0 +1,269 @@
+package label
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	DefaultSize   = 256
+	MinSize       = 64
+	MaxSize       = 2048
+	DefaultMargin = 4
+	MaxMargin     = 16
+	DefaultLevel  = "M"
+)
+
+var (
+	ErrInvalidSize   = fmt.Errorf("size must be between %d and %d pixels", MinSize, MaxSize)
+	ErrInvalidMargin = fmt.Errorf("margin must be between 0 and %d modules", MaxMargin)
+	ErrInvalidLevel  = errors.New("error correction level must be one of L, M, Q or H")
+)
+
+var recoveryLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Options controls how a code is drawn. Size is the width in pixels, Margin
+// the quiet zone in modules and Caption, when set, is printed underneath the
+// code. Level only applies to QR codes.
+type Options struct {
+	Symbology string
+	Size      int
+	Level     string
+	Margin    int
+	Caption   string
+}
+
+func DefaultOptions() Options {
+	return Options{Symbology: SymbologyQR, Size: DefaultSize, Level: DefaultLevel, Margin: DefaultMargin}
+}
+
+func (o Options) Validate() error {
+	if !ValidSymbology(o.Symbology) {
+		return ErrUnknownSymbology
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return ErrInvalidSize
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return ErrInvalidMargin
+	}
+	if _, ok := recoveryLevels[o.Level]; !ok {
+		return ErrInvalidLevel
+	}
+	return nil
+}
+
+// symbolMatrix returns the dark modules of the code for payload surrounded by
+// the requested quiet zone. Linear codes are stretched to a quarter of their
+// width so the bars can be scanned.
+func symbolMatrix(payload string, opts Options) ([][]bool, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	bitmap, err := symbolModules(opts.Symbology, payload, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bitmap) == 1 {
+		bars := bitmap[0]
+		height := len(bars) / 4
+		if height < 10 {
+			height = 10
+		}
+		bitmap = make([][]bool, height)
+		for y := range bitmap {
+			bitmap[y] = bars
+		}
+	}
+
+	rows := len(bitmap) + 2*opts.Margin
+	cols := len(bitmap[0]) + 2*opts.Margin
+	matrix := make([][]bool, rows)
+	for y := range matrix {
+		matrix[y] = make([]bool, cols)
+	}
+	for y, row := range bitmap {
+		copy(matrix[y+opts.Margin][opts.Margin:], row)
+	}
+
+	return matrix, nil
+}
+
+// qrModules encodes payload without any quiet zone.
+func qrModules(payload, level string) ([][]bool, error) {
+	q, err := qrcode.New(payload, recoveryLevels[level])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %v", err)
+	}
+	q.DisableBorder = true
+	return q.Bitmap(), nil
+}
+
+// RenderPNG draws the code with whole-pixel modules, so the image may come out
+// slightly narrower than Size rather than blurred. Long linear codes can be
+// wider when a module would otherwise drop below one pixel.
+func RenderPNG(payload string, opts Options) ([]byte, error) {
+	matrix, err := symbolMatrix(payload, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := len(matrix[0])
+	scale := opts.Size / cols
+	if scale < 1 {
+		scale = 1
+	}
+	width := cols * scale
+	height := len(matrix) * scale
+
+	captionHeight := 0
+	textScale := 1
+	if opts.Caption != "" {
+		textScale = width / 160
+		if textScale < 1 {
+			textScale = 1
+		}
+		captionHeight = (basicfont.Face7x13.Height + 4) * textScale
+	}
+
+	img := image.NewGray(image.Rect(0, 0, width, height+captionHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for y, row := range matrix {
+		for x, dark := range row {
+			if dark {
+				draw.Draw(img, image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale), image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if opts.Caption != "" {
+		drawCaption(img, image.Rect(0, height, width, height+captionHeight), opts.Caption, textScale)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawCaption writes text centred in area using the built-in bitmap font,
+// enlarged by textScale so it stays legible on large codes.
+func drawCaption(dst draw.Image, area image.Rectangle, text string, textScale int) {
+	face := basicfont.Face7x13
+	maxChars := area.Dx() / (face.Advance * textScale)
+	if maxChars < 1 {
+		return
+	}
+	// basicfont has a fixed advance per rune, so cut on runes rather than
+	// bytes to keep multi-byte names whole
+	runes := []rune(text)
+	if len(runes) > maxChars {
+		runes = runes[:maxChars]
+	}
+	text = string(runes)
+
+	textWidth := len(runes) * face.Advance
+	small := image.NewGray(image.Rect(0, 0, textWidth, face.Height))
+	draw.Draw(small, small.Bounds(), image.White, image.Point{}, draw.Src)
+	drawer := &font.Drawer{
+		Dst:  small,
+		Src:  image.NewUniform(color.Black),
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	drawer.DrawString(text)
+
+	scaledWidth := textWidth * textScale
+	left := area.Min.X + (area.Dx()-scaledWidth)/2
+	top := area.Min.Y + (area.Dy()-face.Height*textScale)/2
+	target := image.Rect(left, top, left+scaledWidth, top+face.Height*textScale)
+	draw.NearestNeighbor.Scale(dst, target, small, small.Bounds(), draw.Src, nil)
+}
+
+// RenderSVG draws the code as a single path in module units, scaled to Size
+// by the viewer.
+func RenderSVG(payload string, opts Options) ([]byte, error) {
+	matrix, err := symbolMatrix(payload, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, cols := len(matrix), len(matrix[0])
+	captionHeight := 0
+	if opts.Caption != "" {
+		captionHeight = cols / 8
+		if captionHeight < 3 {
+			captionHeight = 3
+		}
+	}
+
+	// Identical rows, such as every row of a barcode, share one tall run
+	var path strings.Builder
+	for y := 0; y < rows; {
+		row := matrix[y]
+		next := y + 1
+		for next < rows && sameRow(matrix[next], row) {
+			next++
+		}
+
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv%dh-%dz", start, y, x-start, next-y, x-start)
+		}
+		y = next
+	}
+
+	height := opts.Size * (rows + captionHeight) / cols
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, height, cols, rows+captionHeight)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`, cols, rows+captionHeight)
+	fmt.Fprintf(&buf, `<path fill="#000" d="%s"/>`, path.String())
+	if opts.Caption != "" {
+		fmt.Fprintf(&buf, `<text x="%g" y="%g" font-family="monospace" font-size="%g" text-anchor="middle">`,
+			float64(cols)/2, float64(rows)+float64(captionHeight)*0.75, float64(captionHeight)*0.7)
+		xml.EscapeText(&buf, []byte(opts.Caption))
+		buf.WriteString(`</text>`)
+	}
+	buf.WriteString(`</svg>`)
+
+	return buf.Bytes(), nil
+}
+
+func sameRow(a, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package label
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// MaxSheetLabels bounds a single PDF request.
+const MaxSheetLabels = 1000
+
+var (
+	ErrNoLabels      = errors.New("at least one container is required")
+	ErrTooManyLabels = fmt.Errorf("at most %d labels can be printed at once", MaxSheetLabels)
+	ErrInvalidStart  = errors.New("start position must be on the first sheet")
+)
+
+// Label is what gets printed for one container: a code for Payload in the
+// given symbology, QR when empty, with the number, name and location beside
+// it.
+type Label struct {
+	Symbology string
+	Payload   string
+	Name      string
+	Number    string
+	Location  string
+}
+
+// RenderSheet lays labels out across as many pages of tpl as needed. Start
+// skips that many positions on the first page so part-used sheets can be fed
+// back through the printer.
+func RenderSheet(labels []Label, tpl Template, start int) ([]byte, error) {
+	if len(labels) == 0 {
+		return nil, ErrNoLabels
+	}
+	if len(labels) > MaxSheetLabels {
+		return nil, ErrTooManyLabels
+	}
+	if err := tpl.Validate(); err != nil {
+		return nil, err
+	}
+	if start < 0 || start >= tpl.PerPage() {
+		return nil, ErrInvalidStart
+	}
+
+	doc := newPDFDocument(tpl.PageWidth, tpl.PageHeight)
+	var page *bytes.Buffer
+
+	for i, lbl := range labels {
+		position := (start + i) % tpl.PerPage()
+		if page == nil || position == 0 {
+			page = doc.addPage()
+		}
+
+		column := position % tpl.Columns
+		row := position / tpl.Columns
+		x := (tpl.MarginLeft + float64(column)*(tpl.LabelWidth+tpl.GapX)) * pointsPerMM
+		top := doc.height - (tpl.MarginTop+float64(row)*(tpl.LabelHeight+tpl.GapY))*pointsPerMM
+
+		if err := drawLabel(page, x, top, tpl.LabelWidth*pointsPerMM, tpl.LabelHeight*pointsPerMM, lbl); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc.bytes()
+}
+
+// drawLabel fills the label whose top-left corner is at x, top (in points)
+// with a square code on the left and up to three lines of text on the right,
+// or a barcode across the top with the text underneath.
+func drawLabel(page *bytes.Buffer, x, top, width, height float64, lbl Label) error {
+	symbology := lbl.Symbology
+	if symbology == "" {
+		symbology = SymbologyQR
+	}
+
+	modules, err := symbolModules(symbology, lbl.Payload, DefaultLevel)
+	if err != nil {
+		return err
+	}
+
+	padding := minFloat(2*pointsPerMM, height*0.08)
+	if Linear(symbology) {
+		drawLinearLabel(page, x, top, width, height, padding, modules[0], lbl)
+		return nil
+	}
+
+	qrSide := minFloat(height-2*padding, width*0.5)
+
+	// One module of the quiet zone comes from the square, the rest from padding
+	moduleSize := qrSide / float64(maxInt(len(modules), len(modules[0]))+2)
+	qrTop := top - (height-qrSide)/2 - moduleSize
+	drawModules(page, modules, x+padding+moduleSize, qrTop, moduleSize, moduleSize)
+
+	textX := x + padding + qrSide + padding
+	textWidth := x + width - padding - textX
+	if textWidth < 8*pointsPerMM {
+		return nil
+	}
+
+	// The number is what people read off the shelf, so it shrinks to fit
+	// rather than being cut short like the name and location
+	numberSize := clampFloat(height*0.14, 6, 14)
+	if full := textWidthOf(lbl.Number, numberSize); full > textWidth {
+		numberSize = maxFloat(numberSize*textWidth/full, 4)
+	}
+	nameSize := clampFloat(height*0.1, 5, 11)
+	locationSize := nameSize * 0.85
+	bottom := top - height + padding
+
+	baseline := top - padding - numberSize
+	if lbl.Number != "" && baseline >= bottom {
+		writeText(page, textX, baseline, numberSize, true, fitText(lbl.Number, numberSize, textWidth, true))
+		baseline -= nameSize * 1.3
+	}
+	if lbl.Name != "" && baseline >= bottom {
+		writeText(page, textX, baseline, nameSize, false, fitText(lbl.Name, nameSize, textWidth, false))
+		baseline -= locationSize * 1.4
+	}
+	if lbl.Location != "" && baseline >= bottom {
+		writeText(page, textX, baseline, locationSize, false, fitText(lbl.Location, locationSize, textWidth, false))
+	}
+
+	return nil
+}
+
+// drawLinearLabel puts the bars across the top two thirds with the number and
+// name underneath. Barcodes need ten modules of quiet zone either side.
+func drawLinearLabel(page *bytes.Buffer, x, top, width, height, padding float64, bars []bool, lbl Label) {
+	barsWidth := width - 2*padding
+	moduleWidth := barsWidth / float64(len(bars)+20)
+	barHeight := (height - 2*padding) * 0.6
+	drawModules(page, [][]bool{bars}, x+padding+10*moduleWidth, top-padding, moduleWidth, barHeight)
+
+	textSize := clampFloat((height-2*padding-barHeight)*0.45, 4, 12)
+	baseline := top - padding - barHeight - textSize*1.1
+	line := lbl.Number
+	if lbl.Name != "" {
+		line += "  " + lbl.Name
+	}
+	writeText(page, x+padding, baseline, textSize, true, fitText(line, textSize, barsWidth, true))
+}
+
+// drawModules fills each run of dark modules as one rectangle, starting from
+// the top-left corner at x, top.
+func drawModules(page *bytes.Buffer, modules [][]bool, x, top, moduleWidth, moduleHeight float64) {
+	page.WriteString("0 g\n")
+	for row, line := range modules {
+		y := top - float64(row+1)*moduleHeight
+		for col := 0; col < len(line); {
+			if !line[col] {
+				col++
+				continue
+			}
+			start := col
+			for col < len(line) && line[col] {
+				col++
+			}
+			fmt.Fprintf(page, "%.3f %.3f %.3f %.3f re\n",
+				x+float64(start)*moduleWidth, y, float64(col-start)*moduleWidth, moduleHeight)
+		}
+	}
+	page.WriteString("f\n")
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func textWidthOf(text string, size float64) float64 {
+	return textWidth(winAnsi(text), size, true)
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func clampFloat(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
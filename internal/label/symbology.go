@@ -0,0 +1,71 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/datamatrix"
+)
+
+// Symbologies a label can be printed in. QR suits most boxes, Data Matrix
+// stays readable when printed very small and Code 128 works with handheld
+// laser scanners.
+const (
+	SymbologyQR         = "qr"
+	SymbologyDataMatrix = "datamatrix"
+	SymbologyCode128    = "code128"
+)
+
+var Symbologies = []string{SymbologyQR, SymbologyDataMatrix, SymbologyCode128}
+
+var ErrUnknownSymbology = errors.New("symbology must be one of qr, datamatrix or code128")
+
+func ValidSymbology(symbology string) bool {
+	for _, s := range Symbologies {
+		if s == symbology {
+			return true
+		}
+	}
+	return false
+}
+
+// Linear reports whether the symbology is a one-dimensional barcode.
+func Linear(symbology string) bool {
+	return symbology == SymbologyCode128
+}
+
+// symbolModules encodes payload without any quiet zone. Linear codes come back
+// as a single row of bars.
+func symbolModules(symbology, payload, level string) ([][]bool, error) {
+	var code barcode.Barcode
+	var err error
+
+	switch symbology {
+	case SymbologyQR:
+		return qrModules(payload, level)
+	case SymbologyDataMatrix:
+		code, err = datamatrix.Encode(payload)
+	case SymbologyCode128:
+		code, err = code128.Encode(payload)
+	default:
+		return nil, ErrUnknownSymbology
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %v", symbology, err)
+	}
+
+	bounds := code.Bounds()
+	modules := make([][]bool, bounds.Dy())
+	for y := range modules {
+		modules[y] = make([]bool, bounds.Dx())
+		for x := range modules[y] {
+			gray := color.GrayModel.Convert(code.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray)
+			modules[y][x] = gray.Y < 128
+		}
+	}
+
+	return modules, nil
+}
@@ -0,0 +1,89 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Template describes a sheet of label stock. All measurements are in
+// millimetres; MarginTop and MarginLeft are the distance from the page edge
+// to the first label and GapX and GapY the space between labels.
+type Template struct {
+	Name        string  `json:"name"`
+	PageWidth   float64 `json:"pageWidth"`
+	PageHeight  float64 `json:"pageHeight"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	LabelWidth  float64 `json:"labelWidth"`
+	LabelHeight float64 `json:"labelHeight"`
+	MarginTop   float64 `json:"marginTop"`
+	MarginLeft  float64 `json:"marginLeft"`
+	GapX        float64 `json:"gapX"`
+	GapY        float64 `json:"gapY"`
+}
+
+const maxGrid = 20
+
+var (
+	ErrUnknownTemplate = errors.New("unknown label template")
+	ErrInvalidTemplate = errors.New("label template does not fit on its page")
+)
+
+var templates = map[string]Template{
+	"L7160": {
+		Name: "L7160", PageWidth: 210, PageHeight: 297, Columns: 3, Rows: 7,
+		LabelWidth: 63.5, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 7.25, GapX: 2.5,
+	},
+	"L7163": {
+		Name: "L7163", PageWidth: 210, PageHeight: 297, Columns: 2, Rows: 7,
+		LabelWidth: 99.1, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 4.65, GapX: 2.5,
+	},
+	// Thermal printers take one page per label off a continuous roll
+	"thermal-2x1": {
+		Name: "thermal-2x1", PageWidth: 50.8, PageHeight: 25.4, Columns: 1, Rows: 1,
+		LabelWidth: 50.8, LabelHeight: 25.4,
+	},
+}
+
+// LookupTemplate returns a built-in template by name.
+func LookupTemplate(name string) (Template, error) {
+	tpl, ok := templates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	return tpl, nil
+}
+
+// Templates lists the built-in templates by name.
+func Templates() []Template {
+	list := make([]Template, 0, len(templates))
+	for _, tpl := range templates {
+		list = append(list, tpl)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (t Template) PerPage() int {
+	return t.Columns * t.Rows
+}
+
+func (t Template) Validate() error {
+	if t.PageWidth <= 0 || t.PageHeight <= 0 || t.LabelWidth <= 0 || t.LabelHeight <= 0 ||
+		t.MarginTop < 0 || t.MarginLeft < 0 || t.GapX < 0 || t.GapY < 0 {
+		return ErrInvalidTemplate
+	}
+	if t.Columns < 1 || t.Rows < 1 || t.Columns > maxGrid || t.Rows > maxGrid {
+		return fmt.Errorf("%w: columns and rows must be between 1 and %d", ErrInvalidTemplate, maxGrid)
+	}
+
+	// Allow a little slack for stock whose published sizes are rounded
+	const tolerance = 0.5
+	width := t.MarginLeft + float64(t.Columns)*t.LabelWidth + float64(t.Columns-1)*t.GapX
+	height := t.MarginTop + float64(t.Rows)*t.LabelHeight + float64(t.Rows-1)*t.GapY
+	if width > t.PageWidth+tolerance || height > t.PageHeight+tolerance {
+		return ErrInvalidTemplate
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package loan
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chrisabs/storage/internal/container"
+	"github.com/chrisabs/storage/internal/item"
+	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/chrisabs/storage/internal/models"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+    service          *Service
+    containerService *container.Service
+    itemService      *item.Service
+    authMiddleware   *middleware.AuthMiddleware
+}
+
+func NewHandler(service *Service, containerService *container.Service, itemService *item.Service, authMiddleware *middleware.AuthMiddleware) *Handler {
+    return &Handler{
+        service:          service,
+        containerService: containerService,
+        itemService:      itemService,
+        authMiddleware:   authMiddleware,
+    }
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+    router.HandleFunc("/contacts", h.authMiddleware.AuthHandler(h.handleGetContacts)).Methods("GET")
+    router.HandleFunc("/contacts", h.authMiddleware.AuthHandler(h.handleCreateContact)).Methods("POST")
+    router.HandleFunc("/contacts/{id}", h.authMiddleware.AuthHandler(h.handleGetContact)).Methods("GET")
+    router.HandleFunc("/contacts/{id}", h.authMiddleware.AuthHandler(h.handleUpdateContact)).Methods("PUT")
+    router.HandleFunc("/contacts/{id}", h.authMiddleware.AuthHandler(h.handleDeleteContact)).Methods("DELETE")
+    router.HandleFunc("/contacts/{id}/loans", h.authMiddleware.AuthHandler(h.handleGetContactLoans)).Methods("GET")
+
+    router.HandleFunc("/loans", h.authMiddleware.AuthHandler(h.handleGetOutstanding)).Methods("GET")
+
+    router.HandleFunc("/items/{id}/loans", h.authMiddleware.AuthHandler(h.handleGetItemLoans)).Methods("GET")
+    router.HandleFunc("/items/{id}/loans", h.authMiddleware.AuthHandler(h.handleLendItem)).Methods("POST")
+    router.HandleFunc("/items/{id}/loans/{loanId}/return", h.authMiddleware.AuthHandler(h.handleReturnLoan)).Methods("POST")
+}
+
+func (h *Handler) handleGetContacts(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    contacts, err := h.service.GetContacts(userID)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, contacts)
+}
+
+func (h *Handler) handleCreateContact(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    var req ContactRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body")
+        return
+    }
+
+    contact, err := h.service.CreateContact(userID, &req)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusCreated, contact)
+}
+
+func (h *Handler) handleGetContact(w http.ResponseWriter, r *http.Request) {
+    contact, ok := h.authorizeContact(w, r)
+    if !ok {
+        return
+    }
+    writeJSON(w, http.StatusOK, contact)
+}
+
+func (h *Handler) handleUpdateContact(w http.ResponseWriter, r *http.Request) {
+    contact, ok := h.authorizeContact(w, r)
+    if !ok {
+        return
+    }
+
+    var req ContactRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body")
+        return
+    }
+
+    updated, err := h.service.UpdateContact(contact.ID, &req)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) handleDeleteContact(w http.ResponseWriter, r *http.Request) {
+    contact, ok := h.authorizeContact(w, r)
+    if !ok {
+        return
+    }
+
+    if err := h.service.DeleteContact(contact.ID); err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]int{"deleted": contact.ID})
+}
+
+// handleGetContactLoans lists what the contact still has; ?all=true adds
+// returned loans.
+func (h *Handler) handleGetContactLoans(w http.ResponseWriter, r *http.Request) {
+    contact, ok := h.authorizeContact(w, r)
+    if !ok {
+        return
+    }
+
+    includeReturned, ok := boolParam(w, r, "all")
+    if !ok {
+        return
+    }
+
+    loans, err := h.service.GetContactLoans(contact.ID, includeReturned)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, loans)
+}
+
+// handleGetOutstanding lists everything currently lent out; ?overdue=true
+// keeps only loans past their due date.
+func (h *Handler) handleGetOutstanding(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    overdueOnly, ok := boolParam(w, r, "overdue")
+    if !ok {
+        return
+    }
+
+    loans, err := h.service.GetOutstanding(userID, overdueOnly)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, loans)
+}
+
+func (h *Handler) handleGetItemLoans(w http.ResponseWriter, r *http.Request) {
+    _, itemID, ok := h.authorizeItem(w, r)
+    if !ok {
+        return
+    }
+
+    loans, err := h.service.GetItemLoans(itemID)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, loans)
+}
+
+func (h *Handler) handleLendItem(w http.ResponseWriter, r *http.Request) {
+    userID, itemID, ok := h.authorizeItem(w, r)
+    if !ok {
+        return
+    }
+
+    var req LoanRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body")
+        return
+    }
+
+    loan, err := h.service.LendItem(userID, itemID, &req)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusCreated, loan)
+}
+
+func (h *Handler) handleReturnLoan(w http.ResponseWriter, r *http.Request) {
+    _, itemID, ok := h.authorizeItem(w, r)
+    if !ok {
+        return
+    }
+
+    loanID, err := strconv.Atoi(mux.Vars(r)["loanId"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid loan ID")
+        return
+    }
+
+    loan, err := h.service.ReturnLoan(itemID, loanID)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, loan)
+}
+
+// authorizeContact loads the contact in the URL and checks it belongs to the
+// caller, writing the error response when it does not.
+func (h *Handler) authorizeContact(w http.ResponseWriter, r *http.Request) (*models.Contact, bool) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return nil, false
+    }
+
+    contactID, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid contact ID")
+        return nil, false
+    }
+
+    contact, err := h.service.GetContact(contactID)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return nil, false
+    }
+
+    if contact.UserID != userID {
+        writeError(w, http.StatusForbidden, "access denied")
+        return nil, false
+    }
+
+    return contact, true
+}
+
+// authorizeItem checks the caller owns the item in the URL through its
+// container. Unassigned items belong to no one and cannot be lent.
+func (h *Handler) authorizeItem(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return 0, 0, false
+    }
+
+    itemID, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid item ID")
+        return 0, 0, false
+    }
+
+    i, err := h.itemService.GetItemByID(itemID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "item not found")
+        return 0, 0, false
+    }
+    if i.ContainerID == nil {
+        writeError(w, http.StatusForbidden, "access denied")
+        return 0, 0, false
+    }
+
+    c, err := h.containerService.GetContainerByID(*i.ContainerID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "container not found")
+        return 0, 0, false
+    }
+
+    if c.UserID != userID {
+        writeError(w, http.StatusForbidden, "access denied")
+        return 0, 0, false
+    }
+
+    return userID, itemID, true
+}
+
+func boolParam(w http.ResponseWriter, r *http.Request, name string) (bool, bool) {
+    value := r.URL.Query().Get(name)
+    if value == "" {
+        return false, true
+    }
+
+    b, err := strconv.ParseBool(value)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid "+name)
+        return false, false
+    }
+    return b, true
+}
+
+func errorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrContactNotFound), errors.Is(err, ErrItemNotFound),
+        errors.Is(err, ErrLoanNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, ErrContactHasLoans), errors.Is(err, ErrInsufficientQuantity),
+        errors.Is(err, ErrAlreadyReturned):
+        return http.StatusConflict
+    case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidContact),
+        errors.Is(err, ErrNotesTooLong), errors.Is(err, ErrInvalidQuantity),
+        errors.Is(err, ErrFractionalQuantity), errors.Is(err, ErrInvalidDueDate):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
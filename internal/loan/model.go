@@ -0,0 +1,43 @@
+package loan
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+    maxNameLength  = 100
+    maxEmailLength = 255
+    maxPhoneLength = 50
+    maxNotesLength = 1000
+)
+
+var (
+    ErrContactNotFound      = errors.New("contact not found")
+    ErrInvalidName          = errors.New("name must be between 1 and 100 characters")
+    ErrInvalidContact       = errors.New("email must be at most 255 and phone at most 50 characters")
+    ErrNotesTooLong         = errors.New("notes must be at most 1000 characters")
+    ErrContactHasLoans      = errors.New("contact still has items on loan")
+    ErrItemNotFound         = errors.New("item not found")
+    ErrInvalidQuantity      = errors.New("quantity must be positive")
+    ErrFractionalQuantity   = errors.New("items counted in pieces can only be lent in whole numbers")
+    ErrInsufficientQuantity = errors.New("not enough of this item available to lend")
+    ErrInvalidDueDate       = errors.New("dueAt must be in the future")
+    ErrLoanNotFound         = errors.New("loan not found")
+    ErrAlreadyReturned      = errors.New("loan has already been returned")
+)
+
+type ContactRequest struct {
+    Name  string `json:"name"`
+    Email string `json:"email"`
+    Phone string `json:"phone"`
+    Notes string `json:"notes"`
+}
+
+// LoanRequest lends Quantity of the item in the URL, one by default.
+type LoanRequest struct {
+    ContactID int        `json:"contactId"`
+    Quantity  float64    `json:"quantity"`
+    DueAt     *time.Time `json:"dueAt,omitempty"`
+    Notes     string     `json:"notes"`
+}
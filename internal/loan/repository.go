@@ -0,0 +1,328 @@
+package loan
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+const contactColumns = `
+    ct.id, ct.user_id, ct.name, ct.email, ct.phone, ct.notes,
+    (SELECT COUNT(*) FROM item_loan l WHERE l.contact_id = ct.id AND l.returned_at IS NULL),
+    ct.created_at, ct.updated_at`
+
+type scanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanContact(row scanner) (*models.Contact, error) {
+    contact := new(models.Contact)
+    err := row.Scan(
+        &contact.ID, &contact.UserID, &contact.Name, &contact.Email, &contact.Phone, &contact.Notes,
+        &contact.OutstandingLoans,
+        &contact.CreatedAt, &contact.UpdatedAt,
+    )
+    if err != nil {
+        return nil, err
+    }
+    return contact, nil
+}
+
+func (r *Repository) CreateContact(contact *models.Contact) error {
+    query := `
+        INSERT INTO contact (user_id, name, email, phone, notes, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`
+
+    err := r.db.QueryRow(
+        query, contact.UserID, contact.Name, contact.Email, contact.Phone, contact.Notes,
+        contact.CreatedAt, contact.UpdatedAt,
+    ).Scan(&contact.ID)
+    if err != nil {
+        return fmt.Errorf("error creating contact: %v", err)
+    }
+    return nil
+}
+
+func (r *Repository) GetContact(id int) (*models.Contact, error) {
+    query := `SELECT ` + contactColumns + ` FROM contact ct WHERE ct.id = $1`
+
+    contact, err := scanContact(r.db.QueryRow(query, id))
+    if err == sql.ErrNoRows {
+        return nil, ErrContactNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error getting contact: %v", err)
+    }
+    return contact, nil
+}
+
+func (r *Repository) GetContacts(userID int) ([]*models.Contact, error) {
+    query := `SELECT ` + contactColumns + ` FROM contact ct WHERE ct.user_id = $1 ORDER BY ct.name, ct.id`
+
+    rows, err := r.db.Query(query, userID)
+    if err != nil {
+        return nil, fmt.Errorf("error querying contacts: %v", err)
+    }
+    defer rows.Close()
+
+    contacts := []*models.Contact{}
+    for rows.Next() {
+        contact, err := scanContact(rows)
+        if err != nil {
+            return nil, fmt.Errorf("error scanning contact: %v", err)
+        }
+        contacts = append(contacts, contact)
+    }
+
+    return contacts, rows.Err()
+}
+
+func (r *Repository) UpdateContact(contact *models.Contact) error {
+    query := `
+        UPDATE contact
+        SET name = $2, email = $3, phone = $4, notes = $5, updated_at = $6
+        WHERE id = $1`
+
+    _, err := r.db.Exec(
+        query, contact.ID, contact.Name, contact.Email, contact.Phone, contact.Notes, contact.UpdatedAt,
+    )
+    if err != nil {
+        return fmt.Errorf("error updating contact: %v", err)
+    }
+    return nil
+}
+
+// DeleteContact removes a contact and their returned loans. Contacts still
+// holding something cannot be deleted.
+// DeleteContact removes a contact with nothing still on loan. The contact is
+// locked first so a missing one is reported as such rather than as having
+// loans.
+func (r *Repository) DeleteContact(id int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var exists bool
+    err = tx.QueryRow(`SELECT TRUE FROM contact WHERE id = $1 FOR UPDATE`, id).Scan(&exists)
+    if err == sql.ErrNoRows {
+        return ErrContactNotFound
+    }
+    if err != nil {
+        return fmt.Errorf("error locking contact: %v", err)
+    }
+
+    var hasLoans bool
+    err = tx.QueryRow(
+        `SELECT EXISTS (SELECT 1 FROM item_loan WHERE contact_id = $1 AND returned_at IS NULL)`, id,
+    ).Scan(&hasLoans)
+    if err != nil {
+        return fmt.Errorf("error checking contact loans: %v", err)
+    }
+    if hasLoans {
+        return ErrContactHasLoans
+    }
+
+    if _, err := tx.Exec(`DELETE FROM contact WHERE id = $1`, id); err != nil {
+        return fmt.Errorf("error deleting contact: %v", err)
+    }
+
+    return tx.Commit()
+}
+
+const loanColumns = `
+    l.id, l.item_id, i.name, l.contact_id, ct.name, l.user_id, l.quantity, l.notes,
+    l.lent_at, l.due_at, l.returned_at,
+    l.returned_at IS NULL AND COALESCE(l.due_at < NOW(), FALSE)`
+
+const loanJoins = `
+    FROM item_loan l
+    JOIN item i ON l.item_id = i.id
+    JOIN contact ct ON l.contact_id = ct.id`
+
+func scanLoan(row scanner) (*models.Loan, error) {
+    loan := new(models.Loan)
+    err := row.Scan(
+        &loan.ID, &loan.ItemID, &loan.ItemName, &loan.ContactID, &loan.ContactName,
+        &loan.UserID, &loan.Quantity, &loan.Notes,
+        &loan.LentAt, &loan.DueAt, &loan.ReturnedAt,
+        &loan.Overdue,
+    )
+    if err != nil {
+        return nil, err
+    }
+    return loan, nil
+}
+
+// CreateLoan lends part of an item to one of the user's contacts. The item
+// row is locked so concurrent loans cannot lend more than there is.
+func (r *Repository) CreateLoan(userID, itemID int, req *LoanRequest) (*models.Loan, error) {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var quantity float64
+    var whole bool
+    lockQuery := `
+        SELECT COALESCE(i.quantity, 0), u.dimension = 'count'
+        FROM item i
+        JOIN unit u ON i.unit = u.code
+        WHERE i.id = $1
+        FOR UPDATE OF i`
+    err = tx.QueryRow(lockQuery, itemID).Scan(&quantity, &whole)
+    if err == sql.ErrNoRows {
+        return nil, ErrItemNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error locking item: %v", err)
+    }
+    if whole && req.Quantity != math.Trunc(req.Quantity) {
+        return nil, ErrFractionalQuantity
+    }
+
+    var contactExists bool
+    contactQuery := `SELECT EXISTS (SELECT 1 FROM contact WHERE id = $1 AND user_id = $2)`
+    if err := tx.QueryRow(contactQuery, req.ContactID, userID).Scan(&contactExists); err != nil {
+        return nil, fmt.Errorf("error checking contact: %v", err)
+    }
+    if !contactExists {
+        return nil, ErrContactNotFound
+    }
+
+    var lent float64
+    lentQuery := `SELECT COALESCE(SUM(quantity), 0) FROM item_loan WHERE item_id = $1 AND returned_at IS NULL`
+    if err := tx.QueryRow(lentQuery, itemID).Scan(&lent); err != nil {
+        return nil, fmt.Errorf("error summing loans: %v", err)
+    }
+    if available := math.Round((quantity-lent)*1000) / 1000; req.Quantity > available {
+        return nil, fmt.Errorf("%w: %s of %s available", ErrInsufficientQuantity,
+            strconv.FormatFloat(available, 'f', -1, 64), strconv.FormatFloat(quantity, 'f', -1, 64))
+    }
+
+    var id int
+    insertQuery := `
+        INSERT INTO item_loan (item_id, contact_id, user_id, quantity, notes, lent_at, due_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`
+
+    err = tx.QueryRow(
+        insertQuery, itemID, req.ContactID, userID, req.Quantity, req.Notes, time.Now().UTC(), req.DueAt,
+    ).Scan(&id)
+    if err != nil {
+        return nil, fmt.Errorf("error creating loan: %v", err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %v", err)
+    }
+
+    return r.GetLoan(id)
+}
+
+func (r *Repository) GetLoan(id int) (*models.Loan, error) {
+    query := `SELECT ` + loanColumns + loanJoins + ` WHERE l.id = $1`
+
+    loan, err := scanLoan(r.db.QueryRow(query, id))
+    if err == sql.ErrNoRows {
+        return nil, ErrLoanNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error getting loan: %v", err)
+    }
+    return loan, nil
+}
+
+// ReturnLoan marks the whole loan as back.
+func (r *Repository) ReturnLoan(itemID, loanID int) (*models.Loan, error) {
+    query := `
+        UPDATE item_loan
+        SET returned_at = $3
+        WHERE id = $1 AND item_id = $2 AND returned_at IS NULL`
+
+    result, err := r.db.Exec(query, loanID, itemID, time.Now().UTC())
+    if err != nil {
+        return nil, fmt.Errorf("error returning loan: %v", err)
+    }
+
+    returned, err := result.RowsAffected()
+    if err != nil {
+        return nil, fmt.Errorf("error checking return result: %v", err)
+    }
+
+    loan, err := r.GetLoan(loanID)
+    if err != nil {
+        return nil, err
+    }
+    if loan.ItemID != itemID {
+        return nil, ErrLoanNotFound
+    }
+    if returned == 0 {
+        return nil, ErrAlreadyReturned
+    }
+    return loan, nil
+}
+
+// GetItemLoans lists every loan of the item, newest first.
+func (r *Repository) GetItemLoans(itemID int) ([]*models.Loan, error) {
+    query := `SELECT ` + loanColumns + loanJoins + `
+        WHERE l.item_id = $1
+        ORDER BY l.lent_at DESC, l.id DESC`
+
+    return r.listLoans(query, itemID)
+}
+
+// GetContactLoans lists what a contact has borrowed, only what they still
+// hold unless includeReturned is set.
+func (r *Repository) GetContactLoans(contactID int, includeReturned bool) ([]*models.Loan, error) {
+    query := `SELECT ` + loanColumns + loanJoins + `
+        WHERE l.contact_id = $1 AND ($2 OR l.returned_at IS NULL)
+        ORDER BY l.returned_at IS NULL DESC, l.due_at ASC NULLS LAST, l.lent_at DESC`
+
+    return r.listLoans(query, contactID, includeReturned)
+}
+
+// GetOutstanding lists the user's loans that are still out, those due back
+// soonest first.
+func (r *Repository) GetOutstanding(userID int, overdueOnly bool) ([]*models.Loan, error) {
+    query := `SELECT ` + loanColumns + loanJoins + `
+        WHERE l.user_id = $1 AND l.returned_at IS NULL
+          AND (NOT $2 OR l.due_at < NOW())
+        ORDER BY l.due_at ASC NULLS LAST, l.lent_at ASC`
+
+    return r.listLoans(query, userID, overdueOnly)
+}
+
+func (r *Repository) listLoans(query string, args ...interface{}) ([]*models.Loan, error) {
+    rows, err := r.db.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("error querying loans: %v", err)
+    }
+    defer rows.Close()
+
+    loans := []*models.Loan{}
+    for rows.Next() {
+        loan, err := scanLoan(rows)
+        if err != nil {
+            return nil, fmt.Errorf("error scanning loan: %v", err)
+        }
+        loans = append(loans, loan)
+    }
+
+    return loans, rows.Err()
+}
@@ -0,0 +1,127 @@
+package loan
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Service struct {
+    repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+    return &Service{repo: repo}
+}
+
+func validateContact(req *ContactRequest) error {
+    req.Name = strings.TrimSpace(req.Name)
+    req.Email = strings.TrimSpace(req.Email)
+    req.Phone = strings.TrimSpace(req.Phone)
+    req.Notes = strings.TrimSpace(req.Notes)
+
+    if req.Name == "" || len(req.Name) > maxNameLength {
+        return ErrInvalidName
+    }
+    if len(req.Email) > maxEmailLength || len(req.Phone) > maxPhoneLength {
+        return ErrInvalidContact
+    }
+    if len(req.Notes) > maxNotesLength {
+        return ErrNotesTooLong
+    }
+    return nil
+}
+
+func (s *Service) CreateContact(userID int, req *ContactRequest) (*models.Contact, error) {
+    if err := validateContact(req); err != nil {
+        return nil, err
+    }
+
+    contact := &models.Contact{
+        UserID:    userID,
+        Name:      req.Name,
+        Email:     req.Email,
+        Phone:     req.Phone,
+        Notes:     req.Notes,
+        CreatedAt: time.Now().UTC(),
+        UpdatedAt: time.Now().UTC(),
+    }
+
+    if err := s.repo.CreateContact(contact); err != nil {
+        return nil, err
+    }
+    return contact, nil
+}
+
+func (s *Service) GetContact(id int) (*models.Contact, error) {
+    return s.repo.GetContact(id)
+}
+
+func (s *Service) GetContacts(userID int) ([]*models.Contact, error) {
+    return s.repo.GetContacts(userID)
+}
+
+func (s *Service) UpdateContact(id int, req *ContactRequest) (*models.Contact, error) {
+    if err := validateContact(req); err != nil {
+        return nil, err
+    }
+
+    contact, err := s.repo.GetContact(id)
+    if err != nil {
+        return nil, err
+    }
+
+    contact.Name = req.Name
+    contact.Email = req.Email
+    contact.Phone = req.Phone
+    contact.Notes = req.Notes
+    contact.UpdatedAt = time.Now().UTC()
+
+    if err := s.repo.UpdateContact(contact); err != nil {
+        return nil, err
+    }
+    return contact, nil
+}
+
+func (s *Service) DeleteContact(id int) error {
+    return s.repo.DeleteContact(id)
+}
+
+// LendItem records part of an item going out to a contact.
+func (s *Service) LendItem(userID, itemID int, req *LoanRequest) (*models.Loan, error) {
+    if req.Quantity == 0 {
+        req.Quantity = 1
+    }
+    // Quantities are stored to three decimal places
+    req.Quantity = math.Round(req.Quantity*1000) / 1000
+    if req.Quantity <= 0 {
+        return nil, ErrInvalidQuantity
+    }
+    req.Notes = strings.TrimSpace(req.Notes)
+    if len(req.Notes) > maxNotesLength {
+        return nil, ErrNotesTooLong
+    }
+    if req.DueAt != nil && !req.DueAt.After(time.Now()) {
+        return nil, ErrInvalidDueDate
+    }
+
+    return s.repo.CreateLoan(userID, itemID, req)
+}
+
+func (s *Service) ReturnLoan(itemID, loanID int) (*models.Loan, error) {
+    return s.repo.ReturnLoan(itemID, loanID)
+}
+
+func (s *Service) GetItemLoans(itemID int) ([]*models.Loan, error) {
+    return s.repo.GetItemLoans(itemID)
+}
+
+func (s *Service) GetContactLoans(contactID int, includeReturned bool) ([]*models.Loan, error) {
+    return s.repo.GetContactLoans(contactID, includeReturned)
+}
+
+func (s *Service) GetOutstanding(userID int, overdueOnly bool) ([]*models.Loan, error) {
+    return s.repo.GetOutstanding(userID, overdueOnly)
+}
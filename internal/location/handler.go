@@ -0,0 +1,198 @@
+package location
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+    service        *Service
+    authMiddleware *middleware.AuthMiddleware
+}
+
+func NewHandler(service *Service, authMiddleware *middleware.AuthMiddleware) *Handler {
+    return &Handler{
+        service:        service,
+        authMiddleware: authMiddleware,
+    }
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+    router.HandleFunc("/locations", h.authMiddleware.AuthHandler(h.handleCreateLocation)).Methods("POST")
+    router.HandleFunc("/locations/{id}", h.authMiddleware.AuthHandler(h.handleGetLocation)).Methods("GET")
+    router.HandleFunc("/locations/{id}", h.authMiddleware.AuthHandler(h.handleUpdateLocation)).Methods("PUT")
+    router.HandleFunc("/locations/{id}", h.authMiddleware.AuthHandler(h.handleDeleteLocation)).Methods("DELETE")
+
+    router.HandleFunc("/workspaces/{id}/locations", h.authMiddleware.AuthHandler(h.handleGetWorkspaceTree)).Methods("GET")
+}
+
+func (h *Handler) handleCreateLocation(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    var req CreateLocationRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body")
+        return
+    }
+
+    location, err := h.service.CreateLocation(userID, &req)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusCreated, location)
+}
+
+func (h *Handler) handleGetLocation(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    id, err := getIDFromRequest(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    location, err := h.service.GetLocationByID(id)
+    if err != nil {
+        writeError(w, http.StatusNotFound, err.Error())
+        return
+    }
+
+    if location.UserID != userID {
+        writeError(w, http.StatusForbidden, "access denied")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, location)
+}
+
+func (h *Handler) handleGetWorkspaceTree(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    workspaceID, err := getIDFromRequest(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    locations, err := h.service.GetWorkspaceTree(userID, workspaceID)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, locations)
+}
+
+func (h *Handler) handleUpdateLocation(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    id, err := getIDFromRequest(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    location, err := h.service.GetLocationByID(id)
+    if err != nil {
+        writeError(w, http.StatusNotFound, err.Error())
+        return
+    }
+
+    if location.UserID != userID {
+        writeError(w, http.StatusForbidden, "access denied")
+        return
+    }
+
+    var req UpdateLocationRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body")
+        return
+    }
+
+    updatedLocation, err := h.service.UpdateLocation(id, &req)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, updatedLocation)
+}
+
+func (h *Handler) handleDeleteLocation(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    id, err := getIDFromRequest(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    location, err := h.service.GetLocationByID(id)
+    if err != nil {
+        writeError(w, http.StatusNotFound, err.Error())
+        return
+    }
+
+    if location.UserID != userID {
+        writeError(w, http.StatusForbidden, "access denied")
+        return
+    }
+
+    if err := h.service.DeleteLocation(id); err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]int{"deleted": id})
+}
+
+func errorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrWorkspaceNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, ErrLocationCycle):
+        return http.StatusConflict
+    case errors.Is(err, ErrInvalidKind), errors.Is(err, ErrInvalidNesting), errors.Is(err, ErrParentNotFound):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func getIDFromRequest(r *http.Request) (int, error) {
+    vars := mux.Vars(r)
+    return strconv.Atoi(vars["id"])
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
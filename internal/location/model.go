@@ -0,0 +1,39 @@
+package location
+
+import "errors"
+
+// Kinds lists the levels of the location tree from the outermost inwards. A
+// location may only contain locations of a later kind.
+var Kinds = []string{"site", "room", "unit", "shelf", "bin"}
+
+var (
+    ErrInvalidKind       = errors.New("kind must be one of site, room, unit, shelf or bin")
+    ErrInvalidNesting    = errors.New("a location can only contain locations of a narrower kind")
+    ErrParentNotFound    = errors.New("parent location not found in this workspace")
+    ErrLocationCycle     = errors.New("a location cannot be placed inside itself or one of its own children")
+    ErrWorkspaceNotFound = errors.New("workspace not found")
+)
+
+type CreateLocationRequest struct {
+    WorkspaceID int    `json:"workspaceId"`
+    ParentID    *int   `json:"parentId,omitempty"`
+    Name        string `json:"name"`
+    Kind        string `json:"kind"`
+    Description string `json:"description"`
+}
+
+type UpdateLocationRequest struct {
+    ParentID    *int   `json:"parentId,omitempty"`
+    Name        string `json:"name"`
+    Kind        string `json:"kind"`
+    Description string `json:"description"`
+}
+
+func kindRank(kind string) int {
+    for i, k := range Kinds {
+        if k == kind {
+            return i
+        }
+    }
+    return -1
+}
@@ -0,0 +1,260 @@
+package location
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+const locationColumns = `
+    l.id, l.workspace_id, w.user_id, l.parent_id, l.name, l.kind,
+    COALESCE(l.description, ''), COALESCE(l.code, ''),
+    (SELECT COUNT(*) FROM container c WHERE c.location_id = l.id AND c.retired_at IS NULL),
+    l.created_at, l.updated_at`
+
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanLocation(row rowScanner, extra ...interface{}) (*models.Location, error) {
+    location := new(models.Location)
+    dest := []interface{}{
+        &location.ID, &location.WorkspaceID, &location.UserID, &location.ParentID,
+        &location.Name, &location.Kind, &location.Description, &location.Code, &location.ContainerCount,
+        &location.CreatedAt, &location.UpdatedAt,
+    }
+
+    if err := row.Scan(append(dest, extra...)...); err != nil {
+        return nil, err
+    }
+    return location, nil
+}
+
+func (r *Repository) Create(location *models.Location) error {
+    query := `
+        INSERT INTO location (workspace_id, parent_id, name, kind, description, code, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id`
+
+    err := r.db.QueryRow(
+        query,
+        location.WorkspaceID,
+        location.ParentID,
+        location.Name,
+        location.Kind,
+        location.Description,
+        location.Code,
+        location.CreatedAt,
+        location.UpdatedAt,
+    ).Scan(&location.ID)
+
+    if err != nil {
+        return fmt.Errorf("error creating location: %v", err)
+    }
+
+    return nil
+}
+
+func (r *Repository) GetByID(id int) (*models.Location, error) {
+    query := `
+        SELECT ` + locationColumns + `, location_path(l.parent_id)
+        FROM location l
+        JOIN workspace w ON l.workspace_id = w.id
+        WHERE l.id = $1`
+
+    var pathJSON []byte
+    location, err := scanLocation(r.db.QueryRow(query, id), &pathJSON)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("location not found")
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error getting location: %v", err)
+    }
+
+    if err := json.Unmarshal(pathJSON, &location.Path); err != nil {
+        return nil, fmt.Errorf("error parsing path: %v", err)
+    }
+
+    descendantsQuery := `
+        SELECT ` + locationColumns + `
+        FROM location_subtree($1) AS subtree(id)
+        JOIN location l ON l.id = subtree.id
+        JOIN workspace w ON l.workspace_id = w.id
+        WHERE l.id <> $1
+        ORDER BY l.name`
+
+    byParent, err := r.queryTree(descendantsQuery, id)
+    if err != nil {
+        return nil, err
+    }
+
+    location.Children = attachChildren(id, byParent)
+    return location, nil
+}
+
+// GetTreeByWorkspace returns the workspace's top-level locations with every
+// nested location attached below them.
+func (r *Repository) GetTreeByWorkspace(workspaceID int) ([]models.Location, error) {
+    query := `
+        SELECT ` + locationColumns + `
+        FROM location l
+        JOIN workspace w ON l.workspace_id = w.id
+        WHERE l.workspace_id = $1
+        ORDER BY l.name`
+
+    byParent, err := r.queryTree(query, workspaceID)
+    if err != nil {
+        return nil, err
+    }
+
+    roots := attachChildren(0, byParent)
+    if roots == nil {
+        roots = make([]models.Location, 0)
+    }
+    return roots, nil
+}
+
+// queryTree groups the returned locations by parent, using 0 for top-level
+// locations.
+func (r *Repository) queryTree(query string, args ...interface{}) (map[int][]models.Location, error) {
+    rows, err := r.db.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("error querying locations: %v", err)
+    }
+    defer rows.Close()
+
+    byParent := make(map[int][]models.Location)
+    for rows.Next() {
+        location, err := scanLocation(rows)
+        if err != nil {
+            return nil, fmt.Errorf("error scanning location: %v", err)
+        }
+
+        parentID := 0
+        if location.ParentID != nil {
+            parentID = *location.ParentID
+        }
+        byParent[parentID] = append(byParent[parentID], *location)
+    }
+
+    return byParent, nil
+}
+
+func attachChildren(parentID int, byParent map[int][]models.Location) []models.Location {
+    children := byParent[parentID]
+    for i := range children {
+        children[i].Children = attachChildren(children[i].ID, byParent)
+    }
+    return children
+}
+
+func (r *Repository) GetWorkspaceOwner(workspaceID int) (int, error) {
+    var userID int
+    err := r.db.QueryRow(`SELECT user_id FROM workspace WHERE id = $1`, workspaceID).Scan(&userID)
+    if err == sql.ErrNoRows {
+        return 0, ErrWorkspaceNotFound
+    }
+    if err != nil {
+        return 0, fmt.Errorf("error getting workspace: %v", err)
+    }
+
+    return userID, nil
+}
+
+func (r *Repository) Update(location *models.Location) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    if location.ParentID != nil {
+        var createsCycle bool
+        err := tx.QueryRow(
+            `SELECT EXISTS (SELECT 1 FROM location_subtree($1) AS subtree(id) WHERE id = $2)`,
+            location.ID, *location.ParentID,
+        ).Scan(&createsCycle)
+        if err != nil {
+            return fmt.Errorf("error checking location hierarchy: %v", err)
+        }
+        if createsCycle {
+            return ErrLocationCycle
+        }
+    }
+
+    query := `
+        UPDATE location
+        SET parent_id = $2, name = $3, kind = $4, description = $5, updated_at = $6
+        WHERE id = $1`
+
+    result, err := tx.Exec(
+        query,
+        location.ID,
+        location.ParentID,
+        location.Name,
+        location.Kind,
+        location.Description,
+        time.Now().UTC(),
+    )
+    if err != nil {
+        return fmt.Errorf("error updating location: %v", err)
+    }
+
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking update result: %v", err)
+    }
+
+    if rowsAffected == 0 {
+        return fmt.Errorf("location not found")
+    }
+
+    return tx.Commit()
+}
+
+func (r *Repository) Delete(id int) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    // Nested locations move up one level; containers lose their location
+    childrenQuery := `
+        UPDATE location
+        SET parent_id = (SELECT parent_id FROM location WHERE id = $1),
+            updated_at = $2
+        WHERE parent_id = $1`
+
+    _, err = tx.Exec(childrenQuery, id, time.Now().UTC())
+    if err != nil {
+        return fmt.Errorf("error moving nested locations: %v", err)
+    }
+
+    result, err := tx.Exec(`DELETE FROM location WHERE id = $1`, id)
+    if err != nil {
+        return fmt.Errorf("error deleting location: %v", err)
+    }
+
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking delete result: %v", err)
+    }
+
+    if rowsAffected == 0 {
+        return fmt.Errorf("location not found")
+    }
+
+    return tx.Commit()
+}
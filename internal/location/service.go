@@ -0,0 +1,142 @@
+package location
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/pkg/utils"
+)
+
+type Service struct {
+    repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+    return &Service{repo: repo}
+}
+
+func (s *Service) CreateLocation(userID int, req *CreateLocationRequest) (*models.Location, error) {
+    name := strings.TrimSpace(req.Name)
+    if name == "" {
+        return nil, fmt.Errorf("location name is required")
+    }
+    if kindRank(req.Kind) < 0 {
+        return nil, ErrInvalidKind
+    }
+
+    ownerID, err := s.repo.GetWorkspaceOwner(req.WorkspaceID)
+    if err != nil {
+        return nil, err
+    }
+    if ownerID != userID {
+        return nil, ErrWorkspaceNotFound
+    }
+
+    if req.ParentID != nil {
+        if err := s.checkParent(*req.ParentID, req.WorkspaceID, req.Kind); err != nil {
+            return nil, err
+        }
+    }
+
+    // Location labels carry an opaque code, like container labels, so they
+    // can be scanned without revealing IDs
+    code, err := utils.NewQRToken()
+    if err != nil {
+        return nil, err
+    }
+
+    location := &models.Location{
+        WorkspaceID: req.WorkspaceID,
+        ParentID:    req.ParentID,
+        Name:        name,
+        Kind:        req.Kind,
+        Description: req.Description,
+        Code:        code,
+        CreatedAt:   time.Now().UTC(),
+        UpdatedAt:   time.Now().UTC(),
+    }
+
+    if err := s.repo.Create(location); err != nil {
+        return nil, fmt.Errorf("failed to create location: %v", err)
+    }
+
+    return s.repo.GetByID(location.ID)
+}
+
+func (s *Service) GetLocationByID(id int) (*models.Location, error) {
+    return s.repo.GetByID(id)
+}
+
+func (s *Service) GetWorkspaceTree(userID, workspaceID int) ([]models.Location, error) {
+    ownerID, err := s.repo.GetWorkspaceOwner(workspaceID)
+    if err != nil {
+        return nil, err
+    }
+    if ownerID != userID {
+        return nil, ErrWorkspaceNotFound
+    }
+
+    return s.repo.GetTreeByWorkspace(workspaceID)
+}
+
+func (s *Service) UpdateLocation(id int, req *UpdateLocationRequest) (*models.Location, error) {
+    location, err := s.repo.GetByID(id)
+    if err != nil {
+        return nil, fmt.Errorf("location not found: %v", err)
+    }
+
+    name := strings.TrimSpace(req.Name)
+    if name == "" {
+        return nil, fmt.Errorf("location name is required")
+    }
+    if kindRank(req.Kind) < 0 {
+        return nil, ErrInvalidKind
+    }
+
+    if req.ParentID != nil {
+        if *req.ParentID == id {
+            return nil, ErrLocationCycle
+        }
+        if err := s.checkParent(*req.ParentID, location.WorkspaceID, req.Kind); err != nil {
+            return nil, err
+        }
+    }
+
+    for _, child := range location.Children {
+        if kindRank(child.Kind) <= kindRank(req.Kind) {
+            return nil, ErrInvalidNesting
+        }
+    }
+
+    location.ParentID = req.ParentID
+    location.Name = name
+    location.Kind = req.Kind
+    location.Description = req.Description
+
+    if err := s.repo.Update(location); err != nil {
+        return nil, err
+    }
+
+    return s.repo.GetByID(id)
+}
+
+func (s *Service) DeleteLocation(id int) error {
+    return s.repo.Delete(id)
+}
+
+// checkParent verifies that parentID belongs to the workspace and is a wider
+// kind of place than kind.
+func (s *Service) checkParent(parentID, workspaceID int, kind string) error {
+    parent, err := s.repo.GetByID(parentID)
+    if err != nil || parent.WorkspaceID != workspaceID {
+        return ErrParentNotFound
+    }
+
+    if kindRank(kind) <= kindRank(parent.Kind) {
+        return ErrInvalidNesting
+    }
+
+    return nil
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Batch is part of an item's quantity that expires, or is best before, one
+// date. ExpiresOn is a calendar date, YYYY-MM-DD.
+type Batch struct {
+    ID        int       `json:"id"`
+    ItemID    int       `json:"itemId"`
+    Quantity  float64   `json:"quantity"`
+    ExpiresOn string    `json:"expiresOn"`
+    DateKind  string    `json:"dateKind"`
+    Lot       string    `json:"lot,omitempty"`
+    Expired   bool      `json:"expired"`
+    CreatedAt time.Time `json:"createdAt"`
+}
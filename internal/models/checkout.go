@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Checkout records a container or item leaving with someone. It stays open
+// until CheckedInAt is set, and is overdue once ExpectedReturnAt has passed.
+type Checkout struct {
+    ID               int        `json:"id"`
+    ContainerID      *int       `json:"containerId,omitempty"`
+    ItemID           *int       `json:"itemId,omitempty"`
+    Name             string     `json:"name,omitempty"`
+    UserID           int        `json:"userId"`
+    Holder           string     `json:"holder"`
+    Notes            string     `json:"notes,omitempty"`
+    CheckedOutAt     time.Time  `json:"checkedOutAt"`
+    ExpectedReturnAt *time.Time `json:"expectedReturnAt,omitempty"`
+    CheckedInAt      *time.Time `json:"checkedInAt,omitempty"`
+    ReturnNotes      string     `json:"returnNotes,omitempty"`
+    Overdue          bool       `json:"overdue"`
+}
@@ -3,17 +3,52 @@ package models
 import "time"
 
 type Container struct {
-    ID           int        `json:"id"`
-    Name         string     `json:"name"`
-    Description string      `json:"description"`
-    QRCode       string     `json:"qrCode"`
-    QRCodeImage  string     `json:"qrCodeImage"`
-    Number       int        `json:"number"`
-    Location     string     `json:"location"`
-    UserID       int        `json:"userId"`
-    WorkspaceID  *int       `json:"workspaceId,omitempty"`
-    Workspace    *Workspace `json:"workspace,omitempty"`
-    Items        []Item     `json:"items"`
-    CreatedAt    time.Time  `json:"createdAt"`
-    UpdatedAt    time.Time  `json:"updatedAt"`
+    ID                int          `json:"id"`
+    Name              string       `json:"name"`
+    Description       string       `json:"description"`
+    QRCode            string       `json:"qrCode"`
+    Number            int          `json:"number"`
+    Label             string       `json:"label"`
+    Symbology         string       `json:"symbology"`
+    Location          string       `json:"location"`
+    LocationID        *int         `json:"locationId,omitempty"`
+    LocationPath      []Breadcrumb `json:"locationPath,omitempty"`
+    UserID            int          `json:"userId"`
+    WorkspaceID       *int         `json:"workspaceId,omitempty"`
+    Workspace         *Workspace   `json:"workspace,omitempty"`
+    ParentContainerID *int         `json:"parentContainerId,omitempty"`
+    Path              []Breadcrumb `json:"path,omitempty"`
+    Children          []Container  `json:"children,omitempty"`
+    ItemCount         int          `json:"itemCount,omitempty"`
+    Items             []Item       `json:"items"`
+    Images            []ItemImage  `json:"images,omitempty"`
+    CoverImage        *ItemImage   `json:"coverImage,omitempty"`
+    CheckedOut        *Checkout    `json:"checkedOut,omitempty"`
+    Capacity          *Capacity    `json:"capacity,omitempty"`
+    FillPercent       *float64     `json:"fillPercent,omitempty"`
+    RetiredAt         *time.Time   `json:"retiredAt,omitempty"`
+    MergedIntoID      *int         `json:"mergedIntoId,omitempty"`
+    CreatedAt         time.Time    `json:"createdAt"`
+    UpdatedAt         time.Time    `json:"updatedAt"`
+}
+
+// Breadcrumb is one enclosing container on the way from the outermost box
+// down to an item or container.
+type Breadcrumb struct {
+    ID   int    `json:"id"`
+    Name string `json:"name"`
+}
+
+// Capacity describes how much a container holds. Mode picks what the fill
+// level is measured by: volume, from MaxVolumeL or else the inside
+// dimensions, weight against MaxWeightKg, or count against MaxItems. The
+// dimensions are also the space a nested container takes up in its parent.
+type Capacity struct {
+    Mode        string   `json:"mode,omitempty"`
+    WidthCm     *float64 `json:"widthCm,omitempty"`
+    DepthCm     *float64 `json:"depthCm,omitempty"`
+    HeightCm    *float64 `json:"heightCm,omitempty"`
+    MaxWeightKg *float64 `json:"maxWeightKg,omitempty"`
+    MaxVolumeL  *float64 `json:"maxVolumeL,omitempty"`
+    MaxItems    *int     `json:"maxItems,omitempty"`
 }
@@ -2,23 +2,71 @@ package models
 
 import "time"
 
+type ImageVariant struct {
+    URL      string `json:"url"`
+    WebPURL  string `json:"webpUrl,omitempty"`
+    Width    int    `json:"width"`
+    Height   int    `json:"height"`
+    ByteSize int64  `json:"byteSize"`
+}
+
 type ItemImage struct {
-    ID           int       `json:"id"`
-    URL          string    `json:"url"`
-    DisplayOrder int       `json:"displayOrder"`
-    CreatedAt    time.Time `json:"createdAt"`
-    UpdatedAt    time.Time `json:"updatedAt"`
+    ID           int                     `json:"id"`
+    URL          string                  `json:"url"`
+    Width        int                     `json:"width"`
+    Height       int                     `json:"height"`
+    ByteSize     int64                   `json:"byteSize"`
+    Variants     map[string]ImageVariant `json:"variants"`
+    DisplayOrder int                     `json:"displayOrder"`
+    CreatedAt    time.Time               `json:"createdAt"`
+    UpdatedAt    time.Time               `json:"updatedAt"`
 }
 
-type Item struct {
-    ID          int         `json:"id"`
-    Name        string      `json:"name"`
-    Description string      `json:"description"`
-    Images      []ItemImage `json:"images"`
-    Quantity    int         `json:"quantity"`
-    ContainerID *int        `json:"containerId,omitempty"`
-    Container   *Container  `json:"container,omitempty"`
-    Tags        []Tag       `json:"tags"`
-    CreatedAt   time.Time   `json:"createdAt"`
-    UpdatedAt   time.Time   `json:"updatedAt"`
+// Receipt is a photo or PDF of an item's proof of purchase.
+type Receipt struct {
+    ID          int       `json:"id"`
+    ItemID      int       `json:"itemId"`
+    URL         string    `json:"url"`
+    Filename    string    `json:"filename"`
+    ContentType string    `json:"contentType"`
+    ByteSize    int64     `json:"byteSize"`
+    CreatedAt   time.Time `json:"createdAt"`
 }
+
+type Item struct {
+    ID                int          `json:"id"`
+    Name              string       `json:"name"`
+    Description       string       `json:"description"`
+    Images            []ItemImage  `json:"images"`
+    Quantity          float64      `json:"quantity"`
+    Unit              string       `json:"unit"`
+    Barcode           string       `json:"barcode,omitempty"`
+    WeightKg          *float64     `json:"weightKg,omitempty"`
+    WidthCm           *float64     `json:"widthCm,omitempty"`
+    DepthCm           *float64     `json:"depthCm,omitempty"`
+    HeightCm          *float64     `json:"heightCm,omitempty"`
+    MinQuantity       *float64     `json:"minQuantity,omitempty"`
+    ReorderQuantity   *float64     `json:"reorderQuantity,omitempty"`
+    PreferredStore    string       `json:"preferredStore,omitempty"`
+    LowStock          bool         `json:"lowStock,omitempty"`
+    // Prices are per unit of Quantity. PurchaseDate is YYYY-MM-DD
+    PurchasePrice     *float64     `json:"purchasePrice,omitempty"`
+    Currency          string       `json:"currency,omitempty"`
+    PurchaseDate      string       `json:"purchaseDate,omitempty"`
+    Vendor            string       `json:"vendor,omitempty"`
+    EstimatedValue    *float64     `json:"estimatedValue,omitempty"`
+    ContainerID       *int         `json:"containerId,omitempty"`
+    Container         *Container   `json:"container,omitempty"`
+    Path              []Breadcrumb `json:"path,omitempty"`
+    Tags              []Tag        `json:"tags"`
+    CheckedOut        *Checkout    `json:"checkedOut,omitempty"`
+    // Outstanding loans, Quantity less what they hold, expiry batches and
+    // receipts,
+    // for single items only
+    Loans             []Loan       `json:"loans,omitempty"`
+    AvailableQuantity *float64     `json:"availableQuantity,omitempty"`
+    Batches           []Batch      `json:"batches,omitempty"`
+    Receipts          []Receipt    `json:"receipts,omitempty"`
+    CreatedAt         time.Time    `json:"createdAt"`
+    UpdatedAt         time.Time    `json:"updatedAt"`
+}
\ No newline at end of file
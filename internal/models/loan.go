@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Contact is someone items are lent to.
+type Contact struct {
+    ID               int       `json:"id"`
+    UserID           int       `json:"userId"`
+    Name             string    `json:"name"`
+    Email            string    `json:"email,omitempty"`
+    Phone            string    `json:"phone,omitempty"`
+    Notes            string    `json:"notes,omitempty"`
+    OutstandingLoans int       `json:"outstandingLoans"`
+    CreatedAt        time.Time `json:"createdAt"`
+    UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// Loan is part of an item's quantity lent to a contact. It is outstanding
+// until ReturnedAt is set, and overdue once DueAt has passed.
+type Loan struct {
+    ID          int        `json:"id"`
+    ItemID      int        `json:"itemId"`
+    ItemName    string     `json:"itemName,omitempty"`
+    ContactID   int        `json:"contactId"`
+    ContactName string     `json:"contactName,omitempty"`
+    UserID      int        `json:"userId"`
+    Quantity    float64    `json:"quantity"`
+    Notes       string     `json:"notes,omitempty"`
+    LentAt      time.Time  `json:"lentAt"`
+    DueAt       *time.Time `json:"dueAt,omitempty"`
+    ReturnedAt  *time.Time `json:"returnedAt,omitempty"`
+    Overdue     bool       `json:"overdue"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Location struct {
+    ID             int          `json:"id"`
+    WorkspaceID    int          `json:"workspaceId"`
+    UserID         int          `json:"userId"`
+    ParentID       *int         `json:"parentId,omitempty"`
+    Name           string       `json:"name"`
+    Kind           string       `json:"kind"`
+    Description    string       `json:"description"`
+    Code           string       `json:"code"`
+    Path           []Breadcrumb `json:"path,omitempty"`
+    Children       []Location   `json:"children,omitempty"`
+    ContainerCount int          `json:"containerCount"`
+    CreatedAt      time.Time    `json:"createdAt"`
+    UpdatedAt      time.Time    `json:"updatedAt"`
+}
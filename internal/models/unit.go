@@ -0,0 +1,16 @@
+package models
+
+// Unit is a unit of measure items can be counted in. Factor converts a
+// quantity in this unit to the base unit of its dimension.
+type Unit struct {
+    Code      string  `json:"code"`
+    Name      string  `json:"name"`
+    Dimension string  `json:"dimension"`
+    Factor    float64 `json:"factor"`
+}
+
+// Whole reports whether quantities in u must be whole numbers, as they are
+// for anything counted in pieces.
+func (u *Unit) Whole() bool {
+    return u.Dimension == "count"
+}
@@ -3,13 +3,18 @@ package models
 import "time"
 
 type User struct {
-	ID         int         `json:"id"`
-	Email      string      `json:"email"`
-	Password   string      `json:"-"`
-	FirstName  string      `json:"firstName"`
-	LastName   string      `json:"lastName"`
-	ImageURL   string      `json:"imageUrl"`
-	Containers []Container `json:"containers"`
-	CreatedAt  time.Time   `json:"createdAt"`
-	UpdatedAt  time.Time   `json:"updatedAt"`
+	ID           int         `json:"id"`
+	Email        string      `json:"email"`
+	Password     string      `json:"-"`
+	FirstName    string      `json:"firstName"`
+	LastName     string      `json:"lastName"`
+	ImageURL     string      `json:"imageUrl"`
+	ImageBytes   int64       `json:"-"`
+	ImageBlobKey string      `json:"-"`
+	Containers   []Container `json:"containers"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+
+	StorageUsedBytes  int64  `json:"storageUsedBytes"`
+	StorageQuotaBytes *int64 `json:"storageQuotaBytes,omitempty"`
 }
@@ -15,7 +15,9 @@ type PostgresDB struct {
     migrationsManager *migrations.Manager
 }
 
-func NewPostgresDB() (*PostgresDB, error) {
+// NewPostgresDB connects to the database. containerPrefix labels containers
+// that migrations number.
+func NewPostgresDB(containerPrefix string) (*PostgresDB, error) {
     password := os.Getenv("POSTGRES_PASSWORD")
     
     connStr := fmt.Sprintf(
@@ -33,7 +35,7 @@ func NewPostgresDB() (*PostgresDB, error) {
     }
 
     postgresDB := &PostgresDB{DB: db}
-    postgresDB.migrationsManager = migrations.NewManager(db)
+    postgresDB.migrationsManager = migrations.NewManager(db, containerPrefix)
 
     return postgresDB, nil
 }
\ No newline at end of file
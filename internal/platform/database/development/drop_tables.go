@@ -17,13 +17,37 @@ func DropAllTables(db *sql.DB) error {
     }
 
     dropQuery := `
+        DROP TABLE IF EXISTS item_loan CASCADE;
+        DROP TABLE IF EXISTS contact CASCADE;
+        DROP TABLE IF EXISTS checkout CASCADE;
+        DROP TABLE IF EXISTS scan_history CASCADE;
+        DROP TABLE IF EXISTS container_share CASCADE;
+        DROP TABLE IF EXISTS blob_outbox CASCADE;
+        DROP TABLE IF EXISTS blob CASCADE;
         DROP TABLE IF EXISTS item_tag CASCADE;
         DROP TABLE IF EXISTS tag CASCADE;
+        DROP TABLE IF EXISTS stock_movement CASCADE;
+        DROP TABLE IF EXISTS item_batch CASCADE;
+        DROP TABLE IF EXISTS item_receipt CASCADE;
         DROP TABLE IF EXISTS item_image CASCADE;
         DROP TABLE IF EXISTS item CASCADE;
+        DROP TABLE IF EXISTS unit CASCADE;
+        DROP TABLE IF EXISTS container_image CASCADE;
+        DROP TABLE IF EXISTS container_event CASCADE;
+        DROP TABLE IF EXISTS container_counter CASCADE;
         DROP TABLE IF EXISTS container CASCADE;
+        DROP TABLE IF EXISTS location CASCADE;
         DROP TABLE IF EXISTS workspace CASCADE;
         DROP TABLE IF EXISTS users CASCADE;
+        DROP TABLE IF EXISTS schema_migration CASCADE;
+        DROP FUNCTION IF EXISTS stock_movement_append_only();
+        DROP FUNCTION IF EXISTS item_batches(INTEGER);
+        DROP FUNCTION IF EXISTS outstanding_loans(INTEGER);
+        DROP FUNCTION IF EXISTS open_checkout(INTEGER, INTEGER);
+        DROP FUNCTION IF EXISTS container_fill(INTEGER);
+        DROP FUNCTION IF EXISTS container_path(INTEGER);
+        DROP FUNCTION IF EXISTS location_path(INTEGER);
+        DROP FUNCTION IF EXISTS location_subtree(INTEGER);
     `
 
     fmt.Println("Executing drop tables...")
@@ -7,15 +7,30 @@ import (
 func (db *PostgresDB) Init() error {
     fmt.Println("Starting database initialization...")
 
+    existing, err := db.schemaExists()
+    if err != nil {
+        return err
+    }
+
+    // The schema below indexes and references the newest columns, so an
+    // existing database has to be migrated before it runs
+    if existing {
+        fmt.Println("Running migrations...")
+        if err := db.migrationsManager.Run(); err != nil {
+            return fmt.Errorf("migrations failed: %v", err)
+        }
+    }
+
     if err := db.initializeSchema(); err != nil {
         return fmt.Errorf("schema initialization failed: %v", err)
     }
 
-    // db.migrationsManager.EnableMigration("005_tag_description")
-    // fmt.Println("Running data migration...")
-    // if err := db.migrationsManager.Run(); err != nil {
-    //     return fmt.Errorf("migrations failed: %v", err)
-    // }
+    // A new database starts out with the newest schema
+    if !existing {
+        if err := db.migrationsManager.MarkApplied(); err != nil {
+            return fmt.Errorf("recording migrations failed: %v", err)
+        }
+    }
 
     // UNCOMMENTING WILL DROP ALL TABLES IN DEV
     // if err := development.DropAllTables(db.DB); err != nil {
@@ -25,6 +40,15 @@ func (db *PostgresDB) Init() error {
     return nil
 }
 
+// schemaExists reports whether the database was initialised before.
+func (db *PostgresDB) schemaExists() (bool, error) {
+    var exists bool
+    if err := db.QueryRow(`SELECT to_regclass('public.users') IS NOT NULL`).Scan(&exists); err != nil {
+        return false, fmt.Errorf("error checking for an existing schema: %v", err)
+    }
+    return exists, nil
+}
+
 func (db *PostgresDB) initializeSchema() error {
 
     fmt.Println("Ensuring users table exists...")
@@ -37,6 +61,11 @@ func (db *PostgresDB) initializeSchema() error {
         return err
     }
 
+    fmt.Println("Ensuring location table exists...")
+    if err := db.createLocationTable(); err != nil {
+        return err
+    }
+
     fmt.Println("Ensuring container table exists...")
     if err := db.createContainerTable(); err != nil {
         return err
@@ -47,5 +76,30 @@ func (db *PostgresDB) initializeSchema() error {
         return err
     }
 
+    fmt.Println("Ensuring checkout tables exist...")
+    if err := db.createCheckoutTables(); err != nil {
+        return err
+    }
+
+    fmt.Println("Ensuring loan tables exist...")
+    if err := db.createLoanTables(); err != nil {
+        return err
+    }
+
+    fmt.Println("Ensuring share tables exist...")
+    if err := db.createShareTables(); err != nil {
+        return err
+    }
+
+    fmt.Println("Ensuring scan tables exist...")
+    if err := db.createScanTables(); err != nil {
+        return err
+    }
+
+    fmt.Println("Ensuring blob tables exist...")
+    if err := db.createBlobTables(); err != nil {
+        return err
+    }
+
     return nil
 }
\ No newline at end of file
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateItemImageVariants(tx *sql.Tx) error {
+    queries := []string{
+        // Dimensions and size of the processed original
+        `ALTER TABLE item_image 
+         ADD COLUMN IF NOT EXISTS width INTEGER,
+         ADD COLUMN IF NOT EXISTS height INTEGER,
+         ADD COLUMN IF NOT EXISTS byte_size BIGINT;`,
+
+        // Thumbnail and WebP renditions keyed by size name
+        `ALTER TABLE item_image 
+         ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '{}'::jsonb;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute item image variants migration query: %v", err)
+        }
+    }
+
+    return nil
+}
\ No newline at end of file
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateBlobOutbox(tx *sql.Tx) error {
+    queries := []string{
+        // Objects waiting to be removed from the bucket after their rows were deleted
+        `CREATE TABLE IF NOT EXISTS blob_outbox (
+            id SERIAL PRIMARY KEY,
+            object_key TEXT NOT NULL,
+            attempts INTEGER NOT NULL DEFAULT 0,
+            last_error TEXT,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_blob_outbox_pending 
+         ON blob_outbox(attempts, id);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute blob outbox migration query: %v", err)
+        }
+    }
+
+    return nil
+}
\ No newline at end of file
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateStorageQuotas(tx *sql.Tx) error {
+    queries := []string{
+        // Per-user usage counter and optional quota override
+        `ALTER TABLE users 
+         ADD COLUMN IF NOT EXISTS image_bytes BIGINT NOT NULL DEFAULT 0,
+         ADD COLUMN IF NOT EXISTS storage_used_bytes BIGINT NOT NULL DEFAULT 0,
+         ADD COLUMN IF NOT EXISTS storage_quota_bytes BIGINT;`,
+
+        // Track who uploaded each image and how much storage it consumes
+        `ALTER TABLE item_image 
+         ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+         ADD COLUMN IF NOT EXISTS storage_bytes BIGINT NOT NULL DEFAULT 0;`,
+
+        // Attribute existing images to the owner of the item's container
+        `UPDATE item_image img
+         SET user_id = c.user_id,
+             storage_bytes = COALESCE(img.byte_size, 0)
+         FROM item i
+         JOIN container c ON i.container_id = c.id
+         WHERE img.item_id = i.id AND img.user_id IS NULL;`,
+
+        `UPDATE users u
+         SET storage_used_bytes = u.image_bytes + COALESCE((
+             SELECT SUM(storage_bytes) FROM item_image WHERE user_id = u.id
+         ), 0);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_image_user_id 
+         ON item_image(user_id);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute storage quota migration query: %v", err)
+        }
+    }
+
+    return nil
+}
\ No newline at end of file
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateContentAddressedBlobs(tx *sql.Tx) error {
+    queries := []string{
+        // Registry of uploaded content keyed by hash, shared between references
+        `CREATE TABLE IF NOT EXISTS blob (
+            key TEXT PRIMARY KEY,
+            url TEXT NOT NULL,
+            width INTEGER NOT NULL DEFAULT 0,
+            height INTEGER NOT NULL DEFAULT 0,
+            byte_size BIGINT NOT NULL DEFAULT 0,
+            storage_bytes BIGINT NOT NULL DEFAULT 0,
+            variants JSONB NOT NULL DEFAULT '{}'::jsonb,
+            ref_count INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+        // Existing uploads keep their own objects and have no blob key
+        `ALTER TABLE item_image 
+         ADD COLUMN IF NOT EXISTS blob_key TEXT;`,
+
+        `ALTER TABLE users 
+         ADD COLUMN IF NOT EXISTS image_blob_key TEXT;`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_image_blob_key 
+         ON item_image(blob_key);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute content addressed blob migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateImageObjectKeys(tx *sql.Tx) error {
+    // Public bucket URLs become plain object keys; the API signs them on read
+    bucketURL := `'https://[^/"]+\.amazonaws\.com/'`
+
+    queries := []string{
+        `UPDATE item_image 
+         SET url = regexp_replace(url, ` + bucketURL + `, ''),
+             variants = regexp_replace(variants::text, ` + bucketURL + `, '', 'g')::jsonb
+         WHERE url ~ ` + bucketURL + `;`,
+
+        `UPDATE blob 
+         SET url = regexp_replace(url, ` + bucketURL + `, ''),
+             variants = regexp_replace(variants::text, ` + bucketURL + `, '', 'g')::jsonb
+         WHERE url ~ ` + bucketURL + `;`,
+
+        `UPDATE users 
+         SET image_url = regexp_replace(image_url, ` + bucketURL + `, '')
+         WHERE image_url ~ ` + bucketURL + `;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute image object key migration query: %v", err)
+        }
+    }
+
+    return nil
+}
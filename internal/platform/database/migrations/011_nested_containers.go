@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateNestedContainers(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE container 
+         ADD COLUMN IF NOT EXISTS parent_container_id INTEGER REFERENCES container(id) ON DELETE SET NULL;`,
+
+        `DO $$
+         BEGIN
+             IF NOT EXISTS (
+                 SELECT 1 FROM pg_constraint WHERE conname = 'chk_container_not_own_parent'
+             ) THEN
+                 ALTER TABLE container 
+                 ADD CONSTRAINT chk_container_not_own_parent CHECK (parent_container_id <> id);
+             END IF;
+         END $$;`,
+
+        `CREATE INDEX IF NOT EXISTS idx_container_parent 
+         ON container(parent_container_id);`,
+
+        // Breadcrumb of enclosing containers, outermost first, ending with start_id
+        `CREATE OR REPLACE FUNCTION container_path(start_id INTEGER) RETURNS JSONB AS $$
+            WITH RECURSIVE ancestors AS (
+                SELECT id, name, parent_container_id, 0 AS depth
+                FROM container
+                WHERE id = start_id
+                UNION ALL
+                SELECT c.id, c.name, c.parent_container_id, a.depth + 1
+                FROM container c
+                JOIN ancestors a ON c.id = a.parent_container_id
+                WHERE a.depth < 32
+            )
+            SELECT COALESCE(
+                jsonb_agg(jsonb_build_object('id', id, 'name', name) ORDER BY depth DESC),
+                '[]'::jsonb
+            )
+            FROM ancestors
+        $$ LANGUAGE sql STABLE;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute nested container migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateLocations(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS location (
+            id SERIAL PRIMARY KEY,
+            workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+            parent_id INTEGER REFERENCES location(id) ON DELETE SET NULL,
+            name VARCHAR(100) NOT NULL,
+            kind VARCHAR(20) NOT NULL,
+            description TEXT,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_location_not_own_parent CHECK (parent_id <> id)
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_location_workspace 
+         ON location(workspace_id);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_location_parent 
+         ON location(parent_id);`,
+
+        `CREATE UNIQUE INDEX IF NOT EXISTS idx_location_sibling_name 
+         ON location(workspace_id, COALESCE(parent_id, 0), LOWER(name));`,
+
+        `CREATE OR REPLACE FUNCTION location_path(start_id INTEGER) RETURNS JSONB AS $$
+            WITH RECURSIVE ancestors AS (
+                SELECT id, name, parent_id, 0 AS depth
+                FROM location
+                WHERE id = start_id
+                UNION ALL
+                SELECT l.id, l.name, l.parent_id, a.depth + 1
+                FROM location l
+                JOIN ancestors a ON l.id = a.parent_id
+                WHERE a.depth < 32
+            )
+            SELECT COALESCE(
+                jsonb_agg(jsonb_build_object('id', id, 'name', name) ORDER BY depth DESC),
+                '[]'::jsonb
+            )
+            FROM ancestors
+        $$ LANGUAGE sql STABLE;`,
+
+        `CREATE OR REPLACE FUNCTION location_subtree(root_id INTEGER) RETURNS SETOF INTEGER AS $$
+            WITH RECURSIVE subtree AS (
+                SELECT id FROM location WHERE id = root_id
+                UNION
+                SELECT l.id FROM location l JOIN subtree s ON l.parent_id = s.id
+            )
+            SELECT id FROM subtree
+        $$ LANGUAGE sql STABLE;`,
+
+        `ALTER TABLE container 
+         ADD COLUMN IF NOT EXISTS location_id INTEGER REFERENCES location(id) ON DELETE SET NULL;`,
+
+        `CREATE INDEX IF NOT EXISTS idx_container_location 
+         ON container(location_id);`,
+
+        // Turn the free-text locations of each workspace into site nodes,
+        // treating differences in case and surrounding whitespace as the same place
+        `INSERT INTO location (workspace_id, name, kind, created_at, updated_at)
+         SELECT DISTINCT ON (c.workspace_id, LOWER(TRIM(c.location)))
+                c.workspace_id, TRIM(c.location), 'site', NOW(), NOW()
+         FROM container c
+         WHERE c.workspace_id IS NOT NULL AND TRIM(COALESCE(c.location, '')) <> ''
+         ORDER BY c.workspace_id, LOWER(TRIM(c.location)), c.created_at
+         ON CONFLICT DO NOTHING;`,
+
+        `UPDATE container c
+         SET location_id = l.id
+         FROM location l
+         WHERE c.location_id IS NULL 
+           AND l.workspace_id = c.workspace_id 
+           AND l.parent_id IS NULL
+           AND LOWER(l.name) = LOWER(TRIM(c.location));`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute location migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// MigrateContainerNumbering moves containers and workspaces back onto their
+// SERIAL sequences and gives every unlabelled container a per-user number.
+// Containers numbered here get labelPrefix, the configured container prefix.
+func MigrateContainerNumbering(tx *sql.Tx, labelPrefix string) error {
+    queries := []string{
+        // Rows inserted with random explicit IDs never advanced the sequences
+        `SELECT setval(pg_get_serial_sequence('container', 'id'),
+                COALESCE((SELECT MAX(id) FROM container), 1),
+                (SELECT MAX(id) FROM container) IS NOT NULL);`,
+
+        `SELECT setval(pg_get_serial_sequence('workspace', 'id'),
+                COALESCE((SELECT MAX(id) FROM workspace), 1),
+                (SELECT MAX(id) FROM workspace) IS NOT NULL);`,
+
+        `ALTER TABLE container 
+         ADD COLUMN IF NOT EXISTS label VARCHAR(20);`,
+
+        `CREATE TABLE IF NOT EXISTS container_counter (
+            user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+            last_number INTEGER NOT NULL DEFAULT 0
+        );`,
+
+        // Renumbering would trip over the old random numbers mid-update
+        `DROP INDEX IF EXISTS idx_container_user_number;`,
+
+        `WITH numbered AS (
+            SELECT c.id,
+                   COALESCE(cc.last_number, 0) + ROW_NUMBER() OVER (
+                       PARTITION BY c.user_id ORDER BY c.created_at, c.id
+                   ) AS number
+            FROM container c
+            LEFT JOIN container_counter cc ON cc.user_id = c.user_id
+            WHERE c.label IS NULL
+        )
+        UPDATE container c
+        SET number = n.number,
+            label = ` + pq.QuoteLiteral(labelPrefix+"-") + ` || LPAD(n.number::text, GREATEST(4, LENGTH(n.number::text)), '0')
+        FROM numbered n
+        WHERE c.id = n.id;`,
+
+        `INSERT INTO container_counter (user_id, last_number)
+         SELECT user_id, MAX(number) FROM container GROUP BY user_id
+         ON CONFLICT (user_id) DO UPDATE 
+         SET last_number = GREATEST(container_counter.last_number, EXCLUDED.last_number);`,
+
+        `CREATE UNIQUE INDEX IF NOT EXISTS idx_container_user_number 
+         ON container(user_id, number);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute container numbering migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// MigrateDropQRCodeImage removes the stored base64 QR images; labels are now
+// rendered on demand from the container's token.
+func MigrateDropQRCodeImage(tx *sql.Tx) error {
+    query := `ALTER TABLE container DROP COLUMN IF EXISTS qr_code_image;`
+
+    if _, err := tx.Exec(query); err != nil {
+        return fmt.Errorf("failed to drop qr_code_image column: %v", err)
+    }
+
+    return nil
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateContainerSymbology(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE container 
+         ADD COLUMN IF NOT EXISTS symbology VARCHAR(20) NOT NULL DEFAULT 'qr';`,
+
+        `DO $$
+         BEGIN
+             IF NOT EXISTS (
+                 SELECT 1 FROM pg_constraint WHERE conname = 'chk_container_symbology'
+             ) THEN
+                 ALTER TABLE container 
+                 ADD CONSTRAINT chk_container_symbology CHECK (symbology IN ('qr', 'datamatrix', 'code128'));
+             END IF;
+         END $$;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute container symbology migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateScanCodes(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE item 
+         ADD COLUMN IF NOT EXISTS barcode VARCHAR(64);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_barcode ON item(barcode);`,
+
+        `ALTER TABLE location 
+         ADD COLUMN IF NOT EXISTS code VARCHAR(100) UNIQUE;`,
+
+        // Same shape as the tokens the application generates: 16 random
+        // bytes, base64url without padding
+        `UPDATE location 
+         SET code = translate(
+             rtrim(encode(decode(md5(random()::text || clock_timestamp()::text || id::text), 'hex'), 'base64'), '='),
+             '+/', '-_'
+         )
+         WHERE code IS NULL;`,
+
+        `CREATE TABLE IF NOT EXISTS scan_history (
+            id SERIAL PRIMARY KEY,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            entity_type VARCHAR(20) NOT NULL,
+            entity_id INTEGER NOT NULL,
+            code VARCHAR(255) NOT NULL,
+            scanned_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_scan_entity_type CHECK (entity_type IN ('container', 'item', 'location'))
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_scan_history_user ON scan_history(user_id, scanned_at DESC);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute scan codes migration query: %v", err)
+        }
+    }
+
+    return nil
+}
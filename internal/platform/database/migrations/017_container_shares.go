@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateContainerShares(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS container_share (
+            id SERIAL PRIMARY KEY,
+            container_id INTEGER NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            token VARCHAR(100) UNIQUE NOT NULL,
+            fields TEXT[] NOT NULL DEFAULT '{}',
+            expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+            revoked_at TIMESTAMP WITH TIME ZONE,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_container_share_container ON container_share(container_id);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute container shares migration query: %v", err)
+        }
+    }
+
+    return nil
+}
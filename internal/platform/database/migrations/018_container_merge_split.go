@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateContainerMergeSplit(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE container 
+         ADD COLUMN IF NOT EXISTS retired_at TIMESTAMP WITH TIME ZONE;`,
+
+        `ALTER TABLE container 
+         ADD COLUMN IF NOT EXISTS merged_into_id INTEGER REFERENCES container(id) ON DELETE SET NULL;`,
+
+        `CREATE TABLE IF NOT EXISTS container_event (
+            id SERIAL PRIMARY KEY,
+            container_id INTEGER NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            action VARCHAR(20) NOT NULL,
+            related_container_id INTEGER REFERENCES container(id) ON DELETE SET NULL,
+            item_ids INTEGER[] NOT NULL DEFAULT '{}',
+            child_container_ids INTEGER[] NOT NULL DEFAULT '{}',
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_container_event_container ON container_event(container_id, created_at DESC);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute container merge/split migration query: %v", err)
+        }
+    }
+
+    return nil
+}
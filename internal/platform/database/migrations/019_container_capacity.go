@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateContainerCapacity(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE container 
+         ADD COLUMN IF NOT EXISTS capacity_mode VARCHAR(10),
+         ADD COLUMN IF NOT EXISTS width_cm NUMERIC(8, 2),
+         ADD COLUMN IF NOT EXISTS depth_cm NUMERIC(8, 2),
+         ADD COLUMN IF NOT EXISTS height_cm NUMERIC(8, 2),
+         ADD COLUMN IF NOT EXISTS max_weight_kg NUMERIC(10, 3),
+         ADD COLUMN IF NOT EXISTS max_volume_l NUMERIC(10, 2),
+         ADD COLUMN IF NOT EXISTS max_items INTEGER;`,
+
+        `DO $$
+         BEGIN
+             IF NOT EXISTS (
+                 SELECT 1 FROM pg_constraint WHERE conname = 'chk_container_capacity_mode'
+             ) THEN
+                 ALTER TABLE container 
+                 ADD CONSTRAINT chk_container_capacity_mode CHECK (capacity_mode IN ('volume', 'weight', 'count'));
+             END IF;
+         END $$;`,
+
+        `ALTER TABLE item 
+         ADD COLUMN IF NOT EXISTS weight_kg NUMERIC(10, 3),
+         ADD COLUMN IF NOT EXISTS width_cm NUMERIC(8, 2),
+         ADD COLUMN IF NOT EXISTS depth_cm NUMERIC(8, 2),
+         ADD COLUMN IF NOT EXISTS height_cm NUMERIC(8, 2);`,
+
+        `CREATE OR REPLACE FUNCTION container_fill(cid INTEGER) RETURNS NUMERIC AS $$
+            SELECT ROUND(100 * CASE c.capacity_mode
+                WHEN 'count' THEN
+                    (SELECT COALESCE(SUM(COALESCE(i.quantity, 1)), 0) FROM item i WHERE i.container_id = c.id)
+                    / NULLIF(c.max_items, 0)::NUMERIC
+                WHEN 'weight' THEN
+                    (SELECT COALESCE(SUM(i.weight_kg * COALESCE(i.quantity, 1)), 0) FROM item i WHERE i.container_id = c.id)
+                    / NULLIF(c.max_weight_kg, 0)
+                WHEN 'volume' THEN
+                    (
+                        (SELECT COALESCE(SUM(i.width_cm * i.depth_cm * i.height_cm * COALESCE(i.quantity, 1)), 0)
+                         FROM item i WHERE i.container_id = c.id)
+                        + (SELECT COALESCE(SUM(n.width_cm * n.depth_cm * n.height_cm), 0)
+                           FROM container n WHERE n.parent_container_id = c.id AND n.retired_at IS NULL)
+                    ) / 1000
+                    / NULLIF(COALESCE(c.max_volume_l, c.width_cm * c.depth_cm * c.height_cm / 1000), 0)
+            END, 1)
+            FROM container c
+            WHERE c.id = cid
+        $$ LANGUAGE sql STABLE;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute container capacity migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateContainerImages(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS container_image (
+            id SERIAL PRIMARY KEY,
+            container_id INTEGER NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            blob_key TEXT,
+            url TEXT NOT NULL,
+            width INTEGER,
+            height INTEGER,
+            byte_size BIGINT,
+            storage_bytes BIGINT NOT NULL DEFAULT 0,
+            variants JSONB NOT NULL DEFAULT '{}'::jsonb,
+            display_order INTEGER NOT NULL DEFAULT 0,
+            is_cover BOOLEAN NOT NULL DEFAULT FALSE,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_container_image_display_order 
+         ON container_image(container_id, display_order);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_container_image_user_id 
+         ON container_image(user_id);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_container_image_blob_key 
+         ON container_image(blob_key);`,
+
+        `CREATE UNIQUE INDEX IF NOT EXISTS idx_container_image_cover 
+         ON container_image(container_id) WHERE is_cover;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute container images migration query: %v", err)
+        }
+    }
+
+    return nil
+}
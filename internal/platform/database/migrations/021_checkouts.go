@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateCheckouts(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS checkout (
+            id SERIAL PRIMARY KEY,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            container_id INTEGER REFERENCES container(id) ON DELETE CASCADE,
+            item_id INTEGER REFERENCES item(id) ON DELETE CASCADE,
+            holder VARCHAR(100) NOT NULL,
+            notes TEXT NOT NULL DEFAULT '',
+            checked_out_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            expected_return_at TIMESTAMP WITH TIME ZONE,
+            checked_in_at TIMESTAMP WITH TIME ZONE,
+            return_notes TEXT NOT NULL DEFAULT '',
+            CONSTRAINT chk_checkout_entity CHECK (num_nonnulls(container_id, item_id) = 1)
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_checkout_container 
+         ON checkout(container_id, checked_out_at DESC);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_checkout_item 
+         ON checkout(item_id, checked_out_at DESC);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_checkout_user_open 
+         ON checkout(user_id, expected_return_at) WHERE checked_in_at IS NULL;`,
+
+        `CREATE UNIQUE INDEX IF NOT EXISTS idx_checkout_open_container 
+         ON checkout(container_id) WHERE checked_in_at IS NULL;`,
+
+        `CREATE UNIQUE INDEX IF NOT EXISTS idx_checkout_open_item 
+         ON checkout(item_id) WHERE checked_in_at IS NULL;`,
+
+        `CREATE OR REPLACE FUNCTION open_checkout(cid INTEGER, iid INTEGER) RETURNS JSONB AS $$
+            SELECT jsonb_strip_nulls(jsonb_build_object(
+                'id', co.id,
+                'containerId', co.container_id,
+                'itemId', co.item_id,
+                'userId', co.user_id,
+                'holder', co.holder,
+                'notes', NULLIF(co.notes, ''),
+                'checkedOutAt', co.checked_out_at,
+                'expectedReturnAt', co.expected_return_at,
+                'overdue', COALESCE(co.expected_return_at < NOW(), FALSE)
+            ))
+            FROM checkout co
+            WHERE co.checked_in_at IS NULL AND (co.container_id = cid OR co.item_id = iid)
+            LIMIT 1
+        $$ LANGUAGE sql STABLE;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute checkout migration query: %v", err)
+        }
+    }
+
+    return nil
+}
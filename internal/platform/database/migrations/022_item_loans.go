@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateItemLoans(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS contact (
+            id SERIAL PRIMARY KEY,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            name VARCHAR(100) NOT NULL,
+            email VARCHAR(255) NOT NULL DEFAULT '',
+            phone VARCHAR(50) NOT NULL DEFAULT '',
+            notes TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_contact_user ON contact(user_id, name);`,
+
+        `CREATE TABLE IF NOT EXISTS item_loan (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            contact_id INTEGER NOT NULL REFERENCES contact(id) ON DELETE CASCADE,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            quantity INTEGER NOT NULL,
+            notes TEXT NOT NULL DEFAULT '',
+            lent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            due_at TIMESTAMP WITH TIME ZONE,
+            returned_at TIMESTAMP WITH TIME ZONE,
+            CONSTRAINT chk_item_loan_quantity CHECK (quantity > 0)
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_loan_item ON item_loan(item_id, lent_at DESC);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_loan_contact ON item_loan(contact_id, lent_at DESC);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_loan_outstanding ON item_loan(item_id) WHERE returned_at IS NULL;`,
+
+        `CREATE OR REPLACE FUNCTION outstanding_loans(iid INTEGER) RETURNS JSONB AS $$
+            SELECT COALESCE(jsonb_agg(jsonb_strip_nulls(jsonb_build_object(
+                'id', l.id,
+                'itemId', l.item_id,
+                'contactId', l.contact_id,
+                'contactName', ct.name,
+                'userId', l.user_id,
+                'quantity', l.quantity,
+                'notes', NULLIF(l.notes, ''),
+                'lentAt', l.lent_at,
+                'dueAt', l.due_at,
+                'overdue', COALESCE(l.due_at < NOW(), FALSE)
+            )) ORDER BY l.lent_at, l.id), '[]'::jsonb)
+            FROM item_loan l
+            JOIN contact ct ON l.contact_id = ct.id
+            WHERE l.item_id = iid AND l.returned_at IS NULL
+        $$ LANGUAGE sql STABLE;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute item loan migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateStockMovements(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS stock_movement (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            delta INTEGER NOT NULL,
+            reason VARCHAR(20) NOT NULL,
+            note TEXT NOT NULL DEFAULT '',
+            quantity_after INTEGER NOT NULL,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_stock_movement_delta CHECK (delta <> 0),
+            CONSTRAINT chk_stock_movement_reason CHECK (reason IN ('consumed', 'purchased', 'found', 'lost', 'correction'))
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_stock_movement_item 
+         ON stock_movement(item_id, created_at DESC);`,
+
+        `CREATE OR REPLACE FUNCTION stock_movement_append_only() RETURNS TRIGGER AS $$
+         BEGIN
+             -- Deleting a user nulls out user_id; nothing else may change.
+             IF NEW.user_id IS NULL AND ROW(NEW.id, NEW.item_id, NEW.delta, NEW.reason, NEW.note, NEW.quantity_after, NEW.created_at)
+                 IS NOT DISTINCT FROM ROW(OLD.id, OLD.item_id, OLD.delta, OLD.reason, OLD.note, OLD.quantity_after, OLD.created_at) THEN
+                 RETURN NEW;
+             END IF;
+             RAISE EXCEPTION 'stock movements cannot be changed';
+         END;
+         $$ LANGUAGE plpgsql;`,
+
+        `DROP TRIGGER IF EXISTS trg_stock_movement_append_only ON stock_movement;`,
+
+        `CREATE TRIGGER trg_stock_movement_append_only
+             BEFORE UPDATE ON stock_movement
+             FOR EACH ROW EXECUTE FUNCTION stock_movement_append_only();`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute stock movement migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateLowStock(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE item 
+         ADD COLUMN IF NOT EXISTS min_quantity INTEGER,
+         ADD COLUMN IF NOT EXISTS reorder_quantity INTEGER,
+         ADD COLUMN IF NOT EXISTS preferred_store VARCHAR(100);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_low_stock 
+         ON item(container_id) WHERE quantity < min_quantity;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute low stock migration query: %v", err)
+        }
+    }
+
+    return nil
+}
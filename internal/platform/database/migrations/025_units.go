@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateUnits(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS unit (
+            code VARCHAR(10) PRIMARY KEY,
+            name VARCHAR(50) NOT NULL,
+            dimension VARCHAR(10) NOT NULL,
+            factor NUMERIC(20, 10) NOT NULL,
+            CONSTRAINT chk_unit_dimension CHECK (dimension IN ('count', 'length', 'volume', 'mass')),
+            CONSTRAINT chk_unit_factor CHECK (factor > 0)
+        );`,
+
+        `INSERT INTO unit (code, name, dimension, factor) VALUES
+            ('each', 'each', 'count', 1),
+            ('pair', 'pair', 'count', 2),
+            ('dozen', 'dozen', 'count', 12),
+            ('mm', 'millimetre', 'length', 0.001),
+            ('cm', 'centimetre', 'length', 0.01),
+            ('m', 'metre', 'length', 1),
+            ('km', 'kilometre', 'length', 1000),
+            ('in', 'inch', 'length', 0.0254),
+            ('ft', 'foot', 'length', 0.3048),
+            ('yd', 'yard', 'length', 0.9144),
+            ('ml', 'millilitre', 'volume', 0.001),
+            ('l', 'litre', 'volume', 1),
+            ('floz', 'US fluid ounce', 'volume', 0.0295735296),
+            ('gal', 'US gallon', 'volume', 3.785411784),
+            ('mg', 'milligram', 'mass', 0.000001),
+            ('g', 'gram', 'mass', 0.001),
+            ('kg', 'kilogram', 'mass', 1),
+            ('oz', 'ounce', 'mass', 0.028349523125),
+            ('lb', 'pound', 'mass', 0.45359237)
+        ON CONFLICT (code) DO NOTHING;`,
+
+        `ALTER TABLE item 
+         ADD COLUMN IF NOT EXISTS unit VARCHAR(10) NOT NULL DEFAULT 'each' REFERENCES unit(code);`,
+
+        `ALTER TABLE item 
+         ALTER COLUMN quantity TYPE NUMERIC(14, 3),
+         ALTER COLUMN min_quantity TYPE NUMERIC(14, 3),
+         ALTER COLUMN reorder_quantity TYPE NUMERIC(14, 3);`,
+
+        `ALTER TABLE stock_movement 
+         ALTER COLUMN delta TYPE NUMERIC(14, 3),
+         ALTER COLUMN quantity_after TYPE NUMERIC(14, 3);`,
+
+        `ALTER TABLE item_loan 
+         ALTER COLUMN quantity TYPE NUMERIC(14, 3);`,
+
+        `CREATE OR REPLACE FUNCTION container_fill(cid INTEGER) RETURNS NUMERIC AS $$
+            WITH contents AS (
+                SELECT i.weight_kg, i.width_cm * i.depth_cm * i.height_cm AS volume_cm3,
+                       CASE WHEN u.dimension = 'count' THEN COALESCE(i.quantity, 1) * u.factor ELSE 1 END AS pieces,
+                       CASE WHEN u.dimension = 'mass' THEN i.quantity * u.factor END AS mass_kg,
+                       CASE WHEN u.dimension = 'volume' THEN i.quantity * u.factor * 1000 END AS fluid_cm3
+                FROM item i
+                JOIN unit u ON i.unit = u.code
+                WHERE i.container_id = cid
+            )
+            SELECT ROUND(100 * CASE c.capacity_mode
+                WHEN 'count' THEN
+                    (SELECT COALESCE(SUM(pieces), 0) FROM contents)
+                    / NULLIF(c.max_items, 0)::NUMERIC
+                WHEN 'weight' THEN
+                    (SELECT COALESCE(SUM(COALESCE(weight_kg * pieces, mass_kg)), 0) FROM contents)
+                    / NULLIF(c.max_weight_kg, 0)
+                WHEN 'volume' THEN
+                    (
+                        (SELECT COALESCE(SUM(COALESCE(volume_cm3 * pieces, fluid_cm3)), 0) FROM contents)
+                        + (SELECT COALESCE(SUM(n.width_cm * n.depth_cm * n.height_cm), 0)
+                           FROM container n WHERE n.parent_container_id = c.id AND n.retired_at IS NULL)
+                    ) / 1000
+                    / NULLIF(COALESCE(c.max_volume_l, c.width_cm * c.depth_cm * c.height_cm / 1000), 0)
+            END, 1)
+            FROM container c
+            WHERE c.id = cid
+        $$ LANGUAGE sql STABLE;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute units migration query: %v", err)
+        }
+    }
+
+    return nil
+}
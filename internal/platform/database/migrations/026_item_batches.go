@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateItemBatches(tx *sql.Tx) error {
+    queries := []string{
+        `CREATE TABLE IF NOT EXISTS item_batch (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            quantity NUMERIC(14, 3) NOT NULL,
+            expires_on DATE NOT NULL,
+            date_kind VARCHAR(12) NOT NULL DEFAULT 'expiry',
+            lot VARCHAR(64) NOT NULL DEFAULT '',
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_item_batch_quantity CHECK (quantity > 0),
+            CONSTRAINT chk_item_batch_date_kind CHECK (date_kind IN ('expiry', 'best_before'))
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_batch_item ON item_batch(item_id, expires_on);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_batch_expires ON item_batch(expires_on);`,
+
+        `CREATE OR REPLACE FUNCTION item_batches(iid INTEGER) RETURNS JSONB AS $$
+            SELECT COALESCE(jsonb_agg(jsonb_strip_nulls(jsonb_build_object(
+                'id', b.id,
+                'itemId', b.item_id,
+                'quantity', b.quantity,
+                'expiresOn', to_char(b.expires_on, 'YYYY-MM-DD'),
+                'dateKind', b.date_kind,
+                'lot', NULLIF(b.lot, ''),
+                'expired', b.expires_on < CURRENT_DATE,
+                'createdAt', b.created_at
+            )) ORDER BY b.expires_on, b.id), '[]'::jsonb)
+            FROM item_batch b
+            WHERE b.item_id = iid
+        $$ LANGUAGE sql STABLE;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute item batch migration query: %v", err)
+        }
+    }
+
+    return nil
+}
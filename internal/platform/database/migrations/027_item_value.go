@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateItemValue(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE item 
+         ADD COLUMN IF NOT EXISTS purchase_price NUMERIC(14, 2),
+         ADD COLUMN IF NOT EXISTS currency CHAR(3),
+         ADD COLUMN IF NOT EXISTS purchase_date DATE,
+         ADD COLUMN IF NOT EXISTS vendor VARCHAR(100),
+         ADD COLUMN IF NOT EXISTS estimated_value NUMERIC(14, 2);`,
+
+        `CREATE TABLE IF NOT EXISTS item_receipt (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            blob_key TEXT,
+            url TEXT NOT NULL,
+            filename VARCHAR(255) NOT NULL DEFAULT '',
+            content_type VARCHAR(100) NOT NULL,
+            byte_size BIGINT,
+            storage_bytes BIGINT NOT NULL DEFAULT 0,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_receipt_item_id ON item_receipt(item_id);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_item_receipt_blob_key ON item_receipt(blob_key);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute item value migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func MigrateBlobOwner(tx *sql.Tx) error {
+    queries := []string{
+        // Stored bytes are charged once, to the blob, instead of to every
+        // reference sharing it
+        `ALTER TABLE blob 
+         ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;`,
+
+        // The earliest reference stands in for the uploader
+        `UPDATE blob b
+         SET user_id = ref.user_id
+         FROM (
+             SELECT DISTINCT ON (blob_key) blob_key, user_id
+             FROM (
+                 SELECT blob_key, user_id, 1 AS source, id FROM item_image
+                 UNION ALL
+                 SELECT blob_key, user_id, 2, id FROM container_image
+                 UNION ALL
+                 SELECT blob_key, user_id, 3, id FROM item_receipt
+                 UNION ALL
+                 SELECT image_blob_key, id, 4, id FROM users
+             ) refs
+             WHERE blob_key IS NOT NULL AND user_id IS NOT NULL
+             ORDER BY blob_key, source, id
+         ) ref
+         WHERE b.key = ref.blob_key AND b.user_id IS NULL;`,
+
+        `UPDATE item_image SET storage_bytes = 0 WHERE blob_key IS NOT NULL;`,
+        `UPDATE container_image SET storage_bytes = 0 WHERE blob_key IS NOT NULL;`,
+        `UPDATE item_receipt SET storage_bytes = 0 WHERE blob_key IS NOT NULL;`,
+        `UPDATE users SET image_bytes = 0 WHERE image_blob_key IS NOT NULL;`,
+
+        `UPDATE users u
+         SET storage_used_bytes = u.image_bytes
+             + COALESCE((SELECT SUM(storage_bytes) FROM item_image WHERE user_id = u.id), 0)
+             + COALESCE((SELECT SUM(storage_bytes) FROM container_image WHERE user_id = u.id), 0)
+             + COALESCE((SELECT SUM(storage_bytes) FROM item_receipt WHERE user_id = u.id), 0)
+             + COALESCE((SELECT SUM(storage_bytes) FROM blob WHERE user_id = u.id), 0);`,
+
+        `CREATE INDEX IF NOT EXISTS idx_blob_user_id 
+         ON blob(user_id);`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute blob owner migration query: %v", err)
+        }
+    }
+
+    return nil
+}
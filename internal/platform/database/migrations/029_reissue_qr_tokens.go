@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/chrisabs/storage/pkg/utils"
+)
+
+// MigrateReissueQRTokens replaces the enumerable STQRAGE-CONTAINER-<id>-<time>
+// codes, and any missing ones, with random tokens so labels printed before
+// opaque tokens stop resolving. Those containers need new labels.
+func MigrateReissueQRTokens(tx *sql.Tx) error {
+    rows, err := tx.Query(`
+        SELECT id FROM container
+        WHERE qr_code IS NULL OR qr_code LIKE 'STQRAGE-CONTAINER-%'
+        FOR UPDATE`)
+    if err != nil {
+        return fmt.Errorf("failed to find legacy QR codes: %v", err)
+    }
+
+    var ids []int
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err != nil {
+            rows.Close()
+            return fmt.Errorf("failed to scan legacy QR code: %v", err)
+        }
+        ids = append(ids, id)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("failed to read legacy QR codes: %v", err)
+    }
+
+    for _, id := range ids {
+        token, err := utils.NewQRToken()
+        if err != nil {
+            return err
+        }
+
+        if _, err := tx.Exec(`UPDATE container SET qr_code = $2 WHERE id = $1`, id, token); err != nil {
+            return fmt.Errorf("failed to reissue QR code: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// MigrateStockMovementLedger keeps stock movements when their item is
+// deleted; the ledger outlives the items it records.
+func MigrateStockMovementLedger(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE stock_movement 
+         ALTER COLUMN item_id DROP NOT NULL;`,
+
+        `ALTER TABLE stock_movement 
+         DROP CONSTRAINT IF EXISTS stock_movement_item_id_fkey;`,
+
+        `ALTER TABLE stock_movement 
+         ADD CONSTRAINT stock_movement_item_id_fkey 
+         FOREIGN KEY (item_id) REFERENCES item(id) ON DELETE SET NULL;`,
+
+        `CREATE OR REPLACE FUNCTION stock_movement_append_only() RETURNS TRIGGER AS $$
+         BEGIN
+             -- Deleting a user or an item nulls out user_id or item_id; nothing
+             -- else may change.
+             IF (NEW.user_id IS NULL OR NEW.user_id = OLD.user_id)
+                 AND (NEW.item_id IS NULL OR NEW.item_id = OLD.item_id)
+                 AND ROW(NEW.id, NEW.delta, NEW.reason, NEW.note, NEW.quantity_after, NEW.created_at)
+                 IS NOT DISTINCT FROM ROW(OLD.id, OLD.delta, OLD.reason, OLD.note, OLD.quantity_after, OLD.created_at) THEN
+                 RETURN NEW;
+             END IF;
+             RAISE EXCEPTION 'stock movements cannot be changed';
+         END;
+         $$ LANGUAGE plpgsql;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute stock movement ledger migration query: %v", err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// MigrateStockMovementUnit records the unit each stock movement was counted
+// in, so the ledger still reads right after an item changes unit. Existing
+// movements take their item's current unit.
+func MigrateStockMovementUnit(tx *sql.Tx) error {
+    queries := []string{
+        `ALTER TABLE stock_movement 
+         ADD COLUMN IF NOT EXISTS unit VARCHAR(10);`,
+
+        `ALTER TABLE stock_movement 
+         DISABLE TRIGGER trg_stock_movement_append_only;`,
+
+        `UPDATE stock_movement m
+         SET unit = i.unit
+         FROM item i
+         WHERE m.item_id = i.id AND m.unit IS NULL;`,
+
+        `ALTER TABLE stock_movement 
+         ENABLE TRIGGER trg_stock_movement_append_only;`,
+
+        `CREATE OR REPLACE FUNCTION stock_movement_append_only() RETURNS TRIGGER AS $$
+         BEGIN
+             -- Deleting a user or an item nulls out user_id or item_id; nothing
+             -- else may change.
+             IF (NEW.user_id IS NULL OR NEW.user_id = OLD.user_id)
+                 AND (NEW.item_id IS NULL OR NEW.item_id = OLD.item_id)
+                 AND ROW(NEW.id, NEW.delta, NEW.reason, NEW.note, NEW.quantity_after, NEW.unit, NEW.created_at)
+                 IS NOT DISTINCT FROM ROW(OLD.id, OLD.delta, OLD.reason, OLD.note, OLD.quantity_after, OLD.unit, OLD.created_at) THEN
+                 RETURN NEW;
+             END IF;
+             RAISE EXCEPTION 'stock movements cannot be changed';
+         END;
+         $$ LANGUAGE plpgsql;`,
+    }
+
+    for _, query := range queries {
+        if _, err := tx.Exec(query); err != nil {
+            return fmt.Errorf("failed to execute stock movement unit migration query: %v", err)
+        }
+    }
+
+    return nil
+}
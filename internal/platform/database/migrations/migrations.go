@@ -16,37 +16,171 @@ type Manager struct {
     migrations []Migration
 }
 
-func NewManager(db *sql.DB) *Manager {
-    return &Manager{
-        db: db,
-        migrations: []Migration{
-            {
-                ID:      "001_item_images",
-                Enabled: false,
-                Run:     MigrateItemImages,
-            },
-            {
-                ID:      "002_search_indexes",
-                Enabled: true,
-                Run:     MigrateSearchIndexes,
-            },
-            {
-                ID:      "003_workspace_relationships",
-                Enabled: false,
-                Run:     MigrateWorkspaceRelationships,
-            },
-            {
-                ID:      "004_container_description",
-                Enabled: true,  
-                Run:     MigrateContainerDescription,
-            },
-            {
-                ID:      "005_tag_description",
-                Enabled: true,
-                Run:     MigrateTagDescription,
+// NewManager lists the migrations in the order they run. containerPrefix is
+// the configured prefix for labelling containers that are numbered here.
+func NewManager(db *sql.DB, containerPrefix string) *Manager {
+    m := &Manager{db: db}
+    m.migrations = []Migration{
+        {
+            ID:      "001_item_images",
+            Enabled: false,
+            Run:     MigrateItemImages,
+        },
+        {
+            ID:      "002_search_indexes",
+            Enabled: true,
+            Run:     MigrateSearchIndexes,
+        },
+        {
+            ID:      "003_workspace_relationships",
+            Enabled: false,
+            Run:     MigrateWorkspaceRelationships,
+        },
+        {
+            ID:      "004_container_description",
+            Enabled: true,  
+            Run:     MigrateContainerDescription,
+        },
+        {
+            ID:      "005_tag_description",
+            Enabled: true,
+            Run:     MigrateTagDescription,
+        },
+        {
+            ID:      "006_item_image_variants",
+            Enabled: true,
+            Run:     MigrateItemImageVariants,
+        },
+        {
+            ID:      "007_blob_outbox",
+            Enabled: true,
+            Run:     MigrateBlobOutbox,
+        },
+        {
+            ID:      "008_storage_quotas",
+            Enabled: true,
+            Run:     MigrateStorageQuotas,
+        },
+        {
+            ID:      "009_content_addressed_blobs",
+            Enabled: true,
+            Run:     MigrateContentAddressedBlobs,
+        },
+        {
+            ID:      "010_image_object_keys",
+            Enabled: true,
+            Run:     MigrateImageObjectKeys,
+        },
+        {
+            ID:      "011_nested_containers",
+            Enabled: true,
+            Run:     MigrateNestedContainers,
+        },
+        {
+            ID:      "012_locations",
+            Enabled: true,
+            Run:     MigrateLocations,
+        },
+        {
+            ID:      "013_container_numbering",
+            Enabled: true,
+            Run: func(tx *sql.Tx) error {
+                return MigrateContainerNumbering(tx, containerPrefix)
             },
         },
+        {
+            ID:      "014_drop_qr_code_image",
+            Enabled: true,
+            Run:     MigrateDropQRCodeImage,
+        },
+        {
+            ID:      "015_container_symbology",
+            Enabled: true,
+            Run:     MigrateContainerSymbology,
+        },
+        {
+            ID:      "016_scan_codes",
+            Enabled: true,
+            Run:     MigrateScanCodes,
+        },
+        {
+            ID:      "017_container_shares",
+            Enabled: true,
+            Run:     MigrateContainerShares,
+        },
+        {
+            ID:      "018_container_merge_split",
+            Enabled: true,
+            Run:     MigrateContainerMergeSplit,
+        },
+        {
+            ID:      "019_container_capacity",
+            Enabled: true,
+            Run:     MigrateContainerCapacity,
+        },
+        {
+            ID:      "020_container_images",
+            Enabled: true,
+            Run:     MigrateContainerImages,
+        },
+        {
+            ID:      "021_checkouts",
+            Enabled: true,
+            Run:     MigrateCheckouts,
+        },
+        {
+            ID:      "022_item_loans",
+            Enabled: true,
+            Run:     MigrateItemLoans,
+        },
+        {
+            ID:      "023_stock_movements",
+            Enabled: true,
+            Run:     MigrateStockMovements,
+        },
+        {
+            ID:      "024_low_stock",
+            Enabled: true,
+            Run:     MigrateLowStock,
+        },
+        {
+            ID:      "025_units",
+            Enabled: true,
+            Run:     MigrateUnits,
+        },
+        {
+            ID:      "026_item_batches",
+            Enabled: true,
+            Run:     MigrateItemBatches,
+        },
+        {
+            ID:      "027_item_value",
+            Enabled: true,
+            Run:     MigrateItemValue,
+        },
+        {
+            ID:      "028_blob_owner",
+            Enabled: true,
+            Run:     MigrateBlobOwner,
+        },
+        {
+            ID:      "029_reissue_qr_tokens",
+            Enabled: true,
+            Run:     MigrateReissueQRTokens,
+        },
+        {
+            ID:      "030_stock_movement_ledger",
+            Enabled: true,
+            Run:     MigrateStockMovementLedger,
+        },
+        {
+            ID:      "031_stock_movement_unit",
+            Enabled: true,
+            Run:     MigrateStockMovementUnit,
+        },
     }
+
+    return m
 }
 
 func (m *Manager) EnableMigration(id string) {
@@ -58,6 +192,8 @@ func (m *Manager) EnableMigration(id string) {
     }
 }
 
+// Run applies the enabled migrations that have not been applied before,
+// all in one transaction, and records each one so it only ever runs once.
 func (m *Manager) Run() error {
     tx, err := m.db.Begin()
     if err != nil {
@@ -65,13 +201,82 @@ func (m *Manager) Run() error {
     }
     defer tx.Rollback()
 
+    applied, err := appliedMigrations(tx)
+    if err != nil {
+        return err
+    }
+
+    for _, migration := range m.migrations {
+        if !migration.Enabled || applied[migration.ID] {
+            continue
+        }
+        if err := migration.Run(tx); err != nil {
+            return fmt.Errorf("migration %s failed: %v", migration.ID, err)
+        }
+        if err := recordMigration(tx, migration.ID); err != nil {
+            return err
+        }
+    }
+
+    return tx.Commit()
+}
+
+// MarkApplied records every enabled migration as applied without running
+// it, for a database whose schema was just created in its newest form.
+func (m *Manager) MarkApplied() error {
+    tx, err := m.db.Begin()
+    if err != nil {
+        return fmt.Errorf("failed to start transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    if _, err := appliedMigrations(tx); err != nil {
+        return err
+    }
+
     for _, migration := range m.migrations {
         if migration.Enabled {
-            if err := migration.Run(tx); err != nil {
-                return fmt.Errorf("migration %s failed: %v", migration.ID, err)
+            if err := recordMigration(tx, migration.ID); err != nil {
+                return err
             }
         }
     }
 
     return tx.Commit()
-}
\ No newline at end of file
+}
+
+func appliedMigrations(tx *sql.Tx) (map[string]bool, error) {
+    _, err := tx.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migration (
+            id TEXT PRIMARY KEY,
+            applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create migration table: %v", err)
+    }
+
+    rows, err := tx.Query(`SELECT id FROM schema_migration`)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read applied migrations: %v", err)
+    }
+    defer rows.Close()
+
+    applied := make(map[string]bool)
+    for rows.Next() {
+        var id string
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("failed to read applied migrations: %v", err)
+        }
+        applied[id] = true
+    }
+
+    return applied, rows.Err()
+}
+
+func recordMigration(tx *sql.Tx, id string) error {
+    _, err := tx.Exec(`INSERT INTO schema_migration (id) VALUES ($1) ON CONFLICT (id) DO NOTHING`, id)
+    if err != nil {
+        return fmt.Errorf("failed to record migration %s: %v", id, err)
+    }
+    return nil
+}
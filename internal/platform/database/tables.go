@@ -11,6 +11,10 @@ func (db *PostgresDB) createUsersTable() error {
         first_name VARCHAR(100),
         last_name VARCHAR(100),
         image_url TEXT,
+        image_bytes BIGINT NOT NULL DEFAULT 0,
+        image_blob_key TEXT,
+        storage_used_bytes BIGINT NOT NULL DEFAULT 0,
+        storage_quota_bytes BIGINT,
         created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
         updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
     );
@@ -43,6 +47,63 @@ func (db *PostgresDB) createWorkspaceTable() error {
     return nil
 }
 
+func (db *PostgresDB) createLocationTable() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS location (
+            id SERIAL PRIMARY KEY,
+            workspace_id INTEGER NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+            parent_id INTEGER REFERENCES location(id) ON DELETE SET NULL,
+            name VARCHAR(100) NOT NULL,
+            kind VARCHAR(20) NOT NULL,
+            description TEXT,
+            code VARCHAR(100) UNIQUE,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_location_not_own_parent CHECK (parent_id <> id)
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_location_workspace ON location(workspace_id);
+        CREATE INDEX IF NOT EXISTS idx_location_parent ON location(parent_id);
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_location_sibling_name 
+            ON location(workspace_id, COALESCE(parent_id, 0), LOWER(name));
+
+        -- Breadcrumb of enclosing locations, outermost first, ending with start_id
+        CREATE OR REPLACE FUNCTION location_path(start_id INTEGER) RETURNS JSONB AS $$
+            WITH RECURSIVE ancestors AS (
+                SELECT id, name, parent_id, 0 AS depth
+                FROM location
+                WHERE id = start_id
+                UNION ALL
+                SELECT l.id, l.name, l.parent_id, a.depth + 1
+                FROM location l
+                JOIN ancestors a ON l.id = a.parent_id
+                WHERE a.depth < 32
+            )
+            SELECT COALESCE(
+                jsonb_agg(jsonb_build_object('id', id, 'name', name) ORDER BY depth DESC),
+                '[]'::jsonb
+            )
+            FROM ancestors
+        $$ LANGUAGE sql STABLE;
+
+        -- root_id and every location below it
+        CREATE OR REPLACE FUNCTION location_subtree(root_id INTEGER) RETURNS SETOF INTEGER AS $$
+            WITH RECURSIVE subtree AS (
+                SELECT id FROM location WHERE id = root_id
+                UNION
+                SELECT l.id FROM location l JOIN subtree s ON l.parent_id = s.id
+            )
+            SELECT id FROM subtree
+        $$ LANGUAGE sql STABLE;
+    `
+    _, err := db.Exec(query)
+    if err != nil {
+        return fmt.Errorf("error creating location table: %v", err)
+    }
+
+    return nil
+}
+
 func (db *PostgresDB) createContainerTable() error {
     query := `
         CREATE TABLE IF NOT EXISTS container (
@@ -50,16 +111,98 @@ func (db *PostgresDB) createContainerTable() error {
             name VARCHAR(50),
             description TEXT,
             qr_code VARCHAR(100) UNIQUE,           
-            qr_code_image TEXT,             
             number INTEGER,         
+            label VARCHAR(20),
+            symbology VARCHAR(20) NOT NULL DEFAULT 'qr',
             location VARCHAR(50),
             user_id INTEGER REFERENCES users(id) NOT NULL,
             workspace_id INTEGER REFERENCES workspace(id),
+            parent_container_id INTEGER REFERENCES container(id) ON DELETE SET NULL,
+            location_id INTEGER REFERENCES location(id) ON DELETE SET NULL,
+            retired_at TIMESTAMP WITH TIME ZONE,
+            merged_into_id INTEGER REFERENCES container(id) ON DELETE SET NULL,
+            capacity_mode VARCHAR(10),
+            width_cm NUMERIC(8, 2),
+            depth_cm NUMERIC(8, 2),
+            height_cm NUMERIC(8, 2),
+            max_weight_kg NUMERIC(10, 3),
+            max_volume_l NUMERIC(10, 2),
+            max_items INTEGER,
             created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_container_not_own_parent CHECK (parent_container_id <> id),
+            CONSTRAINT chk_container_symbology CHECK (symbology IN ('qr', 'datamatrix', 'code128')),
+            CONSTRAINT chk_container_capacity_mode CHECK (capacity_mode IN ('volume', 'weight', 'count'))
         );
 
         CREATE INDEX IF NOT EXISTS idx_container_qr_code ON container(qr_code);
+        CREATE INDEX IF NOT EXISTS idx_container_parent ON container(parent_container_id);
+        CREATE INDEX IF NOT EXISTS idx_container_location ON container(location_id);
+        -- Existing databases are renumbered by migration 013 before this runs
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_container_user_number ON container(user_id, number);
+
+        -- Merges and splits, one row per container involved
+        CREATE TABLE IF NOT EXISTS container_event (
+            id SERIAL PRIMARY KEY,
+            container_id INTEGER NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            action VARCHAR(20) NOT NULL,
+            related_container_id INTEGER REFERENCES container(id) ON DELETE SET NULL,
+            item_ids INTEGER[] NOT NULL DEFAULT '{}',
+            child_container_ids INTEGER[] NOT NULL DEFAULT '{}',
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_container_event_container ON container_event(container_id, created_at DESC);
+
+        -- Photos of the container itself. The cover is the one marked, or
+        -- else the first in display order.
+        CREATE TABLE IF NOT EXISTS container_image (
+            id SERIAL PRIMARY KEY,
+            container_id INTEGER NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            blob_key TEXT,
+            url TEXT NOT NULL,
+            width INTEGER,
+            height INTEGER,
+            byte_size BIGINT,
+            storage_bytes BIGINT NOT NULL DEFAULT 0,
+            variants JSONB NOT NULL DEFAULT '{}'::jsonb,
+            display_order INTEGER NOT NULL DEFAULT 0,
+            is_cover BOOLEAN NOT NULL DEFAULT FALSE,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_container_image_display_order ON container_image(container_id, display_order);
+        CREATE INDEX IF NOT EXISTS idx_container_image_user_id ON container_image(user_id);
+        CREATE INDEX IF NOT EXISTS idx_container_image_blob_key ON container_image(blob_key);
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_container_image_cover ON container_image(container_id) WHERE is_cover;
+
+        -- Last container number handed out to each user
+        CREATE TABLE IF NOT EXISTS container_counter (
+            user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+            last_number INTEGER NOT NULL DEFAULT 0
+        );
+
+        -- Breadcrumb of enclosing containers, outermost first, ending with start_id
+        CREATE OR REPLACE FUNCTION container_path(start_id INTEGER) RETURNS JSONB AS $$
+            WITH RECURSIVE ancestors AS (
+                SELECT id, name, parent_container_id, 0 AS depth
+                FROM container
+                WHERE id = start_id
+                UNION ALL
+                SELECT c.id, c.name, c.parent_container_id, a.depth + 1
+                FROM container c
+                JOIN ancestors a ON c.id = a.parent_container_id
+                WHERE a.depth < 32
+            )
+            SELECT COALESCE(
+                jsonb_agg(jsonb_build_object('id', id, 'name', name) ORDER BY depth DESC),
+                '[]'::jsonb
+            )
+            FROM ancestors
+        $$ LANGUAGE sql STABLE;
     `
     _, err := db.Exec(query)
     if err != nil {
@@ -79,11 +222,58 @@ func (db *PostgresDB) createItemTables() error {
             updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
         );
 
+        -- Units an item's quantity can be kept in. Factor converts to the
+        -- base unit of the dimension: metres, litres, kilograms or pieces.
+        CREATE TABLE IF NOT EXISTS unit (
+            code VARCHAR(10) PRIMARY KEY,
+            name VARCHAR(50) NOT NULL,
+            dimension VARCHAR(10) NOT NULL,
+            factor NUMERIC(20, 10) NOT NULL,
+            CONSTRAINT chk_unit_dimension CHECK (dimension IN ('count', 'length', 'volume', 'mass')),
+            CONSTRAINT chk_unit_factor CHECK (factor > 0)
+        );
+
+        INSERT INTO unit (code, name, dimension, factor) VALUES
+            ('each', 'each', 'count', 1),
+            ('pair', 'pair', 'count', 2),
+            ('dozen', 'dozen', 'count', 12),
+            ('mm', 'millimetre', 'length', 0.001),
+            ('cm', 'centimetre', 'length', 0.01),
+            ('m', 'metre', 'length', 1),
+            ('km', 'kilometre', 'length', 1000),
+            ('in', 'inch', 'length', 0.0254),
+            ('ft', 'foot', 'length', 0.3048),
+            ('yd', 'yard', 'length', 0.9144),
+            ('ml', 'millilitre', 'volume', 0.001),
+            ('l', 'litre', 'volume', 1),
+            ('floz', 'US fluid ounce', 'volume', 0.0295735296),
+            ('gal', 'US gallon', 'volume', 3.785411784),
+            ('mg', 'milligram', 'mass', 0.000001),
+            ('g', 'gram', 'mass', 0.001),
+            ('kg', 'kilogram', 'mass', 1),
+            ('oz', 'ounce', 'mass', 0.028349523125),
+            ('lb', 'pound', 'mass', 0.45359237)
+        ON CONFLICT (code) DO NOTHING;
+
         CREATE TABLE IF NOT EXISTS item (
             id SERIAL PRIMARY KEY,
             name VARCHAR(100),
             description TEXT,
-            quantity INTEGER,
+            quantity NUMERIC(14, 3),
+            unit VARCHAR(10) NOT NULL DEFAULT 'each' REFERENCES unit(code),
+            barcode VARCHAR(64),
+            weight_kg NUMERIC(10, 3),
+            width_cm NUMERIC(8, 2),
+            depth_cm NUMERIC(8, 2),
+            height_cm NUMERIC(8, 2),
+            min_quantity NUMERIC(14, 3),
+            reorder_quantity NUMERIC(14, 3),
+            preferred_store VARCHAR(100),
+            purchase_price NUMERIC(14, 2),
+            currency CHAR(3),
+            purchase_date DATE,
+            vendor VARCHAR(100),
+            estimated_value NUMERIC(14, 2),
             container_id INTEGER REFERENCES container(id) ON DELETE CASCADE NULL,
             created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
             updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
@@ -92,12 +282,33 @@ func (db *PostgresDB) createItemTables() error {
         CREATE TABLE IF NOT EXISTS item_image (
             id SERIAL PRIMARY KEY,
             item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            blob_key TEXT,
             url TEXT NOT NULL,
+            width INTEGER,
+            height INTEGER,
+            byte_size BIGINT,
+            storage_bytes BIGINT NOT NULL DEFAULT 0,
+            variants JSONB NOT NULL DEFAULT '{}'::jsonb,
             display_order INTEGER NOT NULL DEFAULT 0,
             created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
             updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
         );
 
+        -- Proof of purchase, kept as uploaded
+        CREATE TABLE IF NOT EXISTS item_receipt (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            blob_key TEXT,
+            url TEXT NOT NULL,
+            filename VARCHAR(255) NOT NULL DEFAULT '',
+            content_type VARCHAR(100) NOT NULL,
+            byte_size BIGINT,
+            storage_bytes BIGINT NOT NULL DEFAULT 0,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );
+
         CREATE TABLE IF NOT EXISTS item_tag (
             item_id INTEGER REFERENCES item(id) ON DELETE CASCADE,
             tag_id INTEGER REFERENCES tag(id) ON DELETE CASCADE,
@@ -105,11 +316,311 @@ func (db *PostgresDB) createItemTables() error {
         );
 
         CREATE INDEX IF NOT EXISTS idx_item_container ON item(container_id);
+        CREATE INDEX IF NOT EXISTS idx_item_barcode ON item(barcode);
+        CREATE INDEX IF NOT EXISTS idx_item_low_stock ON item(container_id) WHERE quantity < min_quantity;
         CREATE INDEX IF NOT EXISTS idx_item_tag_item ON item_tag(item_id);
         CREATE INDEX IF NOT EXISTS idx_item_tag_tag ON item_tag(tag_id);
         CREATE INDEX IF NOT EXISTS idx_item_image_item_id ON item_image(item_id);
         CREATE INDEX IF NOT EXISTS idx_item_image_display_order ON item_image(item_id, display_order);
+        CREATE INDEX IF NOT EXISTS idx_item_image_user_id ON item_image(user_id);
+        CREATE INDEX IF NOT EXISTS idx_item_image_blob_key ON item_image(blob_key);
+        CREATE INDEX IF NOT EXISTS idx_item_receipt_item_id ON item_receipt(item_id);
+        CREATE INDEX IF NOT EXISTS idx_item_receipt_blob_key ON item_receipt(blob_key);
+
+        -- Every change to an item's quantity. Rows are only ever added.
+        CREATE TABLE IF NOT EXISTS stock_movement (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER REFERENCES item(id) ON DELETE SET NULL,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            delta NUMERIC(14, 3) NOT NULL,
+            reason VARCHAR(20) NOT NULL,
+            note TEXT NOT NULL DEFAULT '',
+            quantity_after NUMERIC(14, 3) NOT NULL,
+            unit VARCHAR(10),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_stock_movement_delta CHECK (delta <> 0),
+            CONSTRAINT chk_stock_movement_reason CHECK (reason IN ('consumed', 'purchased', 'found', 'lost', 'correction'))
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_stock_movement_item ON stock_movement(item_id, created_at DESC);
+
+        CREATE OR REPLACE FUNCTION stock_movement_append_only() RETURNS TRIGGER AS $$
+        BEGIN
+            -- Deleting a user or an item nulls out user_id or item_id; nothing
+            -- else may change.
+            IF (NEW.user_id IS NULL OR NEW.user_id = OLD.user_id)
+                AND (NEW.item_id IS NULL OR NEW.item_id = OLD.item_id)
+                AND ROW(NEW.id, NEW.delta, NEW.reason, NEW.note, NEW.quantity_after, NEW.unit, NEW.created_at)
+                IS NOT DISTINCT FROM ROW(OLD.id, OLD.delta, OLD.reason, OLD.note, OLD.quantity_after, OLD.unit, OLD.created_at) THEN
+                RETURN NEW;
+            END IF;
+            RAISE EXCEPTION 'stock movements cannot be changed';
+        END;
+        $$ LANGUAGE plpgsql;
+
+        DROP TRIGGER IF EXISTS trg_stock_movement_append_only ON stock_movement;
+        CREATE TRIGGER trg_stock_movement_append_only
+            BEFORE UPDATE ON stock_movement
+            FOR EACH ROW EXECUTE FUNCTION stock_movement_append_only();
+
+        -- Part of an item's quantity that goes off on one date. Taking stock
+        -- away uses up the batch that expires first.
+        CREATE TABLE IF NOT EXISTS item_batch (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            quantity NUMERIC(14, 3) NOT NULL,
+            expires_on DATE NOT NULL,
+            date_kind VARCHAR(12) NOT NULL DEFAULT 'expiry',
+            lot VARCHAR(64) NOT NULL DEFAULT '',
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_item_batch_quantity CHECK (quantity > 0),
+            CONSTRAINT chk_item_batch_date_kind CHECK (date_kind IN ('expiry', 'best_before'))
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_item_batch_item ON item_batch(item_id, expires_on);
+        CREATE INDEX IF NOT EXISTS idx_item_batch_expires ON item_batch(expires_on);
+
+        -- An item's batches, the first to expire first
+        CREATE OR REPLACE FUNCTION item_batches(iid INTEGER) RETURNS JSONB AS $$
+            SELECT COALESCE(jsonb_agg(jsonb_strip_nulls(jsonb_build_object(
+                'id', b.id,
+                'itemId', b.item_id,
+                'quantity', b.quantity,
+                'expiresOn', to_char(b.expires_on, 'YYYY-MM-DD'),
+                'dateKind', b.date_kind,
+                'lot', NULLIF(b.lot, ''),
+                'expired', b.expires_on < CURRENT_DATE,
+                'createdAt', b.created_at
+            )) ORDER BY b.expires_on, b.id), '[]'::jsonb)
+            FROM item_batch b
+            WHERE b.item_id = iid
+        $$ LANGUAGE sql STABLE;
+
+        -- How full a container is as a percentage of its capacity, or NULL
+        -- when it has none. Items without a size or weight count as nothing.
+        -- By volume, nested containers take up their own outer volume. Sizes
+        -- and weights are per piece for counted items; an item kept by mass
+        -- or volume without one is measured by its quantity instead.
+        CREATE OR REPLACE FUNCTION container_fill(cid INTEGER) RETURNS NUMERIC AS $$
+            WITH contents AS (
+                SELECT i.weight_kg, i.width_cm * i.depth_cm * i.height_cm AS volume_cm3,
+                       CASE WHEN u.dimension = 'count' THEN COALESCE(i.quantity, 1) * u.factor ELSE 1 END AS pieces,
+                       CASE WHEN u.dimension = 'mass' THEN i.quantity * u.factor END AS mass_kg,
+                       CASE WHEN u.dimension = 'volume' THEN i.quantity * u.factor * 1000 END AS fluid_cm3
+                FROM item i
+                JOIN unit u ON i.unit = u.code
+                WHERE i.container_id = cid
+            )
+            SELECT ROUND(100 * CASE c.capacity_mode
+                WHEN 'count' THEN
+                    (SELECT COALESCE(SUM(pieces), 0) FROM contents)
+                    / NULLIF(c.max_items, 0)::NUMERIC
+                WHEN 'weight' THEN
+                    (SELECT COALESCE(SUM(COALESCE(weight_kg * pieces, mass_kg)), 0) FROM contents)
+                    / NULLIF(c.max_weight_kg, 0)
+                WHEN 'volume' THEN
+                    (
+                        (SELECT COALESCE(SUM(COALESCE(volume_cm3 * pieces, fluid_cm3)), 0) FROM contents)
+                        + (SELECT COALESCE(SUM(n.width_cm * n.depth_cm * n.height_cm), 0)
+                           FROM container n WHERE n.parent_container_id = c.id AND n.retired_at IS NULL)
+                    ) / 1000
+                    / NULLIF(COALESCE(c.max_volume_l, c.width_cm * c.depth_cm * c.height_cm / 1000), 0)
+            END, 1)
+            FROM container c
+            WHERE c.id = cid
+        $$ LANGUAGE sql STABLE;
     `
     _, err := db.Exec(query)
     return err
+}
+
+func (db *PostgresDB) createShareTables() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS container_share (
+            id SERIAL PRIMARY KEY,
+            container_id INTEGER NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            token VARCHAR(100) UNIQUE NOT NULL,
+            fields TEXT[] NOT NULL DEFAULT '{}',
+            expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+            revoked_at TIMESTAMP WITH TIME ZONE,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_container_share_container ON container_share(container_id);
+    `
+    _, err := db.Exec(query)
+    if err != nil {
+        return fmt.Errorf("error creating share tables: %v", err)
+    }
+
+    return nil
+}
+
+func (db *PostgresDB) createCheckoutTables() error {
+    query := `
+        -- A container or item lent out to someone, open until checked back in
+        CREATE TABLE IF NOT EXISTS checkout (
+            id SERIAL PRIMARY KEY,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            container_id INTEGER REFERENCES container(id) ON DELETE CASCADE,
+            item_id INTEGER REFERENCES item(id) ON DELETE CASCADE,
+            holder VARCHAR(100) NOT NULL,
+            notes TEXT NOT NULL DEFAULT '',
+            checked_out_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            expected_return_at TIMESTAMP WITH TIME ZONE,
+            checked_in_at TIMESTAMP WITH TIME ZONE,
+            return_notes TEXT NOT NULL DEFAULT '',
+            CONSTRAINT chk_checkout_entity CHECK (num_nonnulls(container_id, item_id) = 1)
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_checkout_container ON checkout(container_id, checked_out_at DESC);
+        CREATE INDEX IF NOT EXISTS idx_checkout_item ON checkout(item_id, checked_out_at DESC);
+        CREATE INDEX IF NOT EXISTS idx_checkout_user_open ON checkout(user_id, expected_return_at) WHERE checked_in_at IS NULL;
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_checkout_open_container ON checkout(container_id) WHERE checked_in_at IS NULL;
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_checkout_open_item ON checkout(item_id) WHERE checked_in_at IS NULL;
+
+        -- The open checkout of a container or, with cid NULL, an item
+        CREATE OR REPLACE FUNCTION open_checkout(cid INTEGER, iid INTEGER) RETURNS JSONB AS $$
+            SELECT jsonb_strip_nulls(jsonb_build_object(
+                'id', co.id,
+                'containerId', co.container_id,
+                'itemId', co.item_id,
+                'userId', co.user_id,
+                'holder', co.holder,
+                'notes', NULLIF(co.notes, ''),
+                'checkedOutAt', co.checked_out_at,
+                'expectedReturnAt', co.expected_return_at,
+                'overdue', COALESCE(co.expected_return_at < NOW(), FALSE)
+            ))
+            FROM checkout co
+            WHERE co.checked_in_at IS NULL AND (co.container_id = cid OR co.item_id = iid)
+            LIMIT 1
+        $$ LANGUAGE sql STABLE;
+    `
+    _, err := db.Exec(query)
+    if err != nil {
+        return fmt.Errorf("error creating checkout tables: %v", err)
+    }
+
+    return nil
+}
+
+func (db *PostgresDB) createLoanTables() error {
+    query := `
+        -- People items are lent to
+        CREATE TABLE IF NOT EXISTS contact (
+            id SERIAL PRIMARY KEY,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            name VARCHAR(100) NOT NULL,
+            email VARCHAR(255) NOT NULL DEFAULT '',
+            phone VARCHAR(50) NOT NULL DEFAULT '',
+            notes TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_contact_user ON contact(user_id, name);
+
+        -- Some quantity of an item lent to a contact, outstanding until returned
+        CREATE TABLE IF NOT EXISTS item_loan (
+            id SERIAL PRIMARY KEY,
+            item_id INTEGER NOT NULL REFERENCES item(id) ON DELETE CASCADE,
+            contact_id INTEGER NOT NULL REFERENCES contact(id) ON DELETE CASCADE,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            quantity NUMERIC(14, 3) NOT NULL,
+            notes TEXT NOT NULL DEFAULT '',
+            lent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+            due_at TIMESTAMP WITH TIME ZONE,
+            returned_at TIMESTAMP WITH TIME ZONE,
+            CONSTRAINT chk_item_loan_quantity CHECK (quantity > 0)
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_item_loan_item ON item_loan(item_id, lent_at DESC);
+        CREATE INDEX IF NOT EXISTS idx_item_loan_contact ON item_loan(contact_id, lent_at DESC);
+        CREATE INDEX IF NOT EXISTS idx_item_loan_outstanding ON item_loan(item_id) WHERE returned_at IS NULL;
+
+        -- An item's loans that have not been returned, oldest first
+        CREATE OR REPLACE FUNCTION outstanding_loans(iid INTEGER) RETURNS JSONB AS $$
+            SELECT COALESCE(jsonb_agg(jsonb_strip_nulls(jsonb_build_object(
+                'id', l.id,
+                'itemId', l.item_id,
+                'contactId', l.contact_id,
+                'contactName', ct.name,
+                'userId', l.user_id,
+                'quantity', l.quantity,
+                'notes', NULLIF(l.notes, ''),
+                'lentAt', l.lent_at,
+                'dueAt', l.due_at,
+                'overdue', COALESCE(l.due_at < NOW(), FALSE)
+            )) ORDER BY l.lent_at, l.id), '[]'::jsonb)
+            FROM item_loan l
+            JOIN contact ct ON l.contact_id = ct.id
+            WHERE l.item_id = iid AND l.returned_at IS NULL
+        $$ LANGUAGE sql STABLE;
+    `
+    _, err := db.Exec(query)
+    if err != nil {
+        return fmt.Errorf("error creating loan tables: %v", err)
+    }
+
+    return nil
+}
+
+func (db *PostgresDB) createScanTables() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS scan_history (
+            id SERIAL PRIMARY KEY,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            entity_type VARCHAR(20) NOT NULL,
+            entity_id INTEGER NOT NULL,
+            code VARCHAR(255) NOT NULL,
+            scanned_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+            CONSTRAINT chk_scan_entity_type CHECK (entity_type IN ('container', 'item', 'location'))
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_scan_history_user ON scan_history(user_id, scanned_at DESC);
+    `
+    _, err := db.Exec(query)
+    if err != nil {
+        return fmt.Errorf("error creating scan tables: %v", err)
+    }
+
+    return nil
+}
+
+func (db *PostgresDB) createBlobTables() error {
+    query := `
+        CREATE TABLE IF NOT EXISTS blob (
+            key TEXT PRIMARY KEY,
+            url TEXT NOT NULL,
+            width INTEGER NOT NULL DEFAULT 0,
+            height INTEGER NOT NULL DEFAULT 0,
+            byte_size BIGINT NOT NULL DEFAULT 0,
+            storage_bytes BIGINT NOT NULL DEFAULT 0,
+            variants JSONB NOT NULL DEFAULT '{}'::jsonb,
+            user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            ref_count INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_blob_user_id ON blob(user_id);
+
+        CREATE TABLE IF NOT EXISTS blob_outbox (
+            id SERIAL PRIMARY KEY,
+            object_key TEXT NOT NULL,
+            attempts INTEGER NOT NULL DEFAULT 0,
+            last_error TEXT,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+        );
+
+        CREATE INDEX IF NOT EXISTS idx_blob_outbox_pending ON blob_outbox(attempts, id);
+    `
+    _, err := db.Exec(query)
+    if err != nil {
+        return fmt.Errorf("error creating blob tables: %v", err)
+    }
+
+    return nil
 }
\ No newline at end of file
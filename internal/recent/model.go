@@ -3,9 +3,10 @@ package recent
 import "time"
 
 type EntityPreview struct {
-    ID        int       `json:"id"`
-    Name      string    `json:"name"`
-    CreatedAt time.Time `json:"created_at"`
+    ID          int       `json:"id"`
+    Name        string    `json:"name"`
+    CreatedAt   time.Time `json:"created_at"`
+    FillPercent *float64  `json:"fillPercent,omitempty"`
 }
 
 type EntityStats struct {
@@ -13,9 +14,27 @@ type EntityStats struct {
     Total  int            `json:"total"`
 }
 
+// ExpiredBatch is a batch of an item whose expiry or best-before date has
+// passed. ExpiresOn is YYYY-MM-DD.
+type ExpiredBatch struct {
+    ID        int     `json:"id"`
+    ItemID    int     `json:"itemId"`
+    ItemName  string  `json:"itemName"`
+    Quantity  float64 `json:"quantity"`
+    Unit      string  `json:"unit"`
+    ExpiresOn string  `json:"expiresOn"`
+    DateKind  string  `json:"dateKind"`
+}
+
+type ExpiredStats struct {
+    Recent []ExpiredBatch `json:"recent"`
+    Total  int            `json:"total"`
+}
+
 type Response struct {
-    Workspaces EntityStats `json:"workspaces"`
-    Containers EntityStats `json:"containers"`
-    Items      EntityStats `json:"items"`
-    Tags       EntityStats `json:"tags"`
+    Workspaces EntityStats  `json:"workspaces"`
+    Containers EntityStats  `json:"containers"`
+    Items      EntityStats  `json:"items"`
+    Tags       EntityStats  `json:"tags"`
+    Expired    ExpiredStats `json:"expired"`
 }
\ No newline at end of file
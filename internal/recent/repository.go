@@ -25,21 +25,22 @@ func (r *Repository) GetRecentEntities(userID int, limit int) (*Response, error)
         Containers: EntityStats{Recent: make([]EntityPreview, 0)},
         Items:      EntityStats{Recent: make([]EntityPreview, 0)},
         Tags:       EntityStats{Recent: make([]EntityPreview, 0)},
+        Expired:    ExpiredStats{Recent: make([]ExpiredBatch, 0)},
     }
 
     containerCountQuery := `
         SELECT COUNT(*) 
         FROM container 
-        WHERE user_id = $1
+        WHERE user_id = $1 AND retired_at IS NULL
     `
     if err := tx.QueryRow(containerCountQuery, userID).Scan(&response.Containers.Total); err != nil {
         return nil, fmt.Errorf("failed to get container count: %v", err)
     }
 
     containerQuery := `
-        SELECT id, name, created_at 
+        SELECT id, name, created_at, container_fill(id)
         FROM container 
-        WHERE user_id = $1 
+        WHERE user_id = $1 AND retired_at IS NULL
         ORDER BY created_at DESC 
         LIMIT $2
     `
@@ -51,7 +52,7 @@ func (r *Repository) GetRecentEntities(userID int, limit int) (*Response, error)
 
     for containerRows.Next() {
         var preview EntityPreview
-        if err := containerRows.Scan(&preview.ID, &preview.Name, &preview.CreatedAt); err != nil {
+        if err := containerRows.Scan(&preview.ID, &preview.Name, &preview.CreatedAt, &preview.FillPercent); err != nil {
             return nil, fmt.Errorf("failed to scan container row: %v", err)
         }
         response.Containers.Recent = append(response.Containers.Recent, preview)
@@ -154,6 +155,42 @@ func (r *Repository) GetRecentEntities(userID int, limit int) (*Response, error)
         response.Workspaces.Recent = append(response.Workspaces.Recent, preview)
     }
 
+    expiredCountQuery := `
+        SELECT COUNT(*)
+        FROM item_batch b
+        JOIN item i ON b.item_id = i.id
+        JOIN container c ON i.container_id = c.id
+        WHERE c.user_id = $1 AND b.expires_on < CURRENT_DATE`
+    if err := tx.QueryRow(expiredCountQuery, userID).Scan(&response.Expired.Total); err != nil {
+        return nil, fmt.Errorf("failed to get expired batch count: %v", err)
+    }
+
+    // Most recently expired first, as those are the ones still worth a look
+    expiredQuery := `
+        SELECT b.id, b.item_id, i.name, b.quantity, i.unit, to_char(b.expires_on, 'YYYY-MM-DD'), b.date_kind
+        FROM item_batch b
+        JOIN item i ON b.item_id = i.id
+        JOIN container c ON i.container_id = c.id
+        WHERE c.user_id = $1 AND b.expires_on < CURRENT_DATE
+        ORDER BY b.expires_on DESC, b.id
+        LIMIT $2`
+    expiredRows, err := tx.Query(expiredQuery, userID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch expired batches: %v", err)
+    }
+    defer expiredRows.Close()
+
+    for expiredRows.Next() {
+        var batch ExpiredBatch
+        if err := expiredRows.Scan(
+            &batch.ID, &batch.ItemID, &batch.ItemName, &batch.Quantity,
+            &batch.Unit, &batch.ExpiresOn, &batch.DateKind,
+        ); err != nil {
+            return nil, fmt.Errorf("failed to scan expired batch row: %v", err)
+        }
+        response.Expired.Recent = append(response.Expired.Recent, batch)
+    }
+
     if err := tx.Commit(); err != nil {
         return nil, fmt.Errorf("failed to commit transaction: %v", err)
     }
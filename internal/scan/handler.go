@@ -0,0 +1,90 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+    service        *Service
+    authMiddleware *middleware.AuthMiddleware
+}
+
+func NewHandler(service *Service, authMiddleware *middleware.AuthMiddleware) *Handler {
+    return &Handler{
+        service:        service,
+        authMiddleware: authMiddleware,
+    }
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+    // History first so it is not taken for a code. Codes may be whole deep
+    // links, slashes and all.
+    router.HandleFunc("/scan/history", h.authMiddleware.AuthHandler(h.handleGetHistory)).Methods("GET")
+    router.HandleFunc("/scan/{code:.+}", h.authMiddleware.AuthHandler(h.handleScan)).Methods("GET")
+}
+
+func (h *Handler) handleScan(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    result, err := h.service.Resolve(userID, mux.Vars(r)["code"])
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    limit := 0
+    if value := r.URL.Query().Get("limit"); value != "" {
+        limit, err = strconv.Atoi(value)
+        if err != nil {
+            writeError(w, http.StatusBadRequest, "invalid limit")
+            return
+        }
+    }
+
+    history, err := h.service.GetHistory(userID, limit)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, history)
+}
+
+func errorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, ErrInvalidCode):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
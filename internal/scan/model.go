@@ -0,0 +1,50 @@
+package scan
+
+import (
+	"errors"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+const (
+    EntityContainer = "container"
+    EntityItem      = "item"
+    EntityLocation  = "location"
+)
+
+const (
+    maxCodeLength       = 255
+    maxItemMatches      = 20
+    defaultHistoryLimit = 20
+    maxHistoryLimit     = 100
+    // historyRetention is how many scans are kept per user; older ones are
+    // dropped as new scans come in
+    historyRetention = 500
+)
+
+var (
+    ErrInvalidCode = errors.New("code must be between 1 and 255 characters")
+    ErrNotFound    = errors.New("no container, item or location matches this code")
+)
+
+// Result is what a code resolved to. Exactly one of Container, Location or
+// Items is set, matching Type. A product barcode can match several items, so
+// every match is returned, most recently updated first.
+type Result struct {
+    Type      string            `json:"type"`
+    Code      string            `json:"code"`
+    Container *models.Container `json:"container,omitempty"`
+    Location  *models.Location  `json:"location,omitempty"`
+    Items     []*models.Item    `json:"items,omitempty"`
+}
+
+// HistoryEntry is the latest scan of one entity.
+type HistoryEntry struct {
+    Type      string    `json:"type"`
+    ID        int       `json:"id"`
+    Name      string    `json:"name"`
+    Label     string    `json:"label,omitempty"`
+    Code      string    `json:"code"`
+    ScannedAt time.Time `json:"scannedAt"`
+}
@@ -0,0 +1,158 @@
+package scan
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+// FindContainer matches the opaque label token first and falls back to the
+// printed label, e.g. BOX-0042, for codes typed in by hand.
+func (r *Repository) FindContainer(userID int, token, label string) (int, bool, error) {
+    query := `
+        SELECT id
+        FROM container
+        WHERE user_id = $1 AND (qr_code = $2 OR UPPER(label) = UPPER($3))
+        ORDER BY (qr_code = $2) DESC
+        LIMIT 1`
+
+    return r.findID(query, userID, token, label)
+}
+
+func (r *Repository) FindLocation(userID int, token string) (int, bool, error) {
+    query := `
+        SELECT l.id
+        FROM location l
+        JOIN workspace w ON l.workspace_id = w.id
+        WHERE w.user_id = $1 AND l.code = $2`
+
+    return r.findID(query, userID, token)
+}
+
+// FindItems returns the items carrying barcode. Items only belong to someone
+// through their container, so unassigned items are never matched.
+func (r *Repository) FindItems(userID int, barcode string, limit int) ([]int, error) {
+    query := `
+        SELECT i.id
+        FROM item i
+        JOIN container c ON i.container_id = c.id
+        WHERE c.user_id = $1 AND i.barcode = $2
+        ORDER BY i.updated_at DESC, i.id DESC
+        LIMIT $3`
+
+    rows, err := r.db.Query(query, userID, barcode, limit)
+    if err != nil {
+        return nil, fmt.Errorf("error finding items by barcode: %v", err)
+    }
+    defer rows.Close()
+
+    var ids []int
+    for rows.Next() {
+        var id int
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("error scanning item: %v", err)
+        }
+        ids = append(ids, id)
+    }
+
+    return ids, rows.Err()
+}
+
+func (r *Repository) findID(query string, args ...interface{}) (int, bool, error) {
+    var id int
+    err := r.db.QueryRow(query, args...).Scan(&id)
+    if err == sql.ErrNoRows {
+        return 0, false, nil
+    }
+    if err != nil {
+        return 0, false, fmt.Errorf("error resolving code: %v", err)
+    }
+
+    return id, true, nil
+}
+
+func (r *Repository) Record(userID int, entityType string, entityID int, code string) error {
+    tx, err := r.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    _, err = tx.Exec(`
+        INSERT INTO scan_history (user_id, entity_type, entity_id, code, scanned_at)
+        VALUES ($1, $2, $3, $4, $5)`,
+        userID, entityType, entityID, code, time.Now().UTC(),
+    )
+    if err != nil {
+        return fmt.Errorf("error recording scan: %v", err)
+    }
+
+    _, err = tx.Exec(`
+        DELETE FROM scan_history
+        WHERE user_id = $1 AND id NOT IN (
+            SELECT id FROM scan_history
+            WHERE user_id = $1
+            ORDER BY scanned_at DESC, id DESC
+            LIMIT $2
+        )`,
+        userID, historyRetention,
+    )
+    if err != nil {
+        return fmt.Errorf("error trimming scan history: %v", err)
+    }
+
+    return tx.Commit()
+}
+
+// GetHistory lists the most recently scanned entities, each once, skipping
+// anything deleted or no longer the user's since it was scanned.
+func (r *Repository) GetHistory(userID, limit int) ([]HistoryEntry, error) {
+    query := `
+        SELECT h.entity_type, h.entity_id,
+               COALESCE(CASE h.entity_type
+                   WHEN 'container' THEN c.name
+                   WHEN 'item' THEN i.name
+                   ELSE l.name
+               END, ''),
+               COALESCE(c.label, ''), h.code, h.scanned_at
+        FROM (
+            SELECT DISTINCT ON (entity_type, entity_id) entity_type, entity_id, code, scanned_at
+            FROM scan_history
+            WHERE user_id = $1
+            ORDER BY entity_type, entity_id, scanned_at DESC
+        ) h
+        LEFT JOIN container c ON h.entity_type = 'container' AND c.id = h.entity_id AND c.user_id = $1
+        LEFT JOIN (item i JOIN container ic ON ic.id = i.container_id AND ic.user_id = $1)
+            ON h.entity_type = 'item' AND i.id = h.entity_id
+        LEFT JOIN (location l JOIN workspace lw ON lw.id = l.workspace_id AND lw.user_id = $1)
+            ON h.entity_type = 'location' AND l.id = h.entity_id
+        WHERE c.id IS NOT NULL OR i.id IS NOT NULL OR l.id IS NOT NULL
+        ORDER BY h.scanned_at DESC
+        LIMIT $2`
+
+    rows, err := r.db.Query(query, userID, limit)
+    if err != nil {
+        return nil, fmt.Errorf("error getting scan history: %v", err)
+    }
+    defer rows.Close()
+
+    history := make([]HistoryEntry, 0)
+    for rows.Next() {
+        var entry HistoryEntry
+        err := rows.Scan(&entry.Type, &entry.ID, &entry.Name, &entry.Label, &entry.Code, &entry.ScannedAt)
+        if err != nil {
+            return nil, fmt.Errorf("error scanning history entry: %v", err)
+        }
+        history = append(history, entry)
+    }
+
+    return history, rows.Err()
+}
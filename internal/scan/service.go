@@ -0,0 +1,120 @@
+package scan
+
+import (
+	"strings"
+
+	"github.com/chrisabs/storage/internal/container"
+	"github.com/chrisabs/storage/internal/item"
+	"github.com/chrisabs/storage/internal/location"
+	"github.com/chrisabs/storage/pkg/utils"
+)
+
+type Service struct {
+    repo             *Repository
+    containerService *container.Service
+    itemService      *item.Service
+    locationService  *location.Service
+}
+
+func NewService(repo *Repository, containerService *container.Service, itemService *item.Service, locationService *location.Service) *Service {
+    return &Service{
+        repo:             repo,
+        containerService: containerService,
+        itemService:      itemService,
+        locationService:  locationService,
+    }
+}
+
+// Resolve looks code up as a container label, then a location label and
+// finally an item barcode, only ever matching things userID owns. Resolved
+// scans are added to the user's history.
+func (s *Service) Resolve(userID int, code string) (*Result, error) {
+    code = strings.TrimSpace(code)
+    if code == "" || len(code) > maxCodeLength {
+        return nil, ErrInvalidCode
+    }
+
+    result, err := s.resolve(userID, code)
+    if err != nil {
+        return nil, err
+    }
+
+    entityID := 0
+    switch {
+    case result.Container != nil:
+        entityID = result.Container.ID
+    case result.Location != nil:
+        entityID = result.Location.ID
+    default:
+        entityID = result.Items[0].ID
+    }
+
+    if err := s.repo.Record(userID, result.Type, entityID, code); err != nil {
+        return nil, err
+    }
+
+    return result, nil
+}
+
+func (s *Service) resolve(userID int, code string) (*Result, error) {
+    token := utils.QRToken(code)
+
+    id, ok, err := s.repo.FindContainer(userID, token, code)
+    if err != nil {
+        return nil, err
+    }
+    if ok {
+        c, err := s.containerService.GetContainerByID(id)
+        if err != nil {
+            return nil, err
+        }
+        return &Result{Type: EntityContainer, Code: code, Container: c}, nil
+    }
+
+    id, ok, err = s.repo.FindLocation(userID, token)
+    if err != nil {
+        return nil, err
+    }
+    if ok {
+        l, err := s.locationService.GetLocationByID(id)
+        if err != nil {
+            return nil, err
+        }
+        return &Result{Type: EntityLocation, Code: code, Location: l}, nil
+    }
+
+    barcode := utils.NormalizeBarcode(code)
+    if len(barcode) > utils.MaxBarcodeLength {
+        return nil, ErrNotFound
+    }
+
+    ids, err := s.repo.FindItems(userID, barcode, maxItemMatches)
+    if err != nil {
+        return nil, err
+    }
+    if len(ids) == 0 {
+        return nil, ErrNotFound
+    }
+
+    result := &Result{Type: EntityItem, Code: code}
+    for _, id := range ids {
+        i, err := s.itemService.GetItemByID(id)
+        if err != nil {
+            return nil, err
+        }
+        result.Items = append(result.Items, i)
+    }
+
+    return result, nil
+}
+
+func (s *Service) GetHistory(userID, limit int) ([]HistoryEntry, error) {
+    if limit <= 0 {
+        limit = defaultHistoryLimit
+    }
+    if limit > maxHistoryLimit {
+        limit = maxHistoryLimit
+    }
+
+    return s.repo.GetHistory(userID, limit)
+}
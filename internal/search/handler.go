@@ -2,6 +2,9 @@ package search
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 
@@ -45,7 +48,13 @@ func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    results, err := h.service.Search(query, userID)
+    locationID, err := locationFilter(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    results, err := h.service.Search(query, userID, locationID)
     if err != nil {
         writeError(w, http.StatusInternalServerError, err.Error())
         return
@@ -89,7 +98,13 @@ func (h *Handler) handleContainerSearch(w http.ResponseWriter, r *http.Request)
         return
     }
 
-    results, err := h.service.SearchContainers(query, userID)
+    locationID, err := locationFilter(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    results, err := h.service.SearchContainers(query, userID, locationID)
     if err != nil {
         writeError(w, http.StatusInternalServerError, err.Error())
         return
@@ -111,9 +126,25 @@ func (h *Handler) handleItemSearch(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    results, err := h.service.SearchItems(query, userID)
+    locationID, err := locationFilter(r)
     if err != nil {
-        writeError(w, http.StatusInternalServerError, err.Error())
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    quantity, err := quantityFilter(r)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    results, err := h.service.SearchItems(query, userID, locationID, quantity)
+    if err != nil {
+        status := http.StatusInternalServerError
+        if errors.Is(err, ErrUnknownUnit) {
+            status = http.StatusBadRequest
+        }
+        writeError(w, status, err.Error())
         return
     }
 
@@ -164,6 +195,44 @@ func (h *Handler) handleContainerQRSearch(w http.ResponseWriter, r *http.Request
     writeJSON(w, http.StatusOK, container)
 }
 
+// locationFilter reads the optional locationId parameter that narrows a
+// search to containers placed anywhere beneath that location.
+func locationFilter(r *http.Request) (*int, error) {
+    raw := r.URL.Query().Get("locationId")
+    if raw == "" {
+        return nil, nil
+    }
+
+    id, err := strconv.Atoi(raw)
+    if err != nil {
+        return nil, fmt.Errorf("invalid location ID")
+    }
+    return &id, nil
+}
+
+// quantityFilter reads the optional unit and minQuantity parameters. A
+// minimum on its own is taken to be in pieces.
+func quantityFilter(r *http.Request) (*QuantityFilter, error) {
+    unit := r.URL.Query().Get("unit")
+    raw := r.URL.Query().Get("minQuantity")
+    if unit == "" && raw == "" {
+        return nil, nil
+    }
+
+    filter := &QuantityFilter{Unit: unit}
+    if filter.Unit == "" {
+        filter.Unit = "each"
+    }
+    if raw != "" {
+        min, err := strconv.ParseFloat(raw, 64)
+        if err != nil || math.IsNaN(min) || math.IsInf(min, 0) {
+            return nil, fmt.Errorf("invalid minQuantity")
+        }
+        filter.Min = &min
+    }
+    return filter, nil
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(status)
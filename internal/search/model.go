@@ -1,18 +1,33 @@
 package search
 
 import (
+	"errors"
+
 	"github.com/chrisabs/storage/internal/models"
 )
 
+var ErrUnknownUnit = errors.New("unknown unit")
+
+// QuantityFilter narrows an item search to things measured like Unit, and
+// when Min is set to those holding at least that much of it. Quantities in
+// other units of the same dimension are converted before comparing.
+type QuantityFilter struct {
+    Min  *float64
+    Unit string
+}
+
 type SearchResult struct {
-    Type          string  `json:"type"`
-    ID            int     `json:"id"`
-    Name          string  `json:"name"`
-    Description   string  `json:"description"`
-    Rank          float64 `json:"rank"`
-    ContainerName *string `json:"containerName,omitempty"`
-    WorkspaceName *string `json:"workspaceName,omitempty"`
-    Colour        *string `json:"colour,omitempty"` 
+    Type          string              `json:"type"`
+    ID            int                 `json:"id"`
+    Name          string              `json:"name"`
+    Description   string              `json:"description"`
+    Rank          float64             `json:"rank"`
+    ContainerName *string             `json:"containerName,omitempty"`
+    WorkspaceName *string             `json:"workspaceName,omitempty"`
+    Colour        *string             `json:"colour,omitempty"`
+    Path          []models.Breadcrumb `json:"path,omitempty"`
+    CoverImage    *models.ItemImage   `json:"coverImage,omitempty"`
+    CheckedOut    *models.Checkout    `json:"checkedOut,omitempty"`
 }
 
 type SearchResponse struct {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/pkg/utils"
 )
 
 type Repository struct {
@@ -16,7 +17,7 @@ func NewRepository(db *sql.DB) *Repository {
     return &Repository{db: db}
 }
 
-func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
+func (r *Repository) Search(query string, userID int, locationID *int) (*SearchResponse, error) {
     sqlQuery := `
     WITH workspace_matches AS (
         SELECT 
@@ -33,7 +34,10 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
             END as rank,
             NULL as container_name,
             name as workspace_name,
-            NULL as colour
+            NULL as colour,
+            NULL::jsonb as path,
+            NULL::jsonb as cover_image,
+            NULL::jsonb as checked_out
         FROM workspace 
         WHERE 
             user_id = $2 AND
@@ -58,13 +62,35 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
             END as rank,
             NULL as container_name,
             w.name as workspace_name,
-            NULL as colour
+            NULL as colour,
+            container_path(c.parent_container_id) as path,
+            (
+                SELECT jsonb_build_object(
+                    'id', ci.id,
+                    'url', ci.url,
+                    'width', ci.width,
+                    'height', ci.height,
+                    'byteSize', ci.byte_size,
+                    'variants', ci.variants,
+                    'displayOrder', ci.display_order,
+                    'createdAt', ci.created_at,
+                    'updatedAt', ci.updated_at
+                )
+                FROM container_image ci
+                WHERE ci.container_id = c.id
+                ORDER BY ci.is_cover DESC, ci.display_order, ci.id
+                LIMIT 1
+            ) as cover_image,
+            open_checkout(c.id, NULL) as checked_out
         FROM container c
         LEFT JOIN workspace w ON c.workspace_id = w.id
         WHERE 
             c.user_id = $2 AND
+            c.retired_at IS NULL AND
+            ($3::int IS NULL OR c.location_id IN (SELECT location_subtree($3::int))) AND
             (
                 c.name ILIKE $1 OR
+                c.label ILIKE $1 OR
                 to_tsvector('english', c.name) @@ websearch_to_tsquery('english', $1)
             )
     ),
@@ -83,11 +109,15 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
             END as rank,
             c.name as container_name,
             NULL as workspace_name,
-            NULL as colour
+            NULL as colour,
+            container_path(i.container_id) as path,
+            NULL::jsonb as cover_image,
+            open_checkout(NULL, i.id) as checked_out
         FROM item i
         LEFT JOIN container c ON i.container_id = c.id
         WHERE 
             c.user_id = $2 AND
+            ($3::int IS NULL OR c.location_id IN (SELECT location_subtree($3::int))) AND
             (
                 i.name ILIKE $1 OR
                 i.description ILIKE '%' || $1 || '%' OR
@@ -110,7 +140,10 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
             END as rank,
             NULL as container_name,
             NULL as workspace_name,
-            t.colour
+            t.colour,
+            NULL::jsonb as path,
+            NULL::jsonb as cover_image,
+            NULL::jsonb as checked_out
         FROM tag t
         WHERE t.name ILIKE $1 OR t.name ILIKE $1 || '%' OR t.name ILIKE '%' || $1 || '%'
     ),
@@ -129,7 +162,10 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
             END as rank,
             c.name as container_name,
             w.name as workspace_name,
-            NULL as colour
+            NULL as colour,
+            container_path(i.container_id) as path,
+            NULL::jsonb as cover_image,
+            open_checkout(NULL, i.id) as checked_out
         FROM item i
         INNER JOIN item_tag it ON i.id = it.item_id
         INNER JOIN tag t ON it.tag_id = t.id
@@ -137,6 +173,7 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
         LEFT JOIN workspace w ON c.workspace_id = w.id
         WHERE 
             (c.user_id = $2 OR i.container_id IS NULL) AND
+            ($3::int IS NULL OR c.location_id IN (SELECT location_subtree($3::int))) AND
             (
                 t.name ILIKE $1 OR
                 t.name ILIKE $1 || '%' OR
@@ -145,7 +182,7 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
             ) AND
             i.id NOT IN (SELECT id FROM item_matches)
     )
-    SELECT type, id, name, description, rank, container_name, workspace_name, colour 
+    SELECT type, id, name, description, rank, container_name, workspace_name, colour, path, cover_image, checked_out
     FROM (
         SELECT * FROM workspace_matches
         UNION ALL
@@ -159,7 +196,7 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
     ) combined_results
     ORDER BY rank DESC;`
 
-    rows, err := r.db.Query(sqlQuery, query, userID)
+    rows, err := r.db.Query(sqlQuery, query, userID, locationID)
     if err != nil {
         return nil, fmt.Errorf("error executing search: %v", err)
     }
@@ -176,6 +213,7 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
     for rows.Next() {
         var result SearchResult
         var containerName, workspaceName, colour sql.NullString
+        var pathJSON, coverJSON, checkoutJSON []byte
         err := rows.Scan(
             &result.Type,
             &result.ID,
@@ -185,6 +223,9 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
             &containerName,
             &workspaceName,
             &colour,
+            &pathJSON,
+            &coverJSON,
+            &checkoutJSON,
         )
         if err != nil {
             return nil, fmt.Errorf("error scanning search result: %v", err)
@@ -199,6 +240,21 @@ func (r *Repository) Search(query string, userID int) (*SearchResponse, error) {
         if colour.Valid {
             result.Colour = &colour.String
         }
+        if pathJSON != nil {
+            if err := json.Unmarshal(pathJSON, &result.Path); err != nil {
+                return nil, fmt.Errorf("error parsing search result path: %v", err)
+            }
+        }
+        if coverJSON != nil {
+            if err := json.Unmarshal(coverJSON, &result.CoverImage); err != nil {
+                return nil, fmt.Errorf("error parsing search result cover image: %v", err)
+            }
+        }
+        if checkoutJSON != nil {
+            if err := json.Unmarshal(checkoutJSON, &result.CheckedOut); err != nil {
+                return nil, fmt.Errorf("error parsing search result checkout: %v", err)
+            }
+        }
 
         switch result.Type {
         case "workspace":
@@ -267,11 +323,31 @@ func (r *Repository) SearchWorkspaces(query string, userID int) (WorkspaceSearch
     return results, nil
 }
 
-func (r *Repository) SearchContainers(query string, userID int) (ContainerSearchResults, error) {
+func (r *Repository) SearchContainers(query string, userID int, locationID *int) (ContainerSearchResults, error) {
     sqlQuery := `
         SELECT 
-            c.id, c.name, c.qr_code, c.qr_code_image, c.number, c.location,
+            c.id, c.name, c.qr_code, c.number, COALESCE(c.label, ''), c.location,
             c.user_id, c.workspace_id, c.created_at, c.updated_at,
+            c.parent_container_id, container_path(c.parent_container_id) as path,
+            c.location_id, location_path(c.location_id) as location_path,
+            (
+                SELECT jsonb_build_object(
+                    'id', ci.id,
+                    'url', ci.url,
+                    'width', ci.width,
+                    'height', ci.height,
+                    'byteSize', ci.byte_size,
+                    'variants', ci.variants,
+                    'displayOrder', ci.display_order,
+                    'createdAt', ci.created_at,
+                    'updatedAt', ci.updated_at
+                )
+                FROM container_image ci
+                WHERE ci.container_id = c.id
+                ORDER BY ci.is_cover DESC, ci.display_order, ci.id
+                LIMIT 1
+            ) as cover_image,
+            open_checkout(c.id, NULL) as checked_out,
             CASE
                 WHEN c.name ILIKE $1 THEN 1.0
                 WHEN c.name ILIKE $1 || '%' THEN 0.8
@@ -282,15 +358,18 @@ func (r *Repository) SearchContainers(query string, userID int) (ContainerSearch
         FROM container c
         WHERE 
             c.user_id = $2 AND
+            c.retired_at IS NULL AND
+            ($3::int IS NULL OR c.location_id IN (SELECT location_subtree($3::int))) AND
             (
                 c.name ILIKE $1 OR
+                c.label ILIKE $1 OR
                 c.location ILIKE '%' || $1 || '%' OR
                 to_tsvector('english', c.name || ' ' || COALESCE(c.location, '')) @@ 
                 websearch_to_tsquery('english', $1)
             )
         ORDER BY rank DESC;`
 
-    rows, err := r.db.Query(sqlQuery, query, userID)
+    rows, err := r.db.Query(sqlQuery, query, userID, locationID)
     if err != nil {
         return nil, fmt.Errorf("error executing container search: %v", err)
     }
@@ -299,37 +378,85 @@ func (r *Repository) SearchContainers(query string, userID int) (ContainerSearch
     var results ContainerSearchResults
     for rows.Next() {
         var result ContainerSearchResult
+        var pathJSON, locationPathJSON, coverJSON, checkoutJSON []byte
         err := rows.Scan(
             &result.ID,
             &result.Name,
             &result.QRCode,
-            &result.QRCodeImage,
             &result.Number,
+            &result.Label,
             &result.Location,
             &result.UserID,
             &result.WorkspaceID,
             &result.CreatedAt,
             &result.UpdatedAt,
+            &result.ParentContainerID,
+            &pathJSON,
+            &result.LocationID,
+            &locationPathJSON,
+            &coverJSON,
+            &checkoutJSON,
             &result.Rank,
         )
         if err != nil {
             return nil, fmt.Errorf("error scanning container search result: %v", err)
         }
 
+        if err := json.Unmarshal(pathJSON, &result.Path); err != nil {
+            return nil, fmt.Errorf("error unmarshaling path: %v", err)
+        }
+        if err := json.Unmarshal(locationPathJSON, &result.LocationPath); err != nil {
+            return nil, fmt.Errorf("error unmarshaling location path: %v", err)
+        }
+        if coverJSON != nil {
+            if err := json.Unmarshal(coverJSON, &result.CoverImage); err != nil {
+                return nil, fmt.Errorf("error unmarshaling cover image: %v", err)
+            }
+        }
+        if checkoutJSON != nil {
+            if err := json.Unmarshal(checkoutJSON, &result.CheckedOut); err != nil {
+                return nil, fmt.Errorf("error unmarshaling checkout: %v", err)
+            }
+        }
+
         results = append(results, result)
     }
 
     return results, nil
 }
 
-func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, error) {
+// UnitExists reports whether code is in the unit catalogue.
+func (r *Repository) UnitExists(code string) (bool, error) {
+    var exists bool
+    err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM unit WHERE code = $1)`, code).Scan(&exists)
+    if err != nil {
+        return false, fmt.Errorf("error checking unit: %v", err)
+    }
+    return exists, nil
+}
+
+func (r *Repository) SearchItems(query string, userID int, locationID *int, quantity *QuantityFilter) (ItemSearchResults, error) {
+    var minQuantity *float64
+    var unitCode *string
+    if quantity != nil {
+        minQuantity = quantity.Min
+        unitCode = &quantity.Unit
+    }
+
     quickCheckQuery := `
         SELECT EXISTS (
             SELECT 1
             FROM item i
+            JOIN unit u ON i.unit = u.code
             LEFT JOIN container c ON i.container_id = c.id
             WHERE 
                 (c.user_id = $2 OR i.container_id IS NULL) AND
+                ($3::int IS NULL OR c.location_id IN (SELECT location_subtree($3::int))) AND
+                ($5::text IS NULL OR EXISTS (
+                    SELECT 1 FROM unit q
+                    WHERE q.code = $5 AND q.dimension = u.dimension
+                      AND ($4::numeric IS NULL OR i.quantity * u.factor >= $4::numeric * q.factor)
+                )) AND
                 (
                     LOWER(i.name) = LOWER($1) OR
                     i.name ~* ('\m' || $1 || '\M') OR
@@ -343,7 +470,7 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
         );`
 
     var hasResults bool
-    err := r.db.QueryRow(quickCheckQuery, query, userID).Scan(&hasResults)
+    err := r.db.QueryRow(quickCheckQuery, query, userID, locationID, minQuantity, unitCode).Scan(&hasResults)
     if err != nil {
         return nil, fmt.Errorf("error checking for results: %v", err)
     }
@@ -359,6 +486,7 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
                 i.name,
                 i.description,
                 i.quantity,
+                i.unit,
                 i.container_id,
                 i.created_at,
                 i.updated_at,
@@ -385,9 +513,16 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
                     END
                 ) as rank
             FROM item i
+            JOIN unit u ON i.unit = u.code
             LEFT JOIN container c ON i.container_id = c.id
             WHERE 
                 (c.user_id = $2 OR i.container_id IS NULL) AND
+                ($3::int IS NULL OR c.location_id IN (SELECT location_subtree($3::int))) AND
+                ($5::text IS NULL OR EXISTS (
+                    SELECT 1 FROM unit q
+                    WHERE q.code = $5 AND q.dimension = u.dimension
+                      AND ($4::numeric IS NULL OR i.quantity * u.factor >= $4::numeric * q.factor)
+                )) AND
                 (
                     LOWER(i.name) = LOWER($1) OR
                     i.name ~* ('\m' || $1 || '\M') OR
@@ -408,6 +543,10 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
                     jsonb_build_object(
                         'id', img.id,
                         'url', img.url,
+                        'width', img.width,
+                        'height', img.height,
+                        'byteSize', img.byte_size,
+                        'variants', img.variants,
                         'display_order', img.display_order
                     ) ORDER BY img.display_order
                 ) as images
@@ -420,6 +559,7 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
             i.name,
             i.description,
             i.quantity,
+            i.unit,
             i.container_id,
             i.created_at,
             i.updated_at,
@@ -428,7 +568,8 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
                 'id', c.id,
                 'name', c.name,
                 'location', c.location,
-                'workspace_id', c.workspace_id
+                'workspaceId', c.workspace_id,
+                'parentContainerId', c.parent_container_id
             ) as container,
             COALESCE(
                 jsonb_agg(
@@ -440,21 +581,23 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
                 ) FILTER (WHERE t.id IS NOT NULL),
                 '[]'
             ) as tags,
-            COALESCE(ii.images, '[]'::jsonb) as images
+            COALESCE(ii.images, '[]'::jsonb) as images,
+            container_path(i.container_id) as path,
+            open_checkout(NULL, i.id) as checked_out
         FROM ranked_items i
         LEFT JOIN container c ON i.container_id = c.id
         LEFT JOIN item_tag it ON i.id = it.item_id
         LEFT JOIN tag t ON it.tag_id = t.id
         LEFT JOIN item_images ii ON i.id = ii.item_id
         GROUP BY 
-            i.id, i.name, i.description, i.quantity, i.container_id, 
+            i.id, i.name, i.description, i.quantity, i.unit, i.container_id, 
             i.created_at, i.updated_at, i.rank,
-            c.id, c.name, c.location, c.workspace_id,
+            c.id, c.name, c.location, c.workspace_id, c.parent_container_id,
             ii.images
         ORDER BY i.rank DESC
         LIMIT 50;`
 
-    rows, err := r.db.Query(sqlQuery, query, userID)
+    rows, err := r.db.Query(sqlQuery, query, userID, locationID, minQuantity, unitCode)
     if err != nil {
         return nil, fmt.Errorf("error executing item search: %v", err)
     }
@@ -463,13 +606,14 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
     var results ItemSearchResults
     for rows.Next() {
         var result ItemSearchResult
-        var containerJSON, tagsJSON, imagesJSON []byte
+        var containerJSON, tagsJSON, imagesJSON, pathJSON, checkoutJSON []byte
 
         err := rows.Scan(
             &result.ID,
             &result.Name,
             &result.Description,
             &result.Quantity,
+            &result.Unit,
             &result.ContainerID,
             &result.CreatedAt,
             &result.UpdatedAt,
@@ -477,6 +621,8 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
             &containerJSON,
             &tagsJSON,
             &imagesJSON,
+            &pathJSON,
+            &checkoutJSON,
         )
         if err != nil {
             return nil, fmt.Errorf("error scanning item search result: %v", err)
@@ -496,6 +642,16 @@ func (r *Repository) SearchItems(query string, userID int) (ItemSearchResults, e
             return nil, fmt.Errorf("error unmarshaling images: %v", err)
         }
 
+        if err := json.Unmarshal(pathJSON, &result.Path); err != nil {
+            return nil, fmt.Errorf("error unmarshaling path: %v", err)
+        }
+
+        if checkoutJSON != nil {
+            if err := json.Unmarshal(checkoutJSON, &result.CheckedOut); err != nil {
+                return nil, fmt.Errorf("error unmarshaling checkout: %v", err)
+            }
+        }
+
         results = append(results, result)
     }
 
@@ -582,6 +738,7 @@ func (r *Repository) SearchTags(query string, userID int) (TagSearchResults, err
                                 'id', i.id,
                                 'name', i.name,
                                 'quantity', i.quantity,
+                                'unit', i.unit,
                                 'container_id', i.container_id
                             )
                         ELSE NULL 
@@ -634,7 +791,9 @@ func (r *Repository) SearchTags(query string, userID int) (TagSearchResults, err
 func (r *Repository) FindContainerByQR(qrCode string, userID int) (*models.Container, error) {
    query := `
        SELECT 
-           c.*,
+           c.id, c.name, c.description, c.qr_code, c.number, COALESCE(c.label, ''), c.location,
+           c.user_id, c.workspace_id, c.parent_container_id, c.created_at, c.updated_at,
+           container_path(c.parent_container_id) as path,
            jsonb_build_object(
                'id', w.id,
                'name', w.name,
@@ -649,19 +808,22 @@ func (r *Repository) FindContainerByQR(qrCode string, userID int) (*models.Conta
        LIMIT 1`
 
    container := new(models.Container)
-   var workspaceJSON []byte
+   var workspaceJSON, pathJSON []byte
    
-   err := r.db.QueryRow(query, qrCode, userID).Scan(
+   err := r.db.QueryRow(query, utils.QRToken(qrCode), userID).Scan(
        &container.ID,
        &container.Name,
+       &container.Description,
        &container.QRCode,
-       &container.QRCodeImage,
        &container.Number,
+       &container.Label,
        &container.Location,
        &container.UserID,
        &container.WorkspaceID,
+       &container.ParentContainerID,
        &container.CreatedAt,
        &container.UpdatedAt,
+       &pathJSON,
        &workspaceJSON,
    )
 
@@ -672,6 +834,10 @@ func (r *Repository) FindContainerByQR(qrCode string, userID int) (*models.Conta
        return nil, fmt.Errorf("error finding container: %v", err)
    }
 
+   if err := json.Unmarshal(pathJSON, &container.Path); err != nil {
+       return nil, fmt.Errorf("error unmarshaling path: %v", err)
+   }
+
    if len(workspaceJSON) > 0 {
        if err := json.Unmarshal(workspaceJSON, &container.Workspace); err != nil {
            return nil, fmt.Errorf("error unmarshaling workspace: %v", err)
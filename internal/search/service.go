@@ -4,28 +4,37 @@ import (
 	"fmt"
 
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
 )
 
 type Service struct {
-    repo *Repository
+    repo   *Repository
+    signer *storage.URLSigner
 }
 
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, signer *storage.URLSigner) *Service {
     return &Service{
-        repo: repo,
+        repo:   repo,
+        signer: signer,
     }
 }
 
-func (s *Service) Search(query string, userID int) (*SearchResponse, error) {
+func (s *Service) Search(query string, userID int, locationID *int) (*SearchResponse, error) {
     if query == "" {
         return nil, fmt.Errorf("search query cannot be empty")
     }
 
-    results, err := s.repo.Search(query, userID)
+    results, err := s.repo.Search(query, userID, locationID)
     if err != nil {
         return nil, fmt.Errorf("failed to execute search: %v", err)
     }
 
+    for i := range results.Containers {
+        if cover := results.Containers[i].CoverImage; cover != nil {
+            s.signer.SignImage(cover)
+        }
+    }
+
     return results, nil
 }
 
@@ -42,29 +51,45 @@ func (s *Service) SearchWorkspaces(query string, userID int) (WorkspaceSearchRes
     return results, nil
 }
 
-func (s *Service) SearchContainers(query string, userID int) (ContainerSearchResults, error) {
+func (s *Service) SearchContainers(query string, userID int, locationID *int) (ContainerSearchResults, error) {
     if query == "" {
         return nil, fmt.Errorf("search query cannot be empty")
     }
 
-    results, err := s.repo.SearchContainers(query, userID)
+    results, err := s.repo.SearchContainers(query, userID, locationID)
     if err != nil {
         return nil, fmt.Errorf("failed to execute container search: %v", err)
     }
 
+    for i := range results {
+        s.signer.SignContainer(&results[i].Container)
+    }
     return results, nil
 }
 
-func (s *Service) SearchItems(query string, userID int) (ItemSearchResults, error) {
+func (s *Service) SearchItems(query string, userID int, locationID *int, quantity *QuantityFilter) (ItemSearchResults, error) {
     if query == "" {
         return nil, fmt.Errorf("search query cannot be empty")
     }
 
-    results, err := s.repo.SearchItems(query, userID)
+    if quantity != nil {
+        exists, err := s.repo.UnitExists(quantity.Unit)
+        if err != nil {
+            return nil, err
+        }
+        if !exists {
+            return nil, fmt.Errorf("%w: %s", ErrUnknownUnit, quantity.Unit)
+        }
+    }
+
+    results, err := s.repo.SearchItems(query, userID, locationID, quantity)
     if err != nil {
         return nil, fmt.Errorf("failed to execute item search: %v", err)
     }
 
+    for i := range results {
+        s.signer.SignItem(&results[i].Item)
+    }
     return results, nil
 }
 
@@ -78,6 +103,9 @@ func (s *Service) SearchTags(query string, userID int) (TagSearchResults, error)
         return nil, fmt.Errorf("failed to execute tag search: %v", err)
     }
 
+    for i := range results {
+        s.signer.SignTag(&results[i].Tag)
+    }
     return results, nil
 }
 
@@ -91,5 +119,6 @@ func (s *Service) FindContainerByQR(qrCode string, userID int) (*models.Containe
         return nil, fmt.Errorf("failed to find container: %v", err)
     }
 
+    s.signer.SignContainer(container)
     return container, nil
 }
\ No newline at end of file
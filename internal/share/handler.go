@@ -0,0 +1,185 @@
+package share
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/chrisabs/storage/internal/container"
+	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+    service          *Service
+    containerService *container.Service
+    authMiddleware   *middleware.AuthMiddleware
+}
+
+func NewHandler(service *Service, containerService *container.Service, authMiddleware *middleware.AuthMiddleware) *Handler {
+    return &Handler{
+        service:          service,
+        containerService: containerService,
+        authMiddleware:   authMiddleware,
+    }
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+    router.HandleFunc("/containers/{id}/shares", h.authMiddleware.AuthHandler(h.handleCreateShare)).Methods("POST")
+    router.HandleFunc("/containers/{id}/shares", h.authMiddleware.AuthHandler(h.handleGetShares)).Methods("GET")
+    router.HandleFunc("/containers/{id}/shares/{shareId}", h.authMiddleware.AuthHandler(h.handleUpdateShare)).Methods("PUT")
+    router.HandleFunc("/containers/{id}/shares/{shareId}", h.authMiddleware.AuthHandler(h.handleRevokeShare)).Methods("DELETE")
+
+    // Deliberately unauthenticated: the token is the credential
+    router.HandleFunc("/shared/{token}", h.handleGetShared).Methods("GET")
+}
+
+func (h *Handler) handleCreateShare(w http.ResponseWriter, r *http.Request) {
+    userID, containerID, ok := h.authorizeContainer(w, r)
+    if !ok {
+        return
+    }
+
+    var req CreateShareRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body")
+        return
+    }
+
+    share, err := h.service.CreateShare(userID, containerID, &req)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusCreated, share)
+}
+
+func (h *Handler) handleGetShares(w http.ResponseWriter, r *http.Request) {
+    _, containerID, ok := h.authorizeContainer(w, r)
+    if !ok {
+        return
+    }
+
+    shares, err := h.service.GetShares(containerID)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, shares)
+}
+
+func (h *Handler) handleUpdateShare(w http.ResponseWriter, r *http.Request) {
+    _, containerID, ok := h.authorizeContainer(w, r)
+    if !ok {
+        return
+    }
+
+    shareID, err := strconv.Atoi(mux.Vars(r)["shareId"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid share ID")
+        return
+    }
+
+    var req UpdateShareRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid request body")
+        return
+    }
+
+    share, err := h.service.UpdateShare(containerID, shareID, &req)
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, share)
+}
+
+func (h *Handler) handleRevokeShare(w http.ResponseWriter, r *http.Request) {
+    _, containerID, ok := h.authorizeContainer(w, r)
+    if !ok {
+        return
+    }
+
+    shareID, err := strconv.Atoi(mux.Vars(r)["shareId"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid share ID")
+        return
+    }
+
+    if err := h.service.RevokeShare(containerID, shareID); err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, map[string]int{"revoked": shareID})
+}
+
+func (h *Handler) handleGetShared(w http.ResponseWriter, r *http.Request) {
+    // Anyone can call this, so errors say no more than that the link does
+    // not open
+    view, err := h.service.GetSharedContainer(mux.Vars(r)["token"])
+    if errors.Is(err, ErrShareNotFound) {
+        writeError(w, http.StatusNotFound, ErrShareNotFound.Error())
+        return
+    }
+    if err != nil {
+        log.Printf("Error loading share link: %v", err)
+        writeError(w, http.StatusInternalServerError, "failed to load share link")
+        return
+    }
+
+    // Links can be revoked at any time, so nothing may be kept around
+    w.Header().Set("Cache-Control", "no-store")
+    writeJSON(w, http.StatusOK, view)
+}
+
+// authorizeContainer checks the caller owns the container in the URL and
+// writes the error response when they do not.
+func (h *Handler) authorizeContainer(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return 0, 0, false
+    }
+
+    containerID, err := strconv.Atoi(mux.Vars(r)["id"])
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid container ID")
+        return 0, 0, false
+    }
+
+    c, err := h.containerService.GetContainerByID(containerID)
+    if err != nil {
+        writeError(w, http.StatusNotFound, "container not found")
+        return 0, 0, false
+    }
+
+    if c.UserID != userID {
+        writeError(w, http.StatusForbidden, "access denied")
+        return 0, 0, false
+    }
+
+    return userID, containerID, true
+}
+
+func errorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrShareNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, ErrInvalidField), errors.Is(err, ErrInvalidExpiry):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
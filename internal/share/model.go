@@ -0,0 +1,90 @@
+package share
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+// Fields a share link can expose besides the container's name, which is
+// always shown. Quantities, photos and tags only apply when items are shared.
+const (
+    FieldDescription = "description"
+    FieldLabel       = "label"
+    FieldLocation    = "location"
+    FieldItems       = "items"
+    FieldQuantities  = "quantities"
+    FieldPhotos      = "photos"
+    FieldTags        = "tags"
+)
+
+var Fields = []string{FieldDescription, FieldLabel, FieldLocation, FieldItems, FieldQuantities, FieldPhotos, FieldTags}
+
+// DefaultFields is what a link shows when the owner does not choose.
+var DefaultFields = []string{FieldItems, FieldPhotos}
+
+const (
+    defaultExpiry = 7 * 24 * time.Hour
+    maxExpiry     = 365 * 24 * time.Hour
+)
+
+var (
+    ErrShareNotFound = errors.New("share link not found")
+    ErrInvalidField  = errors.New("unknown share field")
+    ErrInvalidExpiry = fmt.Errorf("expiresInHours must be between 1 and %d", int(maxExpiry.Hours()))
+)
+
+// Share is an owner-issued link to a read-only view of one container.
+type Share struct {
+    ID          int        `json:"id"`
+    ContainerID int        `json:"containerId"`
+    UserID      int        `json:"userId"`
+    Token       string     `json:"token"`
+    URL         string     `json:"url,omitempty"`
+    Fields      []string   `json:"fields"`
+    ExpiresAt   time.Time  `json:"expiresAt"`
+    RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+    CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Active reports whether the link still opens.
+func (s *Share) Active(now time.Time) bool {
+    return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type CreateShareRequest struct {
+    Fields         []string `json:"fields"`
+    ExpiresInHours int      `json:"expiresInHours"`
+}
+
+type UpdateShareRequest struct {
+    Fields []string `json:"fields"`
+}
+
+// SharedContainer is the public view behind a link. Only the fields the link
+// was created with are filled in.
+type SharedContainer struct {
+    Name        string       `json:"name"`
+    Description string       `json:"description,omitempty"`
+    Label       string       `json:"label,omitempty"`
+    Location    string       `json:"location,omitempty"`
+    Items       []SharedItem `json:"items,omitempty"`
+    ExpiresAt   time.Time    `json:"expiresAt"`
+}
+
+type SharedItem struct {
+    Name     string        `json:"name"`
+    Quantity *float64      `json:"quantity,omitempty"`
+    Unit     string        `json:"unit,omitempty"`
+    Photos   []SharedPhoto `json:"photos,omitempty"`
+    Tags     []string      `json:"tags,omitempty"`
+}
+
+type SharedPhoto struct {
+    URL      string                         `json:"url"`
+    Width    int                            `json:"width"`
+    Height   int                            `json:"height"`
+    Variants map[string]models.ImageVariant `json:"variants,omitempty"`
+}
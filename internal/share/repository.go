@@ -0,0 +1,155 @@
+package share
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+const shareColumns = `id, container_id, user_id, token, fields, expires_at, revoked_at, created_at`
+
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanShare(row rowScanner) (*Share, error) {
+    share := new(Share)
+    err := row.Scan(
+        &share.ID, &share.ContainerID, &share.UserID, &share.Token,
+        pq.Array(&share.Fields), &share.ExpiresAt, &share.RevokedAt, &share.CreatedAt,
+    )
+    if err != nil {
+        return nil, err
+    }
+    if share.Fields == nil {
+        share.Fields = []string{}
+    }
+    return share, nil
+}
+
+func (r *Repository) Create(share *Share) error {
+    query := `
+        INSERT INTO container_share (container_id, user_id, token, fields, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id`
+
+    err := r.db.QueryRow(
+        query,
+        share.ContainerID,
+        share.UserID,
+        share.Token,
+        pq.Array(share.Fields),
+        share.ExpiresAt,
+        share.CreatedAt,
+    ).Scan(&share.ID)
+
+    if err != nil {
+        return fmt.Errorf("error creating share link: %v", err)
+    }
+
+    return nil
+}
+
+func (r *Repository) GetByID(id int) (*Share, error) {
+    query := `SELECT ` + shareColumns + ` FROM container_share WHERE id = $1`
+
+    share, err := scanShare(r.db.QueryRow(query, id))
+    if err == sql.ErrNoRows {
+        return nil, ErrShareNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error getting share link: %v", err)
+    }
+
+    return share, nil
+}
+
+// GetActiveByToken only returns links that have neither expired nor been
+// revoked, so the public view cannot tell those apart from unknown tokens.
+func (r *Repository) GetActiveByToken(token string) (*Share, error) {
+    query := `
+        SELECT ` + shareColumns + `
+        FROM container_share
+        WHERE token = $1 AND revoked_at IS NULL AND expires_at > $2`
+
+    share, err := scanShare(r.db.QueryRow(query, token, time.Now().UTC()))
+    if err == sql.ErrNoRows {
+        return nil, ErrShareNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error getting share link: %v", err)
+    }
+
+    return share, nil
+}
+
+func (r *Repository) GetByContainer(containerID int) ([]*Share, error) {
+    query := `
+        SELECT ` + shareColumns + `
+        FROM container_share
+        WHERE container_id = $1
+        ORDER BY created_at DESC, id DESC`
+
+    rows, err := r.db.Query(query, containerID)
+    if err != nil {
+        return nil, fmt.Errorf("error getting share links: %v", err)
+    }
+    defer rows.Close()
+
+    shares := make([]*Share, 0)
+    for rows.Next() {
+        share, err := scanShare(rows)
+        if err != nil {
+            return nil, fmt.Errorf("error scanning share link: %v", err)
+        }
+        shares = append(shares, share)
+    }
+
+    return shares, rows.Err()
+}
+
+func (r *Repository) UpdateFields(id int, fields []string) error {
+    result, err := r.db.Exec(
+        `UPDATE container_share SET fields = $2 WHERE id = $1`,
+        id, pq.Array(fields),
+    )
+    if err != nil {
+        return fmt.Errorf("error updating share link: %v", err)
+    }
+
+    return checkAffected(result)
+}
+
+// Revoke is idempotent and keeps the time of the first revocation.
+func (r *Repository) Revoke(id int) error {
+    result, err := r.db.Exec(
+        `UPDATE container_share SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`,
+        id, time.Now().UTC(),
+    )
+    if err != nil {
+        return fmt.Errorf("error revoking share link: %v", err)
+    }
+
+    return checkAffected(result)
+}
+
+func checkAffected(result sql.Result) error {
+    rowsAffected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking update result: %v", err)
+    }
+    if rowsAffected == 0 {
+        return ErrShareNotFound
+    }
+    return nil
+}
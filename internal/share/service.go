@@ -0,0 +1,234 @@
+package share
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chrisabs/storage/internal/container"
+	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/pkg/utils"
+)
+
+type Service struct {
+    repo             *Repository
+    containerService *container.Service
+    baseURL          string
+}
+
+func NewService(repo *Repository, containerService *container.Service, baseURL string) *Service {
+    return &Service{repo: repo, containerService: containerService, baseURL: baseURL}
+}
+
+func (s *Service) CreateShare(userID, containerID int, req *CreateShareRequest) (*Share, error) {
+    fields, err := normalizeFields(req.Fields)
+    if err != nil {
+        return nil, err
+    }
+
+    expiry := defaultExpiry
+    if req.ExpiresInHours != 0 {
+        // Check the hours before converting; large values overflow a Duration
+        if req.ExpiresInHours < 1 || req.ExpiresInHours > int(maxExpiry.Hours()) {
+            return nil, ErrInvalidExpiry
+        }
+        expiry = time.Duration(req.ExpiresInHours) * time.Hour
+    }
+
+    token, err := utils.NewQRToken()
+    if err != nil {
+        return nil, err
+    }
+
+    now := time.Now().UTC()
+    share := &Share{
+        ContainerID: containerID,
+        UserID:      userID,
+        Token:       token,
+        Fields:      fields,
+        ExpiresAt:   now.Add(expiry),
+        CreatedAt:   now,
+    }
+
+    if err := s.repo.Create(share); err != nil {
+        return nil, err
+    }
+
+    return s.withURL(share), nil
+}
+
+func (s *Service) GetShares(containerID int) ([]*Share, error) {
+    shares, err := s.repo.GetByContainer(containerID)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, share := range shares {
+        s.withURL(share)
+    }
+    return shares, nil
+}
+
+func (s *Service) UpdateShare(containerID, shareID int, req *UpdateShareRequest) (*Share, error) {
+    if _, err := s.getContainerShare(containerID, shareID); err != nil {
+        return nil, err
+    }
+
+    fields, err := normalizeFields(req.Fields)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := s.repo.UpdateFields(shareID, fields); err != nil {
+        return nil, err
+    }
+
+    share, err := s.repo.GetByID(shareID)
+    if err != nil {
+        return nil, err
+    }
+    return s.withURL(share), nil
+}
+
+func (s *Service) RevokeShare(containerID, shareID int) error {
+    if _, err := s.getContainerShare(containerID, shareID); err != nil {
+        return err
+    }
+
+    return s.repo.Revoke(shareID)
+}
+
+// getContainerShare loads a share and checks it belongs to the container in
+// the URL, whose ownership the handler has already checked.
+func (s *Service) getContainerShare(containerID, shareID int) (*Share, error) {
+    share, err := s.repo.GetByID(shareID)
+    if err != nil {
+        return nil, err
+    }
+    if share.ContainerID != containerID {
+        return nil, ErrShareNotFound
+    }
+    return share, nil
+}
+
+// GetSharedContainer builds the public view for an active link.
+func (s *Service) GetSharedContainer(token string) (*SharedContainer, error) {
+    share, err := s.repo.GetActiveByToken(token)
+    if err != nil {
+        return nil, err
+    }
+
+    c, err := s.containerService.GetContainerByID(share.ContainerID)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrShareNotFound, err)
+    }
+
+    return project(c, share), nil
+}
+
+func (s *Service) withURL(share *Share) *Share {
+    if s.baseURL != "" {
+        share.URL = utils.QRLink(s.baseURL, share.Token)
+    }
+    return share
+}
+
+// project copies only the fields the link allows onto the public view.
+func project(c *models.Container, share *Share) *SharedContainer {
+    show := make(map[string]bool, len(share.Fields))
+    for _, field := range share.Fields {
+        show[field] = true
+    }
+
+    view := &SharedContainer{Name: c.Name, ExpiresAt: share.ExpiresAt}
+    if show[FieldDescription] {
+        view.Description = c.Description
+    }
+    if show[FieldLabel] {
+        view.Label = c.Label
+    }
+    if show[FieldLocation] {
+        view.Location = locationText(c)
+    }
+
+    if !show[FieldItems] {
+        return view
+    }
+
+    view.Items = make([]SharedItem, 0, len(c.Items))
+    for _, item := range c.Items {
+        shared := SharedItem{Name: item.Name}
+        if show[FieldQuantities] {
+            quantity := item.Quantity
+            shared.Quantity = &quantity
+            shared.Unit = item.Unit
+        }
+        if show[FieldPhotos] {
+            for _, image := range item.Images {
+                shared.Photos = append(shared.Photos, SharedPhoto{
+                    URL:      image.URL,
+                    Width:    image.Width,
+                    Height:   image.Height,
+                    Variants: image.Variants,
+                })
+            }
+        }
+        if show[FieldTags] {
+            for _, tag := range item.Tags {
+                shared.Tags = append(shared.Tags, tag.Name)
+            }
+        }
+        view.Items = append(view.Items, shared)
+    }
+
+    return view
+}
+
+// locationText prefers the location breadcrumb and falls back to the free
+// text location containers had before locations were structured.
+func locationText(c *models.Container) string {
+    if len(c.LocationPath) == 0 {
+        return c.Location
+    }
+
+    names := make([]string, len(c.LocationPath))
+    for i, crumb := range c.LocationPath {
+        names[i] = crumb.Name
+    }
+    return strings.Join(names, " / ")
+}
+
+// normalizeFields validates the requested fields and returns them once each
+// in a fixed order. Leaving them out picks the defaults; an empty list shares
+// just the name.
+func normalizeFields(requested []string) ([]string, error) {
+    if requested == nil {
+        requested = DefaultFields
+    }
+
+    wanted := make(map[string]bool, len(requested))
+    for _, field := range requested {
+        field = strings.ToLower(strings.TrimSpace(field))
+        if !validField(field) {
+            return nil, fmt.Errorf("%w: %s", ErrInvalidField, field)
+        }
+        wanted[field] = true
+    }
+
+    fields := make([]string, 0, len(wanted))
+    for _, field := range Fields {
+        if wanted[field] {
+            fields = append(fields, field)
+        }
+    }
+    return fields, nil
+}
+
+func validField(field string) bool {
+    for _, f := range Fields {
+        if f == field {
+            return true
+        }
+    }
+    return false
+}
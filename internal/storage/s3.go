@@ -1,21 +1,30 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"mime/multipart"
-	"path/filepath"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	appconfig "github.com/chrisabs/storage/internal/config"
+	"github.com/chrisabs/storage/internal/imaging"
+	"github.com/chrisabs/storage/internal/models"
 )
 
 type S3Handler struct {
-    client *s3.Client
-    bucket string
-    region string
+    client            *s3.Client
+    bucket            string
+    region            string
+    maxUploadBytes    int64
+    storageQuotaBytes int64
+    imageURLTTL       time.Duration
 }
 
 func NewS3Handler() (*S3Handler, error) {
@@ -33,38 +42,209 @@ func NewS3Handler() (*S3Handler, error) {
 
     client := s3.NewFromConfig(awsCfg)
     return &S3Handler{
-        client: client,
-        bucket: cfg.S3Bucket,
-        region: cfg.AWSRegion,
+        client:            client,
+        bucket:            cfg.S3Bucket,
+        region:            cfg.AWSRegion,
+        maxUploadBytes:    cfg.MaxUploadBytes,
+        storageQuotaBytes: cfg.StorageQuotaBytes,
+        imageURLTTL:       cfg.ImageURLTTL,
     }, nil
 }
 
-func (h *S3Handler) UploadFile(file *multipart.FileHeader, prefix string) (string, error) {
-    src, err := file.Open()
+type Upload struct {
+    Key          string
+    ContentType  string
+    data         []byte
+    maxDimension int
+    sizes        []imaging.Size
+    processed    *imaging.Result
+    // raw uploads are stored exactly as read, with no renditions
+    raw          bool
+}
+
+// Process renders the stored form of the upload and returns how many bytes
+// storing it will take, so quota can be reserved before anything is
+// written. Store reuses the result.
+func (u *Upload) Process() (int64, error) {
+    if u.raw {
+        return int64(len(u.data)), nil
+    }
+    if u.processed == nil {
+        processed, err := imaging.Process(u.data, u.maxDimension, u.sizes)
+        if errors.Is(err, imaging.ErrTooManyPixels) {
+            return 0, fmt.Errorf("%w: %v", ErrFileTooLarge, err)
+        }
+        if err != nil {
+            return 0, fmt.Errorf("%w: %v", ErrUnsupportedMediaType, err)
+        }
+        u.processed = processed
+    }
+
+    return u.processed.TotalBytes(), nil
+}
+
+// ReadImage validates an item photo and derives its content-addressed key, so
+// identical photos share a single set of stored objects.
+func (h *S3Handler) ReadImage(file *multipart.FileHeader) (*Upload, error) {
+    return h.read(file, "images", imaging.MaxOriginalDimension, imaging.ThumbnailSizes)
+}
+
+func (h *S3Handler) ReadAvatar(file *multipart.FileHeader) (*Upload, error) {
+    return h.read(file, "avatars", imaging.AvatarDimension, nil)
+}
+
+func (h *S3Handler) read(file *multipart.FileHeader, prefix string, maxDimension int, sizes []imaging.Size) (*Upload, error) {
+    data, err := readImageFile(file, h.maxUploadBytes)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Upload{
+        Key:          fmt.Sprintf("%s/%s", prefix, ContentHash(data)),
+        data:         data,
+        maxDimension: maxDimension,
+        sizes:        sizes,
+    }, nil
+}
+
+// ReadReceipt validates a purchase receipt. Receipts are kept exactly as
+// uploaded, so identical files share one stored object.
+func (h *S3Handler) ReadReceipt(file *multipart.FileHeader) (*Upload, error) {
+    data, contentType, err := readReceiptFile(file, h.maxUploadBytes)
     if err != nil {
-        return "", fmt.Errorf("error opening file: %v", err)
+        return nil, err
+    }
+
+    return &Upload{
+        Key:         fmt.Sprintf("receipts/%s", ContentHash(data)),
+        ContentType: contentType,
+        data:        data,
+        raw:         true,
+    }, nil
+}
+
+// storeRaw writes the upload as is under its key, without the resizing
+// Store does for photos. The result describes the object like an image with
+// no renditions so it can share the blob registry.
+func (h *S3Handler) storeRaw(upload *Upload) (*models.ItemImage, int64, error) {
+    key := upload.Key + AllowedReceiptTypes[upload.ContentType]
+    encoded := imaging.Encoded{Data: upload.data, ContentType: upload.ContentType}
+    if err := h.putObject(key, encoded); err != nil {
+        return nil, 0, err
+    }
+
+    size := int64(len(upload.data))
+    return &models.ItemImage{
+        URL:      key,
+        ByteSize: size,
+        Variants: map[string]models.ImageVariant{},
+    }, size, nil
+}
+
+func ContentHash(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// Store processes the upload and writes the original and its renditions under
+// the upload's key, returning the stored image and the total bytes written.
+// The image refers to its objects by key; URLs are signed when it is served.
+func (h *S3Handler) Store(upload *Upload) (*models.ItemImage, int64, error) {
+    if upload.raw {
+        return h.storeRaw(upload)
     }
-    defer src.Close()
+    if _, err := upload.Process(); err != nil {
+        return nil, 0, err
+    }
+    processed := upload.processed
+
+    base := upload.Key
+    key := base + processed.Original.Extension
+
+    if err := h.putObject(key, processed.Original); err != nil {
+        return nil, 0, err
+    }
+
+    image := &models.ItemImage{
+        URL:      key,
+        Width:    processed.Original.Width,
+        Height:   processed.Original.Height,
+        ByteSize: int64(len(processed.Original.Data)),
+        Variants: make(map[string]models.ImageVariant, len(processed.Variants)),
+    }
+
+    for name, variant := range processed.Variants {
+        variantKey := fmt.Sprintf("%s_%s%s", base, name, variant.Image.Extension)
+        if err := h.putObject(variantKey, variant.Image); err != nil {
+            return nil, 0, err
+        }
+
+        webpKey := fmt.Sprintf("%s_%s%s", base, name, variant.WebP.Extension)
+        if err := h.putObject(webpKey, variant.WebP); err != nil {
+            return nil, 0, err
+        }
+
+        image.Variants[name] = models.ImageVariant{
+            URL:      variantKey,
+            WebPURL:  webpKey,
+            Width:    variant.Image.Width,
+            Height:   variant.Image.Height,
+            ByteSize: int64(len(variant.Image.Data)),
+        }
+    }
+
+    return image, processed.TotalBytes(), nil
+}
 
-    filename := generateFilename(prefix, file.Filename)
+func (h *S3Handler) StorageQuotaBytes() int64 {
+    return h.storageQuotaBytes
+}
 
-    contentType := file.Header.Get("Content-Type")
-    _, err = h.client.PutObject(context.Background(), &s3.PutObjectInput{
-        Bucket:      &h.bucket,
-        Key:         &filename,
-        Body:        src,
-        ContentType: &contentType,
+func (h *S3Handler) putObject(key string, encoded imaging.Encoded) error {
+    _, err := h.client.PutObject(context.Background(), &s3.PutObjectInput{
+        Bucket:        &h.bucket,
+        Key:           &key,
+        Body:          bytes.NewReader(encoded.Data),
+        ContentType:   &encoded.ContentType,
+        ContentLength: aws.Int64(int64(len(encoded.Data))),
     })
+    if err != nil {
+        return fmt.Errorf("error uploading to S3: %v", err)
+    }
 
+    return nil
+}
+
+func (h *S3Handler) DeleteObject(ctx context.Context, key string) error {
+    _, err := h.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+        Bucket: &h.bucket,
+        Key:    &key,
+    })
     if err != nil {
-        return "", fmt.Errorf("error uploading to S3: %v", err)
+        return fmt.Errorf("error deleting %s from S3: %v", key, err)
     }
 
-    return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", h.bucket, h.region, filename), nil
+    return nil
 }
 
-func generateFilename(prefix, originalName string) string {
-    ext := filepath.Ext(originalName)
-    timestamp := time.Now().UnixNano()
-    return fmt.Sprintf("%s/%d%s", prefix, timestamp, ext)
-}
\ No newline at end of file
+func (h *S3Handler) ListObjects(ctx context.Context, prefix string, fn func(key string, lastModified time.Time) error) error {
+    paginator := s3.NewListObjectsV2Paginator(h.client, &s3.ListObjectsV2Input{
+        Bucket: &h.bucket,
+        Prefix: &prefix,
+    })
+
+    for paginator.HasMorePages() {
+        page, err := paginator.NextPage(ctx)
+        if err != nil {
+            return fmt.Errorf("error listing S3 objects: %v", err)
+        }
+
+        for _, object := range page.Contents {
+            if err := fn(aws.ToString(object.Key), aws.ToTime(object.LastModified)); err != nil {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
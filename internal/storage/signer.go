@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/chrisabs/storage/internal/models"
+)
+
+// URLSigner turns stored object keys into short-lived presigned URLs so that
+// images are only reachable by clients the API has served them to.
+type URLSigner struct {
+    client *s3.PresignClient
+    bucket string
+    ttl    time.Duration
+}
+
+func (h *S3Handler) URLSigner() *URLSigner {
+    return &URLSigner{
+        client: s3.NewPresignClient(h.client),
+        bucket: h.bucket,
+        ttl:    h.imageURLTTL,
+    }
+}
+
+// SignURL returns a presigned GET URL for key. Absolute URLs that do not point
+// into the bucket are returned as is, as is everything when the signer is nil.
+func (s *URLSigner) SignURL(key string) string {
+    if s == nil || key == "" || strings.Contains(key, "://") {
+        return key
+    }
+
+    cacheControl := fmt.Sprintf("private, max-age=%d", int(s.ttl.Seconds()))
+    req, err := s.client.PresignGetObject(context.Background(), &s3.GetObjectInput{
+        Bucket:               &s.bucket,
+        Key:                  &key,
+        ResponseCacheControl: aws.String(cacheControl),
+    }, s3.WithPresignExpires(s.ttl))
+    if err != nil {
+        log.Printf("Error signing URL for %s: %v", key, err)
+        return ""
+    }
+
+    return req.URL
+}
+
+func (s *URLSigner) SignImage(image *models.ItemImage) {
+    image.URL = s.SignURL(image.URL)
+    for name, variant := range image.Variants {
+        variant.URL = s.SignURL(variant.URL)
+        variant.WebPURL = s.SignURL(variant.WebPURL)
+        image.Variants[name] = variant
+    }
+}
+
+func (s *URLSigner) SignItem(item *models.Item) {
+    for i := range item.Images {
+        s.SignImage(&item.Images[i])
+    }
+    for i := range item.Receipts {
+        item.Receipts[i].URL = s.SignURL(item.Receipts[i].URL)
+    }
+    if item.Container != nil {
+        s.SignContainer(item.Container)
+    }
+}
+
+func (s *URLSigner) SignContainer(container *models.Container) {
+    for i := range container.Items {
+        s.SignItem(&container.Items[i])
+    }
+    for i := range container.Images {
+        s.SignImage(&container.Images[i])
+    }
+    if container.CoverImage != nil {
+        s.SignImage(container.CoverImage)
+    }
+}
+
+func (s *URLSigner) SignTag(tag *models.Tag) {
+    for i := range tag.Items {
+        s.SignItem(&tag.Items[i])
+    }
+}
+
+func (s *URLSigner) SignUser(user *models.User) {
+    user.ImageURL = s.SignURL(user.ImageURL)
+    for i := range user.Containers {
+        s.SignContainer(&user.Containers[i])
+    }
+}
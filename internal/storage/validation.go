@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+var (
+	ErrFileTooLarge         = errors.New("file exceeds the maximum upload size")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrQuotaExceeded        = errors.New("storage quota exceeded")
+)
+
+var AllowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// AllowedReceiptTypes are what purchase receipts may be uploaded as: a photo
+// or a scanned PDF.
+var AllowedReceiptTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// readImageFile reads an uploaded file, rejecting anything larger than
+// maxBytes or whose content is not an allowed image type. The client-supplied
+// Content-Type and file extension are ignored.
+func readImageFile(file *multipart.FileHeader, maxBytes int64) ([]byte, error) {
+	if file.Size > maxBytes {
+		return nil, fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrFileTooLarge, file.Filename, file.Size, maxBytes)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrFileTooLarge, file.Filename, maxBytes)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !AllowedImageTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s is %s, expected JPEG, PNG, GIF or WebP", ErrUnsupportedMediaType, file.Filename, contentType)
+	}
+
+	return data, nil
+}
+
+// readReceiptFile reads an uploaded receipt the same way, accepting images
+// and PDFs, and returns the detected content type.
+func readReceiptFile(file *multipart.FileHeader, maxBytes int64) ([]byte, string, error) {
+	if file.Size > maxBytes {
+		return nil, "", fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrFileTooLarge, file.Filename, file.Size, maxBytes)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, "", fmt.Errorf("error opening file: %v", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading file: %v", err)
+	}
+
+	if int64(len(data)) > maxBytes {
+		return nil, "", fmt.Errorf("%w: %s exceeds %d bytes", ErrFileTooLarge, file.Filename, maxBytes)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := AllowedReceiptTypes[contentType]; !ok {
+		return nil, "", fmt.Errorf("%w: %s is %s, expected JPEG, PNG, WebP or PDF", ErrUnsupportedMediaType, file.Filename, contentType)
+	}
+
+	return data, contentType, nil
+}
+
+func UploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrFileTooLarge), errors.Is(err, ErrQuotaExceeded):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
\ No newline at end of file
@@ -46,6 +46,10 @@ func (r *Repository) GetByID(id int) (*models.Tag, error) {
                        jsonb_build_object(
                            'id', id,
                            'url', url,
+                           'width', width,
+                           'height', height,
+                           'byteSize', byte_size,
+                           'variants', variants,
                            'displayOrder', display_order,
                            'createdAt', created_at,
                            'updatedAt', updated_at
@@ -63,6 +67,7 @@ func (r *Repository) GetByID(id int) (*models.Tag, error) {
                            'description', i.description,
                            'images', COALESCE(img.images, '[]'::jsonb),
                            'quantity', i.quantity,
+                           'unit', i.unit,
                            'containerId', i.container_id,
                            'container', CASE 
                                WHEN c.id IS NOT NULL THEN
@@ -70,8 +75,8 @@ func (r *Repository) GetByID(id int) (*models.Tag, error) {
                                        'id', c.id,
                                        'name', c.name,
                                        'qrCode', c.qr_code,
-                                       'qrCodeImage', c.qr_code_image,
                                        'number', c.number,
+                                       'label', c.label,
                                        'location', c.location,
                                        'userId', c.user_id,
                                        'workspaceId', c.workspace_id,
@@ -155,6 +160,10 @@ func (r *Repository) GetAll() ([]*models.Tag, error) {
                        jsonb_build_object(
                            'id', id,
                            'url', url,
+                           'width', width,
+                           'height', height,
+                           'byteSize', byte_size,
+                           'variants', variants,
                            'displayOrder', display_order,
                            'createdAt', created_at,
                            'updatedAt', updated_at
@@ -173,6 +182,7 @@ func (r *Repository) GetAll() ([]*models.Tag, error) {
                            'description', i.description,
                            'images', COALESCE(img.images, '[]'::jsonb),
                            'quantity', i.quantity,
+                           'unit', i.unit,
                            'containerId', i.container_id,
                            'container', CASE 
                                WHEN c.id IS NOT NULL THEN
@@ -180,8 +190,8 @@ func (r *Repository) GetAll() ([]*models.Tag, error) {
                                        'id', c.id,
                                        'name', c.name,
                                        'qrCode', c.qr_code,
-                                       'qrCodeImage', c.qr_code_image,
                                        'number', c.number,
+                                       'label', c.label,
                                        'location', c.location,
                                        'userId', c.user_id,
                                        'workspaceId', c.workspace_id,
@@ -5,15 +5,18 @@ import (
 	"time"
 
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
 )
 
 type Service struct {
-	repo *Repository
+	repo   *Repository
+	signer *storage.URLSigner
 }
 
-func NewService(repo *Repository) *Service {
+func NewService(repo *Repository, signer *storage.URLSigner) *Service {
 	return &Service{
-		repo: repo,
+		repo:   repo,
+		signer: signer,
 	}
 }
 
@@ -30,15 +33,29 @@ func (s *Service) CreateTag(req *CreateTagRequest) (*models.Tag, error) {
 		return nil, fmt.Errorf("failed to create tag: %v", err)
 	}
 
-	return s.repo.GetByID(tag.ID)
+	return s.GetTagByID(tag.ID)
 }
 
 func (s *Service) GetTagByID(id int) (*models.Tag, error) {
-	return s.repo.GetByID(id)
+	tag, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.signer.SignTag(tag)
+	return tag, nil
 }
 
 func (s *Service) GetAllTags() ([]*models.Tag, error) {
-	return s.repo.GetAll()
+	tags, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		s.signer.SignTag(tag)
+	}
+	return tags, nil
 }
 
 func (s *Service) UpdateTag(id int, req *UpdateTagRequest) (*models.Tag, error) {
@@ -55,7 +72,7 @@ func (s *Service) UpdateTag(id int, req *UpdateTagRequest) (*models.Tag, error)
 		return nil, fmt.Errorf("failed to update tag: %v", err)
 	}
 
-	return s.repo.GetByID(id)
+	return s.GetTagByID(id)
 }
 
 func (s *Service) AssignTagsToItems(tagIDs []int, itemIDs []int) error {
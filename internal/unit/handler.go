@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+    service        *Service
+    authMiddleware *middleware.AuthMiddleware
+}
+
+func NewHandler(service *Service, authMiddleware *middleware.AuthMiddleware) *Handler {
+    return &Handler{
+        service:        service,
+        authMiddleware: authMiddleware,
+    }
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+    router.HandleFunc("/units", h.authMiddleware.AuthHandler(h.handleGetUnits)).Methods("GET")
+    router.HandleFunc("/units/convert", h.authMiddleware.AuthHandler(h.handleConvert)).Methods("GET")
+}
+
+func (h *Handler) handleGetUnits(w http.ResponseWriter, r *http.Request) {
+    units, err := h.service.GetUnits(r.URL.Query().Get("dimension"))
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, units)
+}
+
+func (h *Handler) handleConvert(w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+
+    value, err := strconv.ParseFloat(query.Get("value"), 64)
+    if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+        writeError(w, http.StatusBadRequest, "invalid value")
+        return
+    }
+
+    conversion, err := h.service.Convert(value, query.Get("from"), query.Get("to"))
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, conversion)
+}
+
+func errorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrUnknownUnit), errors.Is(err, ErrInvalidDimension),
+        errors.Is(err, ErrDimensionMismatch):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,29 @@
+package unit
+
+import (
+	"errors"
+)
+
+const (
+    DimensionCount  = "count"
+    DimensionLength = "length"
+    DimensionVolume = "volume"
+    DimensionMass   = "mass"
+)
+
+// DefaultUnit is what items are counted in unless told otherwise.
+const DefaultUnit = "each"
+
+var (
+    ErrUnknownUnit       = errors.New("unknown unit")
+    ErrInvalidDimension  = errors.New("dimension must be count, length, volume or mass")
+    ErrDimensionMismatch = errors.New("units measure different things and cannot be converted")
+)
+
+// Conversion is a value expressed in one unit and its equivalent in another.
+type Conversion struct {
+    Value  float64 `json:"value"`
+    From   string  `json:"from"`
+    To     string  `json:"to"`
+    Result float64 `json:"result"`
+}
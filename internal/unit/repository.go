@@ -0,0 +1,58 @@
+package unit
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+// GetAll lists the catalogue, optionally for one dimension, smallest unit
+// first within each dimension.
+func (r *Repository) GetAll(dimension string) ([]models.Unit, error) {
+    query := `
+        SELECT code, name, dimension, factor
+        FROM unit
+        WHERE $1 = '' OR dimension = $1
+        ORDER BY dimension, factor, code`
+
+    rows, err := r.db.Query(query, dimension)
+    if err != nil {
+        return nil, fmt.Errorf("error querying units: %v", err)
+    }
+    defer rows.Close()
+
+    units := []models.Unit{}
+    for rows.Next() {
+        var u models.Unit
+        if err := rows.Scan(&u.Code, &u.Name, &u.Dimension, &u.Factor); err != nil {
+            return nil, fmt.Errorf("error scanning unit: %v", err)
+        }
+        units = append(units, u)
+    }
+
+    return units, rows.Err()
+}
+
+func (r *Repository) GetByCode(code string) (*models.Unit, error) {
+    u := new(models.Unit)
+    err := r.db.QueryRow(
+        `SELECT code, name, dimension, factor FROM unit WHERE code = $1`, code,
+    ).Scan(&u.Code, &u.Name, &u.Dimension, &u.Factor)
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("%w: %s", ErrUnknownUnit, code)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error querying unit: %v", err)
+    }
+
+    return u, nil
+}
@@ -0,0 +1,62 @@
+package unit
+
+import (
+	"math"
+	"strings"
+
+	"github.com/chrisabs/storage/internal/models"
+)
+
+type Service struct {
+    repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+    return &Service{repo: repo}
+}
+
+func (s *Service) GetUnits(dimension string) ([]models.Unit, error) {
+    switch dimension {
+    case "", DimensionCount, DimensionLength, DimensionVolume, DimensionMass:
+    default:
+        return nil, ErrInvalidDimension
+    }
+
+    return s.repo.GetAll(dimension)
+}
+
+// GetUnit looks a unit up by code, falling back to the default unit when
+// code is empty.
+func (s *Service) GetUnit(code string) (*models.Unit, error) {
+    code = strings.TrimSpace(code)
+    if code == "" {
+        code = DefaultUnit
+    }
+
+    return s.repo.GetByCode(code)
+}
+
+// Convert expresses value, given in from, in to. Both units must measure
+// the same dimension. Results are rounded to the precision quantities are
+// stored with.
+func (s *Service) Convert(value float64, from, to string) (*Conversion, error) {
+    fromUnit, err := s.GetUnit(from)
+    if err != nil {
+        return nil, err
+    }
+    toUnit, err := s.GetUnit(to)
+    if err != nil {
+        return nil, err
+    }
+    if fromUnit.Dimension != toUnit.Dimension {
+        return nil, ErrDimensionMismatch
+    }
+
+    result := value * fromUnit.Factor / toUnit.Factor
+    return &Conversion{
+        Value:  value,
+        From:   fromUnit.Code,
+        To:     toUnit.Code,
+        Result: math.Round(result*1000) / 1000,
+    }, nil
+}
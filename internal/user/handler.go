@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/chrisabs/storage/internal/storage"
 	"github.com/gorilla/mux"
 )
 
@@ -141,7 +142,7 @@ func (h *Handler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 
     user, err := h.service.UpdateUser(id, firstName, lastName, imageFile)
     if err != nil {
-        writeError(w, http.StatusInternalServerError, err.Error())
+        writeError(w, storage.UploadErrorStatus(err), err.Error())
         return
     }
 
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/chrisabs/storage/internal/blob"
 	"github.com/chrisabs/storage/internal/models"
+	"github.com/chrisabs/storage/internal/storage"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -76,7 +78,9 @@ func (r *Repository) GetByEmail(email string) (*models.User, error) {
 
 func (r *Repository) GetByID(id int) (*models.User, error) {
 	query := `
-        SELECT id, email, first_name, last_name, image_url, created_at, updated_at
+        SELECT id, email, first_name, last_name, image_url, image_bytes,
+               COALESCE(image_blob_key, ''), storage_used_bytes, storage_quota_bytes,
+               created_at, updated_at
         FROM users
         WHERE id = $1`
 
@@ -87,6 +91,10 @@ func (r *Repository) GetByID(id int) (*models.User, error) {
 		&user.FirstName,
 		&user.LastName,
 		&user.ImageURL,
+		&user.ImageBytes,
+		&user.ImageBlobKey,
+		&user.StorageUsedBytes,
+		&user.StorageQuotaBytes,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -99,9 +107,9 @@ func (r *Repository) GetByID(id int) (*models.User, error) {
 	}
 
 	containersQuery := `
-        SELECT id, name, qr_code, qr_code_image, number, location, created_at, updated_at
+        SELECT id, name, qr_code, number, COALESCE(label, ''), location, created_at, updated_at
         FROM container
-        WHERE user_id = $1
+        WHERE user_id = $1 AND retired_at IS NULL
         ORDER BY created_at DESC`
 
 	rows, err := r.db.Query(containersQuery, id)
@@ -117,8 +125,8 @@ func (r *Repository) GetByID(id int) (*models.User, error) {
 			&container.ID,
 			&container.Name,
 			&container.QRCode,
-			&container.QRCodeImage,
 			&container.Number,
+			&container.Label,
 			&container.Location,
 			&container.CreatedAt,
 			&container.UpdatedAt,
@@ -165,18 +173,53 @@ func (r *Repository) GetAll() ([]*models.User, error) {
 	return users, nil
 }
 
-func (r *Repository) Update(user *models.User) error {
+// Update saves the user's profile. A non-nil avatar replaces the current one
+// in the same transaction; it is stored unless identical content already is.
+func (r *Repository) Update(user *models.User, store blob.Storer, avatar *storage.Upload) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var previousImageURL, previousBlobKey sql.NullString
+	var previousImageBytes int64
+	err = tx.QueryRow(
+		`SELECT image_url, image_bytes, image_blob_key FROM users WHERE id = $1 FOR UPDATE`, user.ID,
+	).Scan(&previousImageURL, &previousImageBytes, &previousBlobKey)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return fmt.Errorf("error getting user: %v", err)
+	}
+
+	if avatar != nil && avatar.Key != previousBlobKey.String {
+		image, err := blob.Acquire(tx, store, user.ID, avatar)
+		if err != nil {
+			return err
+		}
+
+		// The blob carries the stored bytes
+		user.ImageURL = image.URL
+		user.ImageBytes = 0
+		user.ImageBlobKey = avatar.Key
+	}
+
 	query := `
         UPDATE users
-        SET first_name = $2, last_name = $3, image_url = $4, updated_at = $5
+        SET first_name = $2, last_name = $3, image_url = $4, image_bytes = $5,
+            image_blob_key = NULLIF($6, ''), updated_at = $7
         WHERE id = $1`
 
-	result, err := r.db.Exec(
+	_, err = tx.Exec(
 		query,
 		user.ID,
 		user.FirstName,
 		user.LastName,
 		user.ImageURL,
+		user.ImageBytes,
+		user.ImageBlobKey,
 		time.Now().UTC(),
 	)
 
@@ -184,33 +227,72 @@ func (r *Repository) Update(user *models.User) error {
 		return fmt.Errorf("error updating user: %v", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("error checking update result: %v", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+	// Replaced avatars are removed from storage once the update commits
+	if previousImageURL.String != "" && previousImageURL.String != user.ImageURL {
+		if err := releaseAvatar(tx, previousImageURL.String, previousBlobKey.String); err != nil {
+			return err
+		}
+		if err := blob.ReleaseStorage(tx, user.ID, previousImageBytes); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (r *Repository) Delete(id int) error {
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	tx, err := r.db.Begin()
 	if err != nil {
-		return fmt.Errorf("error deleting user: %v", err)
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The user's containers, and the items in them, go with the user. Their
+	// photos and receipts are released first so the objects are queued for
+	// deletion rather than dropped by the cascade.
+	releaseQueries := []string{
+		`DELETE FROM item_image
+		 WHERE item_id IN (SELECT i.id FROM item i JOIN container c ON i.container_id = c.id WHERE c.user_id = $1)
+		 RETURNING url, variants, user_id, storage_bytes, blob_key`,
+		`DELETE FROM item_receipt
+		 WHERE item_id IN (SELECT i.id FROM item i JOIN container c ON i.container_id = c.id WHERE c.user_id = $1)
+		 RETURNING url, '{}'::jsonb, user_id, storage_bytes, blob_key`,
+		`DELETE FROM container_image
+		 WHERE container_id IN (SELECT id FROM container WHERE user_id = $1)
+		 RETURNING url, variants, user_id, storage_bytes, blob_key`,
+	}
+	for _, releaseQuery := range releaseQueries {
+		if _, err := blob.DeleteImages(tx, releaseQuery, id); err != nil {
+			return fmt.Errorf("error releasing images: %v", err)
+		}
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("error checking delete result: %v", err)
+	if _, err := tx.Exec(`DELETE FROM container WHERE user_id = $1`, id); err != nil {
+		return fmt.Errorf("error deleting containers: %v", err)
 	}
 
-	if rowsAffected == 0 {
+	var imageURL, blobKey sql.NullString
+	query := `DELETE FROM users WHERE id = $1 RETURNING image_url, image_blob_key`
+	err = tx.QueryRow(query, id).Scan(&imageURL, &blobKey)
+	if err == sql.ErrNoRows {
 		return fmt.Errorf("user not found")
 	}
+	if err != nil {
+		return fmt.Errorf("error deleting user: %v", err)
+	}
 
-	return nil
+	if err := releaseAvatar(tx, imageURL.String, blobKey.String); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// releaseAvatar drops the user's reference to their avatar blob. Avatars
+// uploaded before deduplication have no blob and are queued directly.
+func releaseAvatar(tx *sql.Tx, imageURL, blobKey string) error {
+	if blobKey != "" {
+		return blob.Release(tx, blobKey)
+	}
+	return blob.Enqueue(tx, imageURL)
 }
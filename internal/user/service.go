@@ -14,12 +14,14 @@ import (
 type Service struct {
 	repo      *Repository
 	jwtSecret string
+	signer    *storage.URLSigner
 }
 
-func NewService(repo *Repository, jwtSecret string) *Service {
+func NewService(repo *Repository, jwtSecret string, signer *storage.URLSigner) *Service {
 	return &Service{
 		repo:      repo,
 		jwtSecret: jwtSecret,
+		signer:    signer,
 	}
 }
 
@@ -52,7 +54,7 @@ func (s *Service) CreateUser(req *CreateUserRequest) (*models.User, error) {
 		return nil, fmt.Errorf("failed to create user: %v", err)
 	}
 
-	return s.repo.GetByID(user.ID)
+	return s.GetUserByID(user.ID)
 }
 
 func (s *Service) Login(req *LoginRequest) (*AuthResponse, error) {
@@ -70,6 +72,8 @@ func (s *Service) Login(req *LoginRequest) (*AuthResponse, error) {
 		return nil, fmt.Errorf("failed to generate token: %v", err)
 	}
 
+	s.signer.SignUser(user)
+
 	return &AuthResponse{
 		Token: token,
 		User:  *user,
@@ -77,11 +81,25 @@ func (s *Service) Login(req *LoginRequest) (*AuthResponse, error) {
 }
 
 func (s *Service) GetUserByID(id int) (*models.User, error) {
-	return s.repo.GetByID(id)
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.signer.SignUser(user)
+	return user, nil
 }
 
 func (s *Service) GetAllUsers() ([]*models.User, error) {
-	return s.repo.GetAll()
+	users, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		s.signer.SignUser(user)
+	}
+	return users, nil
 }
 
 func (s *Service) UpdateUser(id int, firstName, lastName string, imageFile *multipart.FileHeader) (*models.User, error) {
@@ -94,24 +112,25 @@ func (s *Service) UpdateUser(id int, firstName, lastName string, imageFile *mult
     user.LastName = lastName
     user.UpdatedAt = time.Now().UTC()
 
+    var store *storage.S3Handler
+    var avatar *storage.Upload
     if imageFile != nil {
-        s3Handler, err := storage.NewS3Handler()
+        store, err = storage.NewS3Handler()
         if err != nil {
             return nil, fmt.Errorf("failed to initialize storage: %v", err)
         }
 
-        imageURL, err := s3Handler.UploadFile(imageFile, fmt.Sprintf("users/%d", id))
+        avatar, err = store.ReadAvatar(imageFile)
         if err != nil {
-            return nil, fmt.Errorf("failed to upload image: %v", err)
+            return nil, fmt.Errorf("failed to upload image: %w", err)
         }
-        user.ImageURL = imageURL
     }
 
-    if err := s.repo.Update(user); err != nil {
-        return nil, fmt.Errorf("failed to update user: %v", err)
+    if err := s.repo.Update(user, store, avatar); err != nil {
+        return nil, fmt.Errorf("failed to update user: %w", err)
     }
 
-    return s.repo.GetByID(id)
+    return s.GetUserByID(id)
 }
 
 func (s *Service) DeleteUser(id int) error {
@@ -0,0 +1,68 @@
+package valuation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chrisabs/storage/internal/middleware"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+    service        *Service
+    authMiddleware *middleware.AuthMiddleware
+}
+
+func NewHandler(service *Service, authMiddleware *middleware.AuthMiddleware) *Handler {
+    return &Handler{
+        service:        service,
+        authMiddleware: authMiddleware,
+    }
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+    router.HandleFunc("/valuation/rates", h.authMiddleware.AuthHandler(h.handleGetRates)).Methods("GET")
+    router.HandleFunc("/valuation/{groupBy}", h.authMiddleware.AuthHandler(h.handleGetReport)).Methods("GET")
+}
+
+// handleGetReport totals item values per workspace, container, location or
+// tag; ?currency= picks what to report in.
+func (h *Handler) handleGetReport(w http.ResponseWriter, r *http.Request) {
+    userID, err := strconv.Atoi(r.Header.Get("UserId"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid user ID")
+        return
+    }
+
+    report, err := h.service.GetReport(userID, mux.Vars(r)["groupBy"], r.URL.Query().Get("currency"))
+    if err != nil {
+        writeError(w, errorStatus(err), err.Error())
+        return
+    }
+    writeJSON(w, http.StatusOK, report)
+}
+
+func (h *Handler) handleGetRates(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, h.service.GetRates())
+}
+
+func errorStatus(err error) int {
+    switch {
+    case errors.Is(err, ErrInvalidGrouping), errors.Is(err, ErrUnknownCurrency):
+        return http.StatusBadRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,63 @@
+package valuation
+
+import (
+	"errors"
+)
+
+// Ways a valuation report can be grouped, as they appear in the URL.
+const (
+    ByWorkspace = "workspaces"
+    ByContainer = "containers"
+    ByLocation  = "locations"
+    ByTag       = "tags"
+)
+
+var (
+    ErrInvalidGrouping = errors.New("valuations are grouped by workspaces, containers, locations or tags")
+    ErrUnknownCurrency = errors.New("currency is not one with a configured exchange rate")
+)
+
+// Report totals what the caller's items are worth. Items are valued at their
+// estimated value, or failing that their purchase price, times their
+// quantity. Amounts in currencies that no longer have an exchange rate are
+// listed under Unconverted instead of being left out silently.
+type Report struct {
+    GroupBy       string             `json:"groupBy"`
+    Currency      string             `json:"currency"`
+    Total         float64            `json:"total"`
+    Items         int                `json:"items"`
+    UnpricedItems int                `json:"unpricedItems"`
+    Unconverted   map[string]float64 `json:"unconverted,omitempty"`
+    Groups        []Group            `json:"groups"`
+}
+
+// Group is one workspace, container, location or tag in a report. ID is
+// nil for items with none, such as untagged items. An item with several
+// tags counts towards each of them, so tag totals can add up to more than
+// the report total.
+type Group struct {
+    ID            *int               `json:"id"`
+    Name          string             `json:"name"`
+    Total         float64            `json:"total"`
+    Items         int                `json:"items"`
+    UnpricedItems int                `json:"unpricedItems"`
+    Unconverted   map[string]float64 `json:"unconverted,omitempty"`
+}
+
+// RateTable lists the configured exchange rates: what one unit of each
+// currency is worth in the base currency.
+type RateTable struct {
+    Base  string             `json:"base"`
+    Rates map[string]float64 `json:"rates"`
+}
+
+// subtotal is the value of one group's items in one currency. Currency is
+// empty for items with no price at all.
+type subtotal struct {
+    groupID  *int
+    name     string
+    currency string
+    value    float64
+    items    int
+    unpriced int
+}
@@ -0,0 +1,48 @@
+package valuation
+
+// Rates is the static exchange-rate table item values are converted with.
+type Rates struct {
+    base  string
+    rates map[string]float64
+}
+
+// NewRates takes what one unit of each currency is worth in base. The base
+// currency is always known, at a rate of 1.
+func NewRates(base string, rates map[string]float64) *Rates {
+    table := map[string]float64{base: 1}
+    for code, rate := range rates {
+        table[code] = rate
+    }
+    return &Rates{base: base, rates: table}
+}
+
+func (r *Rates) BaseCurrency() string {
+    return r.base
+}
+
+func (r *Rates) KnownCurrency(code string) bool {
+    _, ok := r.rates[code]
+    return ok
+}
+
+// Convert expresses amount, given in from, in to. It reports false when
+// either currency has no rate.
+func (r *Rates) Convert(amount float64, from, to string) (float64, bool) {
+    fromRate, ok := r.rates[from]
+    if !ok {
+        return 0, false
+    }
+    toRate, ok := r.rates[to]
+    if !ok {
+        return 0, false
+    }
+    return amount * fromRate / toRate, true
+}
+
+func (r *Rates) Table() *RateTable {
+    rates := make(map[string]float64, len(r.rates))
+    for code, rate := range r.rates {
+        rates[code] = rate
+    }
+    return &RateTable{Base: r.base, Rates: rates}
+}
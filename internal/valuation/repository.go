@@ -0,0 +1,76 @@
+package valuation
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type Repository struct {
+    db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+    return &Repository{db: db}
+}
+
+// groupings joins each way of grouping onto the valued items and names the
+// group's ID and name. Items outside any group get a NULL ID.
+var groupings = map[string]struct {
+    join string
+    id   string
+    name string
+}{
+    "":          {join: "", id: "NULL::INTEGER", name: "''"},
+    ByWorkspace: {join: "LEFT JOIN workspace g ON v.workspace_id = g.id", id: "g.id", name: "COALESCE(g.name, '')"},
+    ByContainer: {join: "JOIN container g ON v.container_id = g.id", id: "g.id", name: "COALESCE(g.name, '')"},
+    ByLocation:  {join: "LEFT JOIN location g ON v.location_id = g.id", id: "g.id", name: "COALESCE(g.name, '')"},
+    ByTag: {
+        join: "LEFT JOIN item_tag it ON v.id = it.item_id LEFT JOIN tag g ON it.tag_id = g.id",
+        id:   "g.id",
+        name: "COALESCE(g.name, '')",
+    },
+}
+
+// GetSubtotals sums the value of userID's items per group and currency.
+// Items in retired containers have been merged elsewhere and are left out.
+// An empty groupBy sums everything into a single group.
+func (r *Repository) GetSubtotals(userID int, groupBy string) ([]subtotal, error) {
+    grouping, ok := groupings[groupBy]
+    if !ok {
+        return nil, ErrInvalidGrouping
+    }
+
+    query := fmt.Sprintf(`
+        WITH valued AS (
+            SELECT i.id, i.container_id, c.workspace_id, c.location_id,
+                   COALESCE(i.currency, '') AS currency,
+                   COALESCE(i.estimated_value, i.purchase_price) * COALESCE(i.quantity, 0) AS value
+            FROM item i
+            JOIN container c ON i.container_id = c.id
+            WHERE c.user_id = $1 AND c.retired_at IS NULL
+        )
+        SELECT %[2]s, %[3]s, v.currency,
+               COALESCE(SUM(v.value), 0), COUNT(*), COUNT(*) FILTER (WHERE v.value IS NULL)
+        FROM valued v
+        %[1]s
+        GROUP BY 1, 2, v.currency`,
+        grouping.join, grouping.id, grouping.name,
+    )
+
+    rows, err := r.db.Query(query, userID)
+    if err != nil {
+        return nil, fmt.Errorf("error querying item values: %v", err)
+    }
+    defer rows.Close()
+
+    var subtotals []subtotal
+    for rows.Next() {
+        var s subtotal
+        if err := rows.Scan(&s.groupID, &s.name, &s.currency, &s.value, &s.items, &s.unpriced); err != nil {
+            return nil, fmt.Errorf("error scanning item values: %v", err)
+        }
+        subtotals = append(subtotals, s)
+    }
+
+    return subtotals, rows.Err()
+}
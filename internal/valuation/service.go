@@ -0,0 +1,135 @@
+package valuation
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+type Service struct {
+    repo  *Repository
+    rates *Rates
+}
+
+func NewService(repo *Repository, rates *Rates) *Service {
+    return &Service{repo: repo, rates: rates}
+}
+
+// GetReport values userID's items grouped by groupBy, converted into
+// currency, or the base currency when that is empty.
+func (s *Service) GetReport(userID int, groupBy, currency string) (*Report, error) {
+    currency = strings.ToUpper(strings.TrimSpace(currency))
+    if currency == "" {
+        currency = s.rates.BaseCurrency()
+    }
+    if !s.rates.KnownCurrency(currency) {
+        return nil, ErrUnknownCurrency
+    }
+    if groupBy == "" {
+        return nil, ErrInvalidGrouping
+    }
+
+    grouped, err := s.repo.GetSubtotals(userID, groupBy)
+    if err != nil {
+        return nil, err
+    }
+    // Tag groups overlap, so the overall total is summed separately
+    overall, err := s.repo.GetSubtotals(userID, "")
+    if err != nil {
+        return nil, err
+    }
+
+    report := &Report{GroupBy: groupBy, Currency: currency, Groups: []Group{}}
+    // Keyed by group ID; IDs start at 1 so 0 stands for no group
+    index := make(map[int]int)
+    for _, sub := range grouped {
+        key, name := 0, ungroupedName(groupBy)
+        if sub.groupID != nil {
+            key, name = *sub.groupID, sub.name
+        }
+
+        i, ok := index[key]
+        if !ok {
+            i = len(report.Groups)
+            index[key] = i
+            report.Groups = append(report.Groups, Group{ID: sub.groupID, Name: name})
+        }
+
+        group := &report.Groups[i]
+        group.Items += sub.items
+        group.UnpricedItems += sub.unpriced
+        group.Total += s.add(sub, currency, &group.Unconverted)
+    }
+
+    for _, sub := range overall {
+        report.Items += sub.items
+        report.UnpricedItems += sub.unpriced
+        report.Total += s.add(sub, currency, &report.Unconverted)
+    }
+
+    report.Total = roundMoney(report.Total)
+    roundAll(report.Unconverted)
+    for i := range report.Groups {
+        report.Groups[i].Total = roundMoney(report.Groups[i].Total)
+        roundAll(report.Groups[i].Unconverted)
+    }
+
+    // Most valuable first, with items outside any group at the end
+    sort.SliceStable(report.Groups, func(i, j int) bool {
+        a, b := report.Groups[i], report.Groups[j]
+        if (a.ID == nil) != (b.ID == nil) {
+            return b.ID == nil
+        }
+        if a.Total != b.Total {
+            return a.Total > b.Total
+        }
+        return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+    })
+
+    return report, nil
+}
+
+func (s *Service) GetRates() *RateTable {
+    return s.rates.Table()
+}
+
+// add converts a subtotal into currency. Amounts with no exchange rate are
+// collected into unconverted and contribute nothing to the total.
+func (s *Service) add(sub subtotal, currency string, unconverted *map[string]float64) float64 {
+    if sub.currency == "" || sub.value == 0 {
+        return 0
+    }
+
+    converted, ok := s.rates.Convert(sub.value, sub.currency, currency)
+    if !ok {
+        if *unconverted == nil {
+            *unconverted = make(map[string]float64)
+        }
+        (*unconverted)[sub.currency] += sub.value
+        return 0
+    }
+    return converted
+}
+
+func ungroupedName(groupBy string) string {
+    switch groupBy {
+    case ByWorkspace:
+        return "No workspace"
+    case ByLocation:
+        return "No location"
+    case ByTag:
+        return "Untagged"
+    default:
+        return ""
+    }
+}
+
+func roundMoney(amount float64) float64 {
+    return math.Round(amount*100) / 100
+}
+
+func roundAll(amounts map[string]float64) {
+    for code, amount := range amounts {
+        amounts[code] = roundMoney(amount)
+    }
+}
@@ -18,13 +18,12 @@ func NewRepository(db *sql.DB) *Repository {
 
 func (r *Repository) Create(workspace *models.Workspace) error {
     query := `
-        INSERT INTO workspace (id, name, description, user_id, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO workspace (name, description, user_id, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
         RETURNING id`
 
     err := r.db.QueryRow(
         query,
-        workspace.ID,
         workspace.Name,
         workspace.Description,
         workspace.UserID,
@@ -64,10 +63,10 @@ func (r *Repository) GetByID(id int) (*models.Workspace, error) {
 
     containersQuery := `
         SELECT 
-            id, name, description, qr_code, qr_code_image, number, location, 
+            id, name, description, qr_code, number, COALESCE(label, ''), location, 
             user_id, workspace_id, created_at, updated_at
         FROM container
-        WHERE workspace_id = $1
+        WHERE workspace_id = $1 AND retired_at IS NULL
         ORDER BY created_at DESC`
 
     rows, err := r.db.Query(containersQuery, id)
@@ -85,8 +84,8 @@ func (r *Repository) GetByID(id int) (*models.Workspace, error) {
             &container.Name,
             &container.Description,
             &container.QRCode,
-            &container.QRCodeImage,
             &container.Number,
+            &container.Label,
             &container.Location,
             &container.UserID,
             &workspaceID,
@@ -138,10 +137,10 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Workspace, error) {
 
         containersQuery := `
             SELECT 
-                id, name, description, qr_code, qr_code_image, number, location, 
+                id, name, description, qr_code, number, COALESCE(label, ''), location, 
                 user_id, workspace_id, created_at, updated_at
             FROM container
-            WHERE workspace_id = $1
+            WHERE workspace_id = $1 AND retired_at IS NULL
             ORDER BY created_at DESC`
 
         containerRows, err := r.db.Query(containersQuery, workspace.ID)
@@ -160,8 +159,8 @@ func (r *Repository) GetByUserID(userID int) ([]*models.Workspace, error) {
                     &container.Name,
                     &container.Description,
                     &container.QRCode,
-                    &container.QRCodeImage,
                     &container.Number,
+                    &container.Label,
                     &container.Location,
                     &container.UserID,
                     &workspaceID,
@@ -2,7 +2,6 @@ package workspace
 
 import (
 	"fmt"
-	"math/rand"
 	"time"
 
 	"github.com/chrisabs/storage/internal/models"
@@ -18,7 +17,6 @@ func NewService(repo *Repository) *Service {
 
 func (s *Service) CreateWorkspace(userID int, req *CreateWorkspaceRequest) (*models.Workspace, error) {
     workspace := &models.Workspace{
-        ID:          rand.Intn(10000),
         Name:        req.Name,
         Description: req.Description,
         UserID:      userID,
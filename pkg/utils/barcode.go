@@ -0,0 +1,26 @@
+package utils
+
+import "strings"
+
+// MaxBarcodeLength matches the item.barcode column.
+const MaxBarcodeLength = 64
+
+// NormalizeBarcode trims a scanned or typed product code and folds UPC-A onto
+// EAN-13, so a 12 digit UPC matches the same product stored with its leading
+// zero.
+func NormalizeBarcode(code string) string {
+	code = strings.ToUpper(strings.Join(strings.Fields(code), ""))
+	if len(code) == 12 && isDigits(code) {
+		return "0" + code
+	}
+	return code
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -1,22 +1,42 @@
 package utils
 
 import (
+	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"time"
-
-	"github.com/skip2/go-qrcode"
+	"strings"
 )
 
-func GenerateQRCode(containerID int) (string, string, error) {
-	qrString := fmt.Sprintf("STQRAGE-CONTAINER-%d-%d", containerID, time.Now().Unix())
+// qrTokenBytes gives 128 bits of randomness, far beyond anything enumerable.
+const qrTokenBytes = 16
 
-	qr, err := qrcode.Encode(qrString, qrcode.Medium, 256)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to generate QR code: %v", err)
+// NewQRToken returns an opaque, URL-safe token identifying a container label.
+func NewQRToken() (string, error) {
+	buf := make([]byte, qrTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate QR token: %v", err)
 	}
 
-	qrBase64 := fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(qr))
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// QRLink is the deep link encoded into a label, which a phone camera opens
+// directly.
+func QRLink(baseURL, token string) string {
+	return strings.TrimRight(baseURL, "/") + "/" + token
+}
+
+// QRToken extracts the token from a scanned payload. Scanners hand back the
+// full deep link while older labels and manual entry carry the bare code.
+func QRToken(payload string) string {
+	payload = strings.TrimSpace(payload)
+	if i := strings.IndexAny(payload, "?#"); i >= 0 {
+		payload = payload[:i]
+	}
+	payload = strings.TrimRight(payload, "/")
+	if i := strings.LastIndex(payload, "/"); i >= 0 {
+		payload = payload[i+1:]
+	}
 
-	return qrString, qrBase64, nil
+	return payload
 }